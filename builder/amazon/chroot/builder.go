@@ -56,6 +56,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 	b.config.tpl.Funcs(awscommon.TemplateFuncs)
 
 	// Defaults
@@ -209,14 +210,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, leftovers)
 
 	b.runner.Run(state)
 
@@ -246,3 +240,18 @@ func (b *Builder) Cancel() {
 		b.runner.Cancel()
 	}
 }
+
+// leftovers returns the volume of the build and where it is mounted as
+// the resources that are left behind when the cleanup is skipped.
+func leftovers(state multistep.StateBag) []common.Leftover {
+	var result []common.Leftover
+	if volumeId, ok := state.GetOk("volume_id"); ok {
+		result = append(result, common.Leftover{Type: "volume-id", Name: "Volume ID", Value: volumeId.(string)})
+	}
+
+	if mountPath, ok := state.GetOk("mount_path"); ok {
+		result = append(result, common.Leftover{Type: "mount-path", Name: "Mount path", Value: mountPath.(string)})
+	}
+
+	return result
+}
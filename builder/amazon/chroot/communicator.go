@@ -3,6 +3,7 @@ package chroot
 import (
 	"bytes"
 	"fmt"
+	"github.com/mitchellh/packer/common"
 	"github.com/mitchellh/packer/packer"
 	"io"
 	"io/ioutil"
@@ -37,7 +38,19 @@ func (c *Communicator) Start(cmd *packer.RemoteCmd) error {
 		return err
 	}
 
+	doneCh := make(chan struct{})
 	go func() {
+		select {
+		case <-cmd.KillCh():
+			log.Printf("Killing chroot command: '%s'", cmd.Command)
+			localCmd.Process.Kill()
+		case <-doneCh:
+		}
+	}()
+
+	go func() {
+		defer close(doneCh)
+
 		exitStatus := 0
 		if err := localCmd.Wait(); err != nil {
 			if exitErr, ok := err.(*exec.ExitError); ok {
@@ -120,3 +133,9 @@ func (c *Communicator) Download(src string, w io.Writer) error {
 
 	return nil
 }
+
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	src = filepath.Join(c.Chroot, src)
+	log.Printf("Downloading directory from chroot dir: %s", src)
+	return common.CopyDir(dst, src, exclude)
+}
@@ -0,0 +1,21 @@
+package common
+
+import (
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/common"
+)
+
+// InstanceLeftovers returns the instance of the build, if any, as the
+// resource that is left behind when the cleanup is skipped.
+func InstanceLeftovers(state multistep.StateBag) []common.Leftover {
+	raw, ok := state.GetOk("instance")
+	if !ok {
+		return nil
+	}
+
+	instance := raw.(*ec2.Instance)
+	return []common.Leftover{
+		{Type: "instance-id", Name: "Instance ID", Value: instance.InstanceId},
+	}
+}
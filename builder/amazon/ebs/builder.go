@@ -20,6 +20,7 @@ const BuilderId = "mitchellh.amazonebs"
 
 type config struct {
 	common.PackerConfig    `mapstructure:",squash"`
+	common.SSHCommConfig   `mapstructure:",squash"`
 	awscommon.AccessConfig `mapstructure:",squash"`
 	awscommon.AMIConfig    `mapstructure:",squash"`
 	awscommon.BlockDevices `mapstructure:",squash"`
@@ -44,6 +45,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 	b.config.tpl.Funcs(awscommon.TemplateFuncs)
 
 	// Accumulate any errors
@@ -51,6 +53,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packer.MultiErrorAppend(errs, b.config.AccessConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.AMIConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.RunConfig.Prepare(b.config.tpl)...)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
@@ -110,6 +113,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     awscommon.SSHAddress(ec2conn, b.config.SSHPort),
 			SSHConfig:      awscommon.SSHConfig(b.config.SSHUsername),
 			SSHWaitTimeout: b.config.SSHTimeout(),
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		&common.StepProvision{},
 		&stepStopInstance{},
@@ -128,14 +132,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, awscommon.InstanceLeftovers)
 
 	b.runner.Run(state)
 
@@ -22,6 +22,7 @@ const BuilderId = "mitchellh.amazon.instance"
 // settable from the template.
 type Config struct {
 	common.PackerConfig    `mapstructure:",squash"`
+	common.SSHCommConfig   `mapstructure:",squash"`
 	awscommon.AccessConfig `mapstructure:",squash"`
 	awscommon.AMIConfig    `mapstructure:",squash"`
 	awscommon.BlockDevices `mapstructure:",squash"`
@@ -56,6 +57,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 	b.config.tpl.Funcs(awscommon.TemplateFuncs)
 
 	if b.config.BundleDestination == "" {
@@ -99,6 +101,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packer.MultiErrorAppend(errs, b.config.AccessConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.AMIConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.RunConfig.Prepare(b.config.tpl)...)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 
 	validates := map[string]*string{
 		"bundle_upload_command": &b.config.BundleUploadCommand,
@@ -214,6 +217,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     awscommon.SSHAddress(ec2conn, b.config.SSHPort),
 			SSHConfig:      awscommon.SSHConfig(b.config.SSHUsername),
 			SSHWaitTimeout: b.config.SSHTimeout(),
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		&common.StepProvision{},
 		&StepUploadX509Cert{},
@@ -235,14 +239,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, awscommon.InstanceLeftovers)
 
 	b.runner.Run(state)
 
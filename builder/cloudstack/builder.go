@@ -22,7 +22,8 @@ const BuilderId = "mindjiver.cloudstack"
 // communicating with CloudStack and describes the template you are
 // creating
 type config struct {
-	common.PackerConfig `mapstructure:",squash"`
+	common.PackerConfig  `mapstructure:",squash"`
+	common.SSHCommConfig `mapstructure:",squash"`
 
 	APIURL string `mapstructure:"api_url"`
 	APIKey string `mapstructure:"api_key"`
@@ -79,9 +80,11 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := common.CheckUnusedConfig(md)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 
 	if b.config.APIURL == "" {
 		b.config.APIURL = os.Getenv("CLOUDSTACK_API_URL")
@@ -244,6 +247,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     sshAddress,
 			SSHConfig:      sshConfig,
 			SSHWaitTimeout: b.config.sshTimeout,
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		new(common.StepProvision),
 		new(stepStopVirtualMachine),
@@ -251,14 +255,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run the steps
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, leftovers)
 
 	b.runner.Run(state)
 
@@ -287,3 +284,16 @@ func (b *Builder) Cancel() {
 		b.runner.Cancel()
 	}
 }
+
+// leftovers returns the virtual machine of the build, if any, as the
+// resource that is left behind when the cleanup is skipped.
+func leftovers(state multistep.StateBag) []common.Leftover {
+	id, ok := state.GetOk("virtual_machine_id")
+	if !ok {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "virtual-machine-id", Name: "Virtual machine ID", Value: id.(string)},
+	}
+}
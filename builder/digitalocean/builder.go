@@ -22,7 +22,8 @@ const BuilderId = "pearkes.digitalocean"
 // to use while communicating with DO and describes the image
 // you are creating
 type config struct {
-	common.PackerConfig `mapstructure:",squash"`
+	common.PackerConfig  `mapstructure:",squash"`
+	common.SSHCommConfig `mapstructure:",squash"`
 
 	ClientID string `mapstructure:"client_id"`
 	APIKey   string `mapstructure:"api_key"`
@@ -63,9 +64,11 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := common.CheckUnusedConfig(md)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 
 	// Optional configuration with defaults
 	if b.config.APIKey == "" {
@@ -197,6 +200,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     sshAddress,
 			SSHConfig:      sshConfig,
 			SSHWaitTimeout: 5 * time.Minute,
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		new(common.StepProvision),
 		new(stepShutdown),
@@ -205,14 +209,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run the steps
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, leftovers)
 
 	b.runner.Run(state)
 
@@ -250,3 +247,16 @@ func (b *Builder) Cancel() {
 		b.runner.Cancel()
 	}
 }
+
+// leftovers returns the droplet of the build, if any, as the resource that
+// is left behind when the cleanup is skipped.
+func leftovers(state multistep.StateBag) []common.Leftover {
+	dropletId, ok := state.GetOk("droplet_id")
+	if !ok {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "droplet-id", Name: "Droplet ID", Value: fmt.Sprintf("%d", dropletId.(uint))},
+	}
+}
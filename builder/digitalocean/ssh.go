@@ -1,10 +1,9 @@
 package digitalocean
 
 import (
-	gossh "code.google.com/p/go.crypto/ssh"
 	"fmt"
 	"github.com/mitchellh/multistep"
-	"github.com/mitchellh/packer/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 func sshAddress(state multistep.StateBag) (string, error) {
@@ -17,15 +16,15 @@ func sshConfig(state multistep.StateBag) (*gossh.ClientConfig, error) {
 	config := state.Get("config").(config)
 	privateKey := state.Get("privateKey").(string)
 
-	keyring := new(ssh.SimpleKeychain)
-	if err := keyring.AddPEMKey(privateKey); err != nil {
+	signer, err := gossh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
 		return nil, fmt.Errorf("Error setting up SSH config: %s", err)
 	}
 
 	return &gossh.ClientConfig{
 		User: config.SSHUsername,
-		Auth: []gossh.ClientAuth{
-			gossh.ClientAuthKeyring(keyring),
+		Auth: []gossh.AuthMethod{
+			gossh.PublicKeys(signer),
 		},
 	}, nil
 }
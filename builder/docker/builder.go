@@ -48,14 +48,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state.Put("driver", driver)
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, leftovers)
 
 	b.runner.Run(state)
 
@@ -75,3 +68,16 @@ func (b *Builder) Cancel() {
 		b.runner.Cancel()
 	}
 }
+
+// leftovers returns the container of the build, if any, as the resource
+// that is left behind when the cleanup is skipped.
+func leftovers(state multistep.StateBag) []common.Leftover {
+	containerId, ok := state.GetOk("container_id")
+	if !ok {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "container-id", Name: "Container ID", Value: containerId.(string)},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/ActiveState/tail"
+	"github.com/mitchellh/packer/common"
 	"github.com/mitchellh/packer/packer"
 	"io"
 	"io/ioutil"
@@ -172,7 +173,63 @@ func (c *Communicator) UploadDir(dst string, src string, exclude []string) error
 }
 
 func (c *Communicator) Download(src string, dst io.Writer) error {
-	panic("not implemented")
+	// Create a temporary file in the shared folder to copy the file to
+	tempfile, err := ioutil.TempFile(c.HostDir, "download")
+	if err != nil {
+		return err
+	}
+	tempfile.Close()
+	defer os.Remove(tempfile.Name())
+
+	// Copy the file from the container into the shared folder, then
+	// read it from the host.
+	cmd := &packer.RemoteCmd{
+		Command: fmt.Sprintf("cp %s %s/%s", src, c.ContainerDir,
+			filepath.Base(tempfile.Name())),
+	}
+	if err := c.Start(cmd); err != nil {
+		return err
+	}
+
+	// Wait for the copy to complete
+	cmd.Wait()
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("Download failed with non-zero exit status: %d", cmd.ExitStatus)
+	}
+
+	f, err := os.Open(tempfile.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	// Create the temporary directory in the shared folder that will
+	// store the contents of "src" for copying out of the container.
+	td, err := ioutil.TempDir(c.HostDir, "dirdownload")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	cmd := &packer.RemoteCmd{
+		Command: fmt.Sprintf("cp -R %s/. %s/%s", src, c.ContainerDir, filepath.Base(td)),
+	}
+	if err := c.Start(cmd); err != nil {
+		return err
+	}
+
+	// Wait for the copy to complete
+	cmd.Wait()
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("Download failed with non-zero exit status: %d", cmd.ExitStatus)
+	}
+
+	return common.CopyDir(dst, td, exclude)
 }
 
 // Runs the given command and blocks until completion
@@ -218,6 +275,17 @@ func (c *Communicator) run(cmd *exec.Cmd, remote *packer.RemoteCmd, stdin_w io.W
 		return
 	}
 
+	// Stop attaching to the container if the command is killed
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	go func() {
+		select {
+		case <-remote.KillCh():
+			cmd.Process.Kill()
+		case <-doneCh:
+		}
+	}()
+
 	go func() {
 		defer stdin_w.Close()
 
@@ -266,7 +334,16 @@ func (c *Communicator) run(cmd *exec.Cmd, remote *packer.RemoteCmd, stdin_w io.W
 			break
 		}
 
-		time.Sleep(1 * time.Second)
+		// The command runs in the shell of the container, which we can't
+		// signal, so if we're asked to kill it we just stop waiting. It
+		// goes away with the container.
+		select {
+		case <-remote.KillCh():
+			log.Printf("Command killed, not waiting for exit code: %s", remote.Command)
+			remote.SetExited(-1)
+			return
+		case <-time.After(1 * time.Second):
+		}
 	}
 
 	// Read the exit code
@@ -30,6 +30,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	}
 
 	c.tpl.UserVars = c.PackerUserVars
+	c.tpl.UpstreamArtifacts = c.PackerUpstreamArtifacts
 
 	// Defaults
 	if len(c.RunCommand) == 0 {
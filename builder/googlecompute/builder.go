@@ -63,6 +63,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     sshAddress,
 			SSHConfig:      sshConfig,
 			SSHWaitTimeout: 5 * time.Minute,
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		new(common.StepProvision),
 		new(StepUpdateGsutil),
@@ -72,14 +73,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run the steps.
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, leftovers)
 	b.runner.Run(state)
 
 	// Report any errors.
@@ -105,3 +99,16 @@ func (b *Builder) Cancel() {
 		b.runner.Cancel()
 	}
 }
+
+// leftovers returns the instance of the build, if any, as the resource
+// that is left behind when the cleanup is skipped.
+func leftovers(state multistep.StateBag) []common.Leftover {
+	name, ok := state.GetOk("instance_name")
+	if !ok {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "instance-name", Name: "Instance name", Value: name.(string)},
+	}
+}
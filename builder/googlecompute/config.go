@@ -13,7 +13,8 @@ import (
 // both the publicly settable state as well as the privately generated
 // state of the config object.
 type Config struct {
-	common.PackerConfig `mapstructure:",squash"`
+	common.PackerConfig  `mapstructure:",squash"`
+	common.SSHCommConfig `mapstructure:",squash"`
 
 	BucketName        string            `mapstructure:"bucket_name"`
 	ClientSecretsFile string            `mapstructure:"client_secrets_file"`
@@ -53,9 +54,11 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		return nil, nil, err
 	}
 	c.tpl.UserVars = c.PackerUserVars
+	c.tpl.UpstreamArtifacts = c.PackerUpstreamArtifacts
 
 	// Prepare the errors
 	errs := common.CheckUnusedConfig(md)
+	errs = packer.MultiErrorAppend(errs, c.SSHCommConfig.Prepare(c.tpl)...)
 
 	// Set defaults.
 	if c.Network == "" {
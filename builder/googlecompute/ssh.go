@@ -3,9 +3,8 @@ package googlecompute
 import (
 	"fmt"
 
-	gossh "code.google.com/p/go.crypto/ssh"
 	"github.com/mitchellh/multistep"
-	"github.com/mitchellh/packer/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 // sshAddress returns the ssh address.
@@ -20,14 +19,14 @@ func sshConfig(state multistep.StateBag) (*gossh.ClientConfig, error) {
 	config := state.Get("config").(*Config)
 	privateKey := state.Get("ssh_private_key").(string)
 
-	keyring := new(ssh.SimpleKeychain)
-	if err := keyring.AddPEMKey(privateKey); err != nil {
+	signer, err := gossh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
 		return nil, fmt.Errorf("Error setting up SSH config: %s", err)
 	}
 
 	sshConfig := &gossh.ClientConfig{
 		User: config.SSHUsername,
-		Auth: []gossh.ClientAuth{gossh.ClientAuthKeyring(keyring)},
+		Auth: []gossh.AuthMethod{gossh.PublicKeys(signer)},
 	}
 
 	return sshConfig, nil
@@ -1,7 +1,6 @@
 package googlecompute
 
 import (
-	"code.google.com/p/go.crypto/ssh"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,6 +8,7 @@ import (
 	"fmt"
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/packer"
+	"golang.org/x/crypto/ssh"
 	"os"
 )
 
@@ -16,10 +16,11 @@ import (
 const BuilderId = "mitchellh.openstack"
 
 type config struct {
-	common.PackerConfig `mapstructure:",squash"`
-	AccessConfig        `mapstructure:",squash"`
-	ImageConfig         `mapstructure:",squash"`
-	RunConfig           `mapstructure:",squash"`
+	common.PackerConfig  `mapstructure:",squash"`
+	common.SSHCommConfig `mapstructure:",squash"`
+	AccessConfig         `mapstructure:",squash"`
+	ImageConfig          `mapstructure:",squash"`
+	RunConfig            `mapstructure:",squash"`
 
 	tpl *packer.ConfigTemplate
 }
@@ -40,12 +41,14 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := common.CheckUnusedConfig(md)
 	errs = packer.MultiErrorAppend(errs, b.config.AccessConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.ImageConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.RunConfig.Prepare(b.config.tpl)...)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
@@ -94,20 +97,14 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     SSHAddress(csp, b.config.SSHPort),
 			SSHConfig:      SSHConfig(b.config.SSHUsername),
 			SSHWaitTimeout: b.config.SSHTimeout(),
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		&common.StepProvision{},
 		&stepCreateImage{},
 	}
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, leftovers)
 
 	b.runner.Run(state)
 
@@ -137,3 +134,16 @@ func (b *Builder) Cancel() {
 		b.runner.Cancel()
 	}
 }
+
+// leftovers returns the server of the build, if any, as the resource that
+// is left behind when the cleanup is skipped.
+func leftovers(state multistep.StateBag) []common.Leftover {
+	server, ok := state.GetOk("server")
+	if !ok {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "server-id", Name: "Server ID", Value: server.(*gophercloud.Server).Id},
+	}
+}
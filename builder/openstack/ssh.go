@@ -1,12 +1,11 @@
 package openstack
 
 import (
-	gossh "code.google.com/p/go.crypto/ssh"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
-	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/rackspace/gophercloud"
+	gossh "golang.org/x/crypto/ssh"
 	"time"
 )
 
@@ -43,15 +42,15 @@ func SSHConfig(username string) func(multistep.StateBag) (*gossh.ClientConfig, e
 	return func(state multistep.StateBag) (*gossh.ClientConfig, error) {
 		privateKey := state.Get("privateKey").(string)
 
-		keyring := new(ssh.SimpleKeychain)
-		if err := keyring.AddPEMKey(privateKey); err != nil {
+		signer, err := gossh.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
 			return nil, fmt.Errorf("Error setting up SSH config: %s", err)
 		}
 
 		return &gossh.ClientConfig{
 			User: username,
-			Auth: []gossh.ClientAuth{
-				gossh.ClientAuthKeyring(keyring),
+			Auth: []gossh.AuthMethod{
+				gossh.PublicKeys(signer),
 			},
 		}, nil
 	}
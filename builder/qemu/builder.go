@@ -52,7 +52,8 @@ type Builder struct {
 }
 
 type config struct {
-	common.PackerConfig `mapstructure:",squash"`
+	common.PackerConfig  `mapstructure:",squash"`
+	common.SSHCommConfig `mapstructure:",squash"`
 
 	Accelerator     string     `mapstructure:"accelerator"`
 	BootCommand     []string   `mapstructure:"boot_command"`
@@ -107,9 +108,11 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := common.CheckUnusedConfig(md)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 
 	if b.config.DiskSize == 0 {
 		b.config.DiskSize = 40000
@@ -305,7 +308,8 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	if !b.config.PackerForce {
+	// The output directory of a build that is resumed already exists
+	if !b.config.PackerForce && !(b.config.PackerResume && common.CheckpointExists(b.config.OutputDir)) {
 		if _, err := os.Stat(b.config.OutputDir); err == nil {
 			errs = packer.MultiErrorAppend(
 				errs,
@@ -337,7 +341,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		if _, err := os.Stat(b.config.SSHKeyPath); err != nil {
 			errs = packer.MultiErrorAppend(
 				errs, fmt.Errorf("ssh_key_path is invalid: %s", err))
-		} else if _, err := sshKeyToKeyring(b.config.SSHKeyPath); err != nil {
+		} else if _, err := sshKeyToSigner(b.config.SSHKeyPath); err != nil {
 			errs = packer.MultiErrorAppend(
 				errs, fmt.Errorf("ssh_key_path is invalid: %s", err))
 		}
@@ -408,6 +412,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     sshAddress,
 			SSHConfig:      sshConfig,
 			SSHWaitTimeout: b.config.sshWaitTimeout,
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		new(common.StepProvision),
 		new(stepShutdown),
@@ -422,14 +427,8 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state.Put("ui", ui)
 
 	// Run
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewResumableRunner(
+		steps, b.config.PackerConfig, ui, b.leftovers, b.config.OutputDir)
 
 	b.runner.Run(state)
 
@@ -499,3 +498,17 @@ func (b *Builder) newDriver(qemuBinary string) (Driver, error) {
 
 	return driver, nil
 }
+
+// leftovers returns the VM of the build and its output directory, if it
+// was created, as the resources that are left behind when the cleanup is
+// skipped.
+func (b *Builder) leftovers(state multistep.StateBag) []common.Leftover {
+	if _, err := os.Stat(b.config.OutputDir); err != nil {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "vm-name", Name: "VM name", Value: b.config.VMName},
+		{Type: "output-directory", Name: "Output directory", Value: b.config.OutputDir},
+	}
+}
@@ -45,6 +45,37 @@ func (s *stepRun) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
+// Resume starts the VM again if the build is resumed before it was shut
+// down, since the VM doesn't outlive a run of Packer. It boots from the
+// disk if it was installed, and from the CD-ROM otherwise.
+func (s *stepRun) Resume(state multistep.StateBag) multistep.StepAction {
+	if _, ok := state.GetOk("vm_stopped"); ok {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Starting the VM again to resume the build")
+
+	command, err := getCommandArgs("order=cd", state)
+	if err != nil {
+		err := fmt.Errorf("Error processing QemuArgs: %s", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if err := driver.Qemu(command...); err != nil {
+		err := fmt.Errorf("Error launching VM: %s\n"+
+			"If the VM of the build that is resumed is still running, "+
+			"it must be stopped first.", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
 func (s *stepRun) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
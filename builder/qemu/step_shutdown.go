@@ -19,7 +19,7 @@ import (
 //   ui     packer.Ui
 //
 // Produces:
-//   <nothing>
+//   vm_stopped bool - Whether the VM was shut down, for resumed builds.
 type stepShutdown struct{}
 
 func (s *stepShutdown) Run(state multistep.StateBag) multistep.StepAction {
@@ -64,6 +64,7 @@ func (s *stepShutdown) Run(state multistep.StateBag) multistep.StepAction {
 	}
 
 	log.Println("VM shut down.")
+	state.Put("vm_stopped", true)
 	return multistep.ActionContinue
 }
 
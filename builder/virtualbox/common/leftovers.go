@@ -0,0 +1,19 @@
+package common
+
+import (
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/common"
+)
+
+// Leftovers returns the VM of the build, if any, as the resource that is
+// left behind when the cleanup is skipped.
+func Leftovers(state multistep.StateBag) []common.Leftover {
+	vmName, ok := state.GetOk("vmName")
+	if !ok {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "vm-name", Name: "VM name", Value: vmName.(string)},
+	}
+}
@@ -1,10 +1,10 @@
 package common
 
 import (
-	gossh "code.google.com/p/go.crypto/ssh"
 	"fmt"
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
 	"io/ioutil"
 	"os"
 )
@@ -16,19 +16,19 @@ func SSHAddress(state multistep.StateBag) (string, error) {
 
 func SSHConfigFunc(config SSHConfig) func(multistep.StateBag) (*gossh.ClientConfig, error) {
 	return func(state multistep.StateBag) (*gossh.ClientConfig, error) {
-		auth := []gossh.ClientAuth{
-			gossh.ClientAuthPassword(ssh.Password(config.SSHPassword)),
-			gossh.ClientAuthKeyboardInteractive(
-				ssh.PasswordKeyboardInteractive(config.SSHPassword)),
+		auth := []gossh.AuthMethod{
+			gossh.Password(config.SSHPassword),
+			gossh.KeyboardInteractive(
+				ssh.PasswordKeyboardInteractive(config.SSHPassword).Challenge),
 		}
 
 		if config.SSHKeyPath != "" {
-			keyring, err := sshKeyToKeyring(config.SSHKeyPath)
+			signer, err := sshKeyToSigner(config.SSHKeyPath)
 			if err != nil {
 				return nil, err
 			}
 
-			auth = append(auth, gossh.ClientAuthKeyring(keyring))
+			auth = append(auth, gossh.PublicKeys(signer))
 		}
 
 		return &gossh.ClientConfig{
@@ -38,7 +38,7 @@ func SSHConfigFunc(config SSHConfig) func(multistep.StateBag) (*gossh.ClientConf
 	}
 }
 
-func sshKeyToKeyring(path string) (gossh.ClientKeyring, error) {
+func sshKeyToSigner(path string) (gossh.Signer, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -50,10 +50,5 @@ func sshKeyToKeyring(path string) (gossh.ClientKeyring, error) {
 		return nil, err
 	}
 
-	keyring := new(ssh.SimpleKeychain)
-	if err := keyring.AddPEMKey(string(keyBytes)); err != nil {
-		return nil, err
-	}
-
-	return keyring, nil
+	return gossh.ParsePrivateKey(keyBytes)
 }
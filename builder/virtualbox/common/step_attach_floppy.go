@@ -19,6 +19,7 @@ import (
 //   vmName string
 //
 // Produces:
+//   attached_floppy_path string - The copy of the floppy disk that is attached
 type StepAttachFloppy struct {
 	floppyPath string
 }
@@ -75,6 +76,17 @@ func (s *StepAttachFloppy) Run(state multistep.StateBag) multistep.StepAction {
 
 	// Track the path so that we can unregister it from VirtualBox later
 	s.floppyPath = floppyPath
+	state.Put("attached_floppy_path", floppyPath)
+
+	return multistep.ActionContinue
+}
+
+// Resume restores the copy of the floppy disk so that it is detached and
+// deleted when the build is done.
+func (s *StepAttachFloppy) Resume(state multistep.StateBag) multistep.StepAction {
+	if floppyPath, ok := state.GetOk("attached_floppy_path"); ok {
+		s.floppyPath = floppyPath.(string)
+	}
 
 	return multistep.ActionContinue
 }
@@ -64,6 +64,24 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
+// Resume starts the VM again if it isn't running and the build is resumed
+// before it was shut down.
+func (s *StepRun) Resume(state multistep.StateBag) multistep.StepAction {
+	if _, ok := state.GetOk("vm_stopped"); ok {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+
+	if running, _ := driver.IsRunning(vmName); running {
+		s.vmName = vmName
+		return multistep.ActionContinue
+	}
+
+	return s.Run(state)
+}
+
 func (s *StepRun) Cleanup(state multistep.StateBag) {
 	if s.vmName == "" {
 		return
@@ -19,7 +19,7 @@ import (
 //   vmName string
 //
 // Produces:
-//   <nothing>
+//   vm_stopped bool - Whether the VM was shut down, for resumed builds.
 type StepShutdown struct {
 	Command string
 	Timeout time.Duration
@@ -72,6 +72,7 @@ func (s *StepShutdown) Run(state multistep.StateBag) multistep.StepAction {
 	}
 
 	log.Println("VM shut down.")
+	state.Put("vm_stopped", true)
 	return multistep.ActionContinue
 }
 
@@ -28,6 +28,7 @@ type Builder struct {
 
 type config struct {
 	common.PackerConfig          `mapstructure:",squash"`
+	common.SSHCommConfig         `mapstructure:",squash"`
 	vboxcommon.ExportConfig      `mapstructure:",squash"`
 	vboxcommon.FloppyConfig      `mapstructure:",squash"`
 	vboxcommon.OutputConfig      `mapstructure:",squash"`
@@ -69,6 +70,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors and warnings
 	errs := common.CheckUnusedConfig(md)
@@ -81,6 +83,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packer.MultiErrorAppend(errs, b.config.SSHConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.VBoxManageConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.VBoxVersionConfig.Prepare(b.config.tpl)...)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 	warnings := make([]string, 0)
 
 	if b.config.DiskSize == 0 {
@@ -305,6 +308,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     vboxcommon.SSHAddress,
 			SSHConfig:      vboxcommon.SSHConfigFunc(b.config.SSHConfig),
 			SSHWaitTimeout: b.config.SSHWaitTimeout,
+			CommConfig:     &b.config.SSHCommConfig,
 		},
 		&vboxcommon.StepUploadVersion{
 			Path: b.config.VBoxVersionFile,
@@ -331,14 +335,8 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state.Put("ui", ui)
 
 	// Run
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewResumableRunner(
+		steps, b.config.PackerConfig, ui, vboxcommon.Leftovers, b.config.OutputDir)
 
 	b.runner.Run(state)
 
@@ -61,6 +61,17 @@ func (s *stepAttachGuestAdditions) Run(state multistep.StateBag) multistep.StepA
 	return multistep.ActionContinue
 }
 
+// Resume restores the path of the guest additions, if they were attached,
+// so that they are detached when the build is done.
+func (s *stepAttachGuestAdditions) Resume(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*config)
+	if config.GuestAdditionsMode == GuestAdditionsModeAttach {
+		s.attachedPath = state.Get("guest_additions_path").(string)
+	}
+
+	return multistep.ActionContinue
+}
+
 func (s *stepAttachGuestAdditions) Cleanup(state multistep.StateBag) {
 	if s.attachedPath == "" {
 		return
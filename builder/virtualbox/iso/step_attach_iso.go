@@ -47,6 +47,13 @@ func (s *stepAttachISO) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
+// Resume restores the path of the ISO so that it is detached when the
+// build is done.
+func (s *stepAttachISO) Resume(state multistep.StateBag) multistep.StepAction {
+	s.diskPath = state.Get("iso_path").(string)
+	return multistep.ActionContinue
+}
+
 func (s *stepAttachISO) Cleanup(state multistep.StateBag) {
 	if s.diskPath == "" {
 		return
@@ -56,6 +56,13 @@ func (s *stepCreateVM) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
+// Resume restores the name of the VM so that it is deleted when the build
+// is done.
+func (s *stepCreateVM) Resume(state multistep.StateBag) multistep.StepAction {
+	s.vmName = state.Get("vmName").(string)
+	return multistep.ActionContinue
+}
+
 func (s *stepCreateVM) Cleanup(state multistep.StateBag) {
 	if s.vmName == "" {
 		return
@@ -73,3 +73,9 @@ func (s *stepHTTPServer) Cleanup(multistep.StateBag) {
 		s.l.Close()
 	}
 }
+
+// Resume starts the HTTP server again, since it doesn't outlive a run of
+// Packer and the boot command may need it.
+func (s *stepHTTPServer) Resume(state multistep.StateBag) multistep.StepAction {
+	return s.Run(state)
+}
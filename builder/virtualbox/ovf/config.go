@@ -12,6 +12,7 @@ import (
 // Config is the configuration structure for the builder.
 type Config struct {
 	common.PackerConfig          `mapstructure:",squash"`
+	common.SSHCommConfig         `mapstructure:",squash"`
 	vboxcommon.ExportConfig      `mapstructure:",squash"`
 	vboxcommon.FloppyConfig      `mapstructure:",squash"`
 	vboxcommon.OutputConfig      `mapstructure:",squash"`
@@ -40,6 +41,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		return nil, nil, err
 	}
 	c.tpl.UserVars = c.PackerUserVars
+	c.tpl.UpstreamArtifacts = c.PackerUpstreamArtifacts
 
 	// Defaults
 	if c.VMName == "" {
@@ -56,6 +58,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	errs = packer.MultiErrorAppend(errs, c.SSHConfig.Prepare(c.tpl)...)
 	errs = packer.MultiErrorAppend(errs, c.VBoxManageConfig.Prepare(c.tpl)...)
 	errs = packer.MultiErrorAppend(errs, c.VBoxVersionConfig.Prepare(c.tpl)...)
+	errs = packer.MultiErrorAppend(errs, c.SSHCommConfig.Prepare(c.tpl)...)
 
 	templates := map[string]*string{
 		"source_path": &c.SourcePath,
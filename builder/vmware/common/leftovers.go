@@ -0,0 +1,19 @@
+package common
+
+import (
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/common"
+)
+
+// Leftovers returns the VMX file of the VM of the build, if any, as the
+// resource that is left behind when the cleanup is skipped.
+func Leftovers(state multistep.StateBag) []common.Leftover {
+	vmxPath, ok := state.GetOk("vmx_path")
+	if !ok {
+		return nil
+	}
+
+	return []common.Leftover{
+		{Type: "vmx-path", Name: "VMX path", Value: vmxPath.(string)},
+	}
+}
@@ -29,7 +29,8 @@ func (c *OutputConfig) Prepare(t *packer.ConfigTemplate, pc *common.PackerConfig
 		}
 	}
 
-	if !pc.PackerForce {
+	// The output directory of a build that is resumed already exists
+	if !pc.PackerForce && !(pc.PackerResume && common.CheckpointExists(c.OutputDir)) {
 		if _, err := os.Stat(c.OutputDir); err == nil {
 			errs = append(errs, fmt.Errorf(
 				"Output directory '%s' already exists. It must not exist.", c.OutputDir))
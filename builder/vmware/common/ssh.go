@@ -1,7 +1,6 @@
 package common
 
 import (
-	gossh "code.google.com/p/go.crypto/ssh"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 func SSHAddressFunc(config *SSHConfig) func(multistep.StateBag) (string, error) {
@@ -63,19 +63,19 @@ func SSHAddressFunc(config *SSHConfig) func(multistep.StateBag) (string, error)
 
 func SSHConfigFunc(config *SSHConfig) func(multistep.StateBag) (*gossh.ClientConfig, error) {
 	return func(state multistep.StateBag) (*gossh.ClientConfig, error) {
-		auth := []gossh.ClientAuth{
-			gossh.ClientAuthPassword(ssh.Password(config.SSHPassword)),
-			gossh.ClientAuthKeyboardInteractive(
-				ssh.PasswordKeyboardInteractive(config.SSHPassword)),
+		auth := []gossh.AuthMethod{
+			gossh.Password(config.SSHPassword),
+			gossh.KeyboardInteractive(
+				ssh.PasswordKeyboardInteractive(config.SSHPassword).Challenge),
 		}
 
 		if config.SSHKeyPath != "" {
-			keyring, err := sshKeyToKeyring(config.SSHKeyPath)
+			signer, err := sshKeyToSigner(config.SSHKeyPath)
 			if err != nil {
 				return nil, err
 			}
 
-			auth = append(auth, gossh.ClientAuthKeyring(keyring))
+			auth = append(auth, gossh.PublicKeys(signer))
 		}
 
 		return &gossh.ClientConfig{
@@ -85,7 +85,7 @@ func SSHConfigFunc(config *SSHConfig) func(multistep.StateBag) (*gossh.ClientCon
 	}
 }
 
-func sshKeyToKeyring(path string) (gossh.ClientKeyring, error) {
+func sshKeyToSigner(path string) (gossh.Signer, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -97,10 +97,5 @@ func sshKeyToKeyring(path string) (gossh.ClientKeyring, error) {
 		return nil, err
 	}
 
-	keyring := new(ssh.SimpleKeychain)
-	if err := keyring.AddPEMKey(string(keyBytes)); err != nil {
-		return nil, err
-	}
-
-	return keyring, nil
+	return gossh.ParsePrivateKey(keyBytes)
 }
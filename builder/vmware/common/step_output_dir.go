@@ -48,6 +48,13 @@ func (s *StepOutputDir) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
+// Resume marks the output directory as set up, so that it is deleted if
+// the resumed build is cancelled or fails.
+func (s *StepOutputDir) Resume(state multistep.StateBag) multistep.StepAction {
+	s.success = true
+	return multistep.ActionContinue
+}
+
 func (s *StepOutputDir) Cleanup(state multistep.StateBag) {
 	if !s.success {
 		return
@@ -82,6 +82,24 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
+// Resume starts the VM again if it isn't running and the build is resumed
+// before it was shut down.
+func (s *StepRun) Resume(state multistep.StateBag) multistep.StepAction {
+	if _, ok := state.GetOk("vm_stopped"); ok {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	vmxPath := state.Get("vmx_path").(string)
+
+	if running, _ := driver.IsRunning(vmxPath); running {
+		s.vmxPath = vmxPath
+		return multistep.ActionContinue
+	}
+
+	return s.Run(state)
+}
+
 func (s *StepRun) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
@@ -24,7 +24,7 @@ import (
 //   vmx_path string
 //
 // Produces:
-//   <nothing>
+//   vm_stopped bool - Whether the VM was shut down, for resumed builds.
 type StepShutdown struct {
 	Command string
 	Timeout time.Duration
@@ -142,6 +142,7 @@ LockWaitLoop:
 	}
 
 	log.Println("VM shut down.")
+	state.Put("vm_stopped", true)
 	return multistep.ActionContinue
 }
 
@@ -25,6 +25,7 @@ type Builder struct {
 
 type config struct {
 	common.PackerConfig      `mapstructure:",squash"`
+	common.SSHCommConfig     `mapstructure:",squash"`
 	vmwcommon.DriverConfig   `mapstructure:",squash"`
 	vmwcommon.OutputConfig   `mapstructure:",squash"`
 	vmwcommon.RunConfig      `mapstructure:",squash"`
@@ -75,6 +76,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, err
 	}
 	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.UpstreamArtifacts = b.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := common.CheckUnusedConfig(md)
@@ -85,6 +87,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packer.MultiErrorAppend(errs, b.config.ShutdownConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.SSHConfig.Prepare(b.config.tpl)...)
 	errs = packer.MultiErrorAppend(errs, b.config.VMXConfig.Prepare(b.config.tpl)...)
+	errs = packer.MultiErrorAppend(errs, b.config.SSHCommConfig.Prepare(b.config.tpl)...)
 	warnings := make([]string, 0)
 
 	if b.config.DiskName == "" {
@@ -278,6 +281,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 				"a checksum is highly recommended.")
 	}
 
+	if b.config.RemoteType != "" && b.config.PackerResume {
+		warnings = append(warnings,
+			"Builds with a remote_type can't be resumed, so this build starts\n"+
+				"from the beginning.")
+	}
+
 	if b.config.ShutdownCommand == "" {
 		warnings = append(warnings,
 			"A shutdown_command was not specified. Without a shutdown command, Packer\n"+
@@ -357,6 +366,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SSHAddress:     driver.SSHAddress,
 			SSHConfig:      vmwcommon.SSHConfigFunc(&b.config.SSHConfig),
 			SSHWaitTimeout: b.config.SSHWaitTimeout,
+			CommConfig:     &b.config.SSHCommConfig,
 			NoPty:          b.config.SSHSkipRequestPty,
 		},
 		&stepUploadTools{},
@@ -373,13 +383,12 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
+	// Builds are only resumable when everything is local
+	if b.config.RemoteType == "" {
+		b.runner = common.NewResumableRunner(
+			steps, b.config.PackerConfig, ui, vmwcommon.Leftovers, b.config.OutputDir)
 	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
+		b.runner = common.NewRunner(steps, b.config.PackerConfig, ui, vmwcommon.Leftovers)
 	}
 
 	b.runner.Run(state)
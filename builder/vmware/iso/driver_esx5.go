@@ -3,13 +3,13 @@ package iso
 import (
 	"bufio"
 	"bytes"
-	gossh "code.google.com/p/go.crypto/ssh"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/mitchellh/packer/packer"
+	gossh "golang.org/x/crypto/ssh"
 	"io"
 	"log"
 	"net"
@@ -260,10 +260,10 @@ func (d *ESX5Driver) datastorePath(path string) string {
 func (d *ESX5Driver) connect() error {
 	address := fmt.Sprintf("%s:%d", d.Host, d.Port)
 
-	auth := []gossh.ClientAuth{
-		gossh.ClientAuthPassword(ssh.Password(d.Password)),
-		gossh.ClientAuthKeyboardInteractive(
-			ssh.PasswordKeyboardInteractive(d.Password)),
+	auth := []gossh.AuthMethod{
+		gossh.Password(d.Password),
+		gossh.KeyboardInteractive(
+			ssh.PasswordKeyboardInteractive(d.Password).Challenge),
 	}
 
 	// TODO(dougm) KeyPath support
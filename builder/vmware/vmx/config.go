@@ -12,6 +12,7 @@ import (
 // Config is the configuration structure for the builder.
 type Config struct {
 	common.PackerConfig      `mapstructure:",squash"`
+	common.SSHCommConfig     `mapstructure:",squash"`
 	vmwcommon.DriverConfig   `mapstructure:",squash"`
 	vmwcommon.OutputConfig   `mapstructure:",squash"`
 	vmwcommon.RunConfig      `mapstructure:",squash"`
@@ -38,6 +39,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		return nil, nil, err
 	}
 	c.tpl.UserVars = c.PackerUserVars
+	c.tpl.UpstreamArtifacts = c.PackerUpstreamArtifacts
 
 	// Defaults
 	if c.VMName == "" {
@@ -52,6 +54,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	errs = packer.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(c.tpl)...)
 	errs = packer.MultiErrorAppend(errs, c.SSHConfig.Prepare(c.tpl)...)
 	errs = packer.MultiErrorAppend(errs, c.VMXConfig.Prepare(c.tpl)...)
+	errs = packer.MultiErrorAppend(errs, c.SSHCommConfig.Prepare(c.tpl)...)
 
 	templates := map[string]*string{
 		"source_path": &c.SourcePath,
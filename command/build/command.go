@@ -2,16 +2,50 @@ package build
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	cmdcommon "github.com/mitchellh/packer/common/command"
+	"github.com/mitchellh/packer/common/uuid"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+)
+
+// The exit codes of the build command. If builds are both invalid and
+// failed, the command exits with ExitInvalid, since running them again
+// won't help until the template is fixed.
+const (
+	// ExitSuccess means that all the builds completed successfully.
+	ExitSuccess = 0
+
+	// ExitFailed means that builds failed while running, or that another
+	// error occurred, such as a log file that couldn't be created.
+	ExitFailed = 1
+
+	// ExitUsage means that the flags or arguments are invalid.
+	ExitUsage = 2
+
+	// ExitTemplate means that the template couldn't be read or parsed, or
+	// that its builds couldn't be created.
+	ExitTemplate = 3
+
+	// ExitInvalid means that builds failed to prepare, since their
+	// configuration is invalid.
+	ExitInvalid = 4
+
+	// ExitInterrupted means that the builds were interrupted.
+	ExitInterrupted = 5
+
+	// ExitTimedOut means that the builds were cancelled by the timeout.
+	ExitTimedOut = 6
 )
 
 type Command byte
@@ -21,46 +55,108 @@ func (Command) Help() string {
 }
 
 func (c Command) Run(env packer.Environment, args []string) int {
+	summary := &runSummary{start: time.Now()}
+	exitCode := c.run(env, args, summary)
+	if summary.path == "" {
+		return exitCode
+	}
+
+	if err := summary.write(exitCode); err != nil {
+		env.Ui().Error(fmt.Sprintf("Error writing summary: %s", err))
+		if exitCode == ExitSuccess {
+			exitCode = ExitFailed
+		}
+	}
+
+	return exitCode
+}
+
+// run runs the builds, filling in the summary of the run as it goes, and
+// returns the exit code of the command.
+func (c Command) run(env packer.Environment, args []string, summary *runSummary) int {
 	var cfgDebug bool
+	var cfgDryRun bool
 	var cfgForce bool
+	var cfgLogDir string
+	var cfgOnError string
+	var cfgParallel int
+	var cfgResume bool
+	var cfgSummary string
+	var cfgTimeout time.Duration
 	buildOptions := new(cmdcommon.BuildOptions)
 
 	cmdFlags := flag.NewFlagSet("build", flag.ContinueOnError)
 	cmdFlags.Usage = func() { env.Ui().Say(c.Help()) }
 	cmdFlags.BoolVar(&cfgDebug, "debug", false, "debug mode for builds")
+	cmdFlags.BoolVar(&cfgDryRun, "dry-run", false, "print what the builds would run")
 	cmdFlags.BoolVar(&cfgForce, "force", false, "force a build if artifacts exist")
+	cmdFlags.StringVar(&cfgLogDir, "log-dir", "", "directory to write the logs of the builds to")
+	cmdFlags.StringVar(&cfgOnError, "on-error", "", "what to do when a build fails")
+	cmdFlags.IntVar(&cfgParallel, "parallel", 0, "number of builds to run at once")
+	cmdFlags.BoolVar(&cfgResume, "resume", false, "resume builds from their checkpoints")
+	cmdFlags.StringVar(&cfgSummary, "summary", "", "file to write a JSON summary of the run to")
+	cmdFlags.DurationVar(&cfgTimeout, "timeout", 0, "time after which builds are cancelled")
 	cmdcommon.BuildOptionFlags(cmdFlags, buildOptions)
 	if err := cmdFlags.Parse(args); err != nil {
-		return 1
+		return ExitUsage
+	}
+
+	if cfgSummary != "" {
+		summary.path, _ = filepath.Abs(cfgSummary)
+	}
+
+	// usageError prints an error with the flags or arguments, followed by
+	// the help of the command.
+	usageError := func(message string) int {
+		summary.Error = message
+		env.Ui().Error(message)
+		env.Ui().Error("")
+		env.Ui().Error(c.Help())
+		return ExitUsage
 	}
 
 	args = cmdFlags.Args()
 	if len(args) != 1 {
+		summary.Error = "A single template must be given."
 		cmdFlags.Usage()
-		return 1
+		return ExitUsage
 	}
 
 	if err := buildOptions.Validate(); err != nil {
-		env.Ui().Error(err.Error())
-		env.Ui().Error("")
-		env.Ui().Error(c.Help())
-		return 1
+		return usageError(err.Error())
+	}
+
+	switch cfgOnError {
+	case "", packer.OnErrorCleanup, packer.OnErrorAbort, packer.OnErrorAsk:
+	default:
+		return usageError(fmt.Sprintf(
+			"The '-on-error' flag must be 'cleanup', 'abort' or 'ask', got '%s'.", cfgOnError))
+	}
+
+	if cfgForce && cfgResume {
+		return usageError("The '-force' and '-resume' flags can't be used together.")
+	}
+
+	if cfgParallel < 0 {
+		return usageError("The '-parallel' flag can't be negative.")
+	}
+
+	if cfgTimeout < 0 {
+		return usageError("The '-timeout' flag can't be negative.")
 	}
 
 	userVars, err := buildOptions.AllUserVars()
 	if err != nil {
-		env.Ui().Error(fmt.Sprintf("Error compiling user variables: %s", err))
-		env.Ui().Error("")
-		env.Ui().Error(c.Help())
-		return 1
+		return usageError(fmt.Sprintf("Error compiling user variables: %s", err))
 	}
 
 	// Read the file into a byte array so that we can parse the template
 	log.Printf("Reading template: %s", args[0])
 	tpl, err := packer.ParseTemplateFile(args[0], userVars)
 	if err != nil {
-		env.Ui().Error(fmt.Sprintf("Failed to parse template: %s", err))
-		return 1
+		summary.Error = fmt.Sprintf("Failed to parse template: %s", err)
+		env.Ui().Error(summary.Error)
+		return ExitTemplate
 	}
 
 	// The component finder for our builds
@@ -74,14 +170,28 @@ func (c Command) Run(env packer.Environment, args []string) int {
 	// Go through each builder and compile the builds that we care about
 	builds, err := buildOptions.Builds(tpl, components)
 	if err != nil {
-		env.Ui().Error(err.Error())
-		return 1
+		summary.Error = err.Error()
+		env.Ui().Error(summary.Error)
+		return ExitTemplate
 	}
 
 	if cfgDebug {
 		env.Ui().Say("Debug mode enabled. Builds will not be parallelized.")
 	}
 
+	// Builds run once the builds they depend on have completed, so order
+	// them such that every build comes after the builds it depends on.
+	dependencies := make(map[string][]string)
+	for _, b := range builds {
+		dependencies[b.Name()] = tpl.Builders[b.Name()].DependsOn
+	}
+	builds = sortBuilds(builds, dependencies)
+
+	buildSummaries := make(map[string]*buildSummary)
+	for _, b := range builds {
+		buildSummaries[b.Name()] = summary.addBuild(b.Name())
+	}
+
 	// Compile all the UIs for the builds
 	colors := [5]packer.UiColor{
 		packer.UiColorGreen,
@@ -91,13 +201,48 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		packer.UiColorBlue,
 	}
 
+	// With a log directory, every build has a log file with its Ui output,
+	// which the plugins serving the build also write their logs to.
+	logFiles := make(map[string]string)
+	if cfgLogDir != "" {
+		logDir, err := filepath.Abs(cfgLogDir)
+		if err == nil {
+			err = os.MkdirAll(logDir, 0755)
+		}
+		if err != nil {
+			summary.Error = fmt.Sprintf("Error creating log directory: %s", err)
+			env.Ui().Error(summary.Error)
+			return ExitFailed
+		}
+
+		for _, b := range builds {
+			logFiles[b.Name()] = filepath.Join(logDir, logFileName(b.Name()))
+		}
+	}
+
 	buildUis := make(map[string]packer.Ui)
 	for i, b := range builds {
-		ui := &packer.ColoredUi{
+		var ui packer.Ui
+		ui = &packer.ColoredUi{
 			Color: colors[i%len(colors)],
 			Ui:    env.Ui(),
 		}
 
+		if path, ok := logFiles[b.Name()]; ok {
+			f, err := openLogFile(path)
+			if err != nil {
+				summary.Error = fmt.Sprintf("Error creating log file: %s", err)
+				env.Ui().Error(summary.Error)
+				return ExitFailed
+			}
+			defer f.Close()
+
+			ui = &logUi{
+				Ui:     ui,
+				Logger: log.New(f, "", log.LstdFlags),
+			}
+		}
+
 		buildUis[b.Name()] = ui
 		ui.Say(fmt.Sprintf("%s output will be in this color.", b.Name()))
 	}
@@ -106,18 +251,32 @@ func (c Command) Run(env packer.Environment, args []string) int {
 	env.Ui().Say("")
 
 	log.Printf("Build debug mode: %v", cfgDebug)
+	log.Printf("Dry run: %v", cfgDryRun)
 	log.Printf("Force build: %v", cfgForce)
-
-	// Set the debug and force mode and prepare all the builds
-	for _, b := range builds {
+	log.Printf("Log directory: %s", cfgLogDir)
+	log.Printf("On error: %s", cfgOnError)
+	log.Printf("Parallel builds: %d", cfgParallel)
+	log.Printf("Resume builds: %v", cfgResume)
+	log.Printf("Summary file: %s", summary.path)
+
+	// Every build of this run shares the same run UUID
+	runUUID := uuid.TimeOrderedUUID()
+	log.Printf("Run UUID: %s", runUUID)
+	summary.RunUUID = runUUID
+
+	// Set the debug, force, on-error and resume mode and prepare a build
+	prepare := func(b packer.Build) error {
 		log.Printf("Preparing build: %s", b.Name())
 		b.SetDebug(cfgDebug)
 		b.SetForce(cfgForce)
+		b.SetLogFile(logFiles[b.Name()])
+		b.SetOnError(cfgOnError)
+		b.SetResume(cfgResume)
+		b.SetRunUUID(runUUID)
 
 		warnings, err := b.Prepare()
 		if err != nil {
-			env.Ui().Error(err.Error())
-			return 1
+			return err
 		}
 		if len(warnings) > 0 {
 			ui := buildUis[b.Name()]
@@ -127,13 +286,128 @@ func (c Command) Run(env packer.Environment, args []string) int {
 			}
 			ui.Say("")
 		}
+
+		return nil
+	}
+
+	// Prepare all the builds that don't depend on other builds. The others
+	// are prepared once the builds they depend on have completed, since
+	// their configuration can refer to the artifacts of those builds.
+	for _, b := range builds {
+		if len(dependencies[b.Name()]) > 0 {
+			continue
+		}
+
+		if err := prepare(b); err != nil {
+			buildSummaries[b.Name()].setError(OutcomeInvalid, err)
+			summary.Error = fmt.Sprintf("Build '%s' is invalid.", b.Name())
+			env.Ui().Error(err.Error())
+			return ExitInvalid
+		}
+	}
+
+	// Check the configuration of the builds that depend on other builds
+	// too, before anything runs, with placeholders for the artifacts of
+	// the builds they depend on. This uses separate instances of the
+	// builds, since a build can only be prepared once and the builds
+	// themselves are prepared once the actual artifacts exist. Every
+	// instance starts its own builder, provisioner and post-processor
+	// plugins, which can't be stopped on their own, so this costs one
+	// more set of idle plugin processes for every build that depends on
+	// other builds, all of them stopped when Packer exits.
+	placeholderDir, err := ioutil.TempDir("", "packer-placeholder")
+	if err != nil {
+		summary.Error = fmt.Sprintf("Error creating placeholder directory: %s", err)
+		env.Ui().Error(summary.Error)
+		return ExitFailed
+	}
+	defer os.RemoveAll(placeholderDir)
+
+	for _, b := range builds {
+		deps := dependencies[b.Name()]
+		if len(deps) == 0 {
+			continue
+		}
+
+		log.Printf("Checking build with placeholder artifacts: %s", b.Name())
+		pb, err := tpl.Build(b.Name(), components)
+		if err == nil {
+			pb.SetUpstreamArtifacts(packer.PlaceholderUpstreamArtifacts(deps, placeholderDir))
+			_, err = pb.Prepare()
+		}
+
+		if err != nil {
+			buildSummaries[b.Name()].setError(OutcomeInvalid, err)
+			summary.Error = fmt.Sprintf("Build '%s' is invalid.", b.Name())
+			env.Ui().Error(err.Error())
+			return ExitInvalid
+		}
+	}
+
+	// In a dry run, print what every build would run instead of running it
+	if cfgDryRun {
+		for _, b := range builds {
+			machineUi := &packer.TargettedUi{
+				Target: b.Name(),
+				Ui:     env.Ui(),
+			}
+
+			err := printPlan(buildUis[b.Name()], machineUi, b.Plan(), dependencies[b.Name()])
+			if err != nil {
+				summary.Error = fmt.Sprintf("Error printing the plan of build '%s': %s", b.Name(), err)
+				env.Ui().Error(summary.Error)
+				return ExitFailed
+			}
+		}
+
+		env.Ui().Say("\n==> Dry run finished. No builds were run.")
+		return ExitSuccess
 	}
 
 	// Run all the builds in parallel and wait for them to complete
 	var interruptWg, wg sync.WaitGroup
+	var resultLock sync.Mutex
 	interrupted := false
+	isInterrupted := func() bool {
+		resultLock.Lock()
+		defer resultLock.Unlock()
+		return interrupted
+	}
+	invalid := false
 	artifacts := make(map[string][]packer.Artifact)
 	errors := make(map[string]error)
+	done := make(map[string]chan struct{})
+	for _, b := range builds {
+		done[b.Name()] = make(chan struct{})
+	}
+
+	// If the builds have a timeout, they are all cancelled when it expires
+	// the same way as when they're interrupted.
+	timedOut := false
+	timeoutCh := make(chan struct{})
+	var timer *time.Timer
+	if cfgTimeout > 0 {
+		timer = time.AfterFunc(cfgTimeout, func() {
+			resultLock.Lock()
+			timedOut = true
+			resultLock.Unlock()
+
+			env.Ui().Error(fmt.Sprintf(
+				"Builds timed out after %s. Cancelling them...", cfgTimeout))
+			close(timeoutCh)
+		})
+	}
+
+	// If the number of builds running at once is limited, every running
+	// build holds one of the slots and the others are queued.
+	var slots chan struct{}
+	if cfgParallel > 0 {
+		slots = make(chan struct{}, cfgParallel)
+	}
+
+	// The interrupt handlers stop waiting once all the builds are done
+	finishedCh := make(chan struct{})
+
 	for _, b := range builds {
 		// Increment the waitgroup so we wait for this item to finish properly
 		wg.Add(1)
@@ -142,11 +416,20 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, os.Interrupt)
 		defer signal.Stop(sigCh)
+		interruptWg.Add(1)
 		go func(b packer.Build) {
-			<-sigCh
-			interruptWg.Add(1)
 			defer interruptWg.Done()
+
+			select {
+			case <-sigCh:
+			case <-timeoutCh:
+			case <-finishedCh:
+				return
+			}
+
+			resultLock.Lock()
 			interrupted = true
+			resultLock.Unlock()
 
 			log.Printf("Stopping build: %s", b.Name())
 			b.Cancel()
@@ -158,16 +441,108 @@ func (c Command) Run(env packer.Environment, args []string) int {
 			defer wg.Done()
 
 			name := b.Name()
-			log.Printf("Starting build run: %s", name)
+			defer close(done[name])
 			ui := buildUis[name]
+
+			// Wait for the builds this build depends on, skipping this
+			// build if any of them didn't complete successfully.
+			if deps := dependencies[name]; len(deps) > 0 {
+				upstream := make(map[string][]packer.UpstreamArtifact)
+				for _, dep := range deps {
+					log.Printf("Build '%s' waiting on build: %s", name, dep)
+					<-done[dep]
+
+					resultLock.Lock()
+					depArtifacts, ok := artifacts[dep]
+					resultLock.Unlock()
+
+					if !ok {
+						err := fmt.Errorf(
+							"Skipped because build '%s' didn't complete successfully.", dep)
+						ui.Error(fmt.Sprintf("Build '%s' skipped: %s", name, err))
+
+						resultLock.Lock()
+						errors[name] = err
+						buildSummaries[name].setError(OutcomeSkipped, err)
+						resultLock.Unlock()
+						return
+					}
+
+					upstream[dep] = upstreamArtifacts(depArtifacts)
+				}
+
+				if isInterrupted() {
+					log.Printf("Interrupted, not starting build: %s", name)
+					return
+				}
+
+				b.SetUpstreamArtifacts(upstream)
+				if err := prepare(b); err != nil {
+					ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
+
+					resultLock.Lock()
+					errors[name] = err
+					invalid = true
+					buildSummaries[name].setError(OutcomeInvalid, err)
+					resultLock.Unlock()
+					return
+				}
+			}
+
+			// Wait for a free slot if the number of builds is limited
+			machineUi := &packer.TargettedUi{
+				Target: name,
+				Ui:     env.Ui(),
+			}
+
+			queued := false
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+				default:
+					queued = true
+					ui.Say(fmt.Sprintf(
+						"Build '%s' queued, waiting for one of the other builds to finish.", name))
+					machineUi.Machine("build-state", "queued")
+
+					log.Printf("Build '%s' waiting for a free slot", name)
+					slots <- struct{}{}
+				}
+				defer func() { <-slots }()
+
+				if isInterrupted() {
+					log.Printf("Interrupted, not starting build: %s", name)
+					return
+				}
+			}
+
+			if queued {
+				ui.Say(fmt.Sprintf("Build '%s' started.", name))
+			}
+			machineUi.Machine("build-state", "started")
+
+			log.Printf("Starting build run: %s", name)
+			start := time.Now()
 			runArtifacts, err := b.Run(ui, env.Cache())
 
+			resultLock.Lock()
+			defer resultLock.Unlock()
+
+			buildSummary := buildSummaries[name]
+			buildSummary.Duration = time.Since(start).Seconds()
 			if err != nil {
 				ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
 				errors[name] = err
+
+				if interrupted {
+					buildSummary.setError(OutcomeCancelled, err)
+				} else {
+					buildSummary.setError(OutcomeFailed, err)
+				}
 			} else {
 				ui.Say(fmt.Sprintf("Build '%s' finished.", name))
 				artifacts[name] = runArtifacts
+				buildSummary.setArtifacts(runArtifacts)
 			}
 		}(b)
 
@@ -176,7 +551,7 @@ func (c Command) Run(env packer.Environment, args []string) int {
 			wg.Wait()
 		}
 
-		if interrupted {
+		if isInterrupted() {
 			log.Println("Interrupted, not going to start any more builds.")
 			break
 		}
@@ -186,13 +561,31 @@ func (c Command) Run(env packer.Environment, args []string) int {
 	// if it is interrupted.
 	log.Printf("Waiting on builds to complete...")
 	wg.Wait()
+	if timer != nil {
+		timer.Stop()
+	}
 
 	log.Printf("Builds completed. Waiting on interrupt barrier...")
+	close(finishedCh)
 	interruptWg.Wait()
 
+	// The timer may have fired just before it was stopped, so the flag
+	// is read under the lock it's set with.
+	resultLock.Lock()
+	cancelledByTimeout := timedOut
+	resultLock.Unlock()
+
+	if cancelledByTimeout {
+		summary.Error = fmt.Sprintf("Builds timed out after %s.", cfgTimeout)
+		env.Ui().Say(fmt.Sprintf(
+			"Cleanly cancelled builds after timing out after %s.", cfgTimeout))
+		return ExitTimedOut
+	}
+
 	if interrupted {
+		summary.Error = "Builds were interrupted."
 		env.Ui().Say("Cleanly cancelled builds after being interrupted.")
-		return 1
+		return ExitInterrupted
 	}
 
 	if len(errors) > 0 {
@@ -262,10 +655,256 @@ func (c Command) Run(env packer.Environment, args []string) int {
 
 	if len(errors) > 0 {
 		// If any errors occurred, exit with a non-zero exit status
-		return 1
+		summary.Error = "Some builds didn't complete successfully."
+		if invalid {
+			return ExitInvalid
+		}
+
+		return ExitFailed
+	}
+
+	return ExitSuccess
+}
+
+// printPlan prints what a build would run, both for humans and as
+// machine-readable output, along with the builds it depends on.
+func printPlan(ui, machineUi packer.Ui, plan packer.BuildPlan, deps []string) error {
+	ui.Say(fmt.Sprintf("Plan for build '%s':", plan.Name))
+	if len(deps) > 0 {
+		ui.Say(fmt.Sprintf("  Runs after the builds: %s", strings.Join(deps, ", ")))
+		machineUi.Machine("plan", append([]string{"depends-on"}, deps...)...)
+	}
+
+	config, err := planConfig(plan.BuilderConfig, "")
+	if err != nil {
+		return err
+	}
+
+	machineUi.Machine("plan", "builder", plan.BuilderType, config)
+	ui.Say(fmt.Sprintf("  Builder: %s", plan.BuilderType))
+	config, _ = planConfig(plan.BuilderConfig, "    ")
+	ui.Say("    " + config)
+
+	if len(plan.Provisioners) == 0 {
+		ui.Say("  Provisioners: <none>")
+	} else {
+		ui.Say("  Provisioners:")
+	}
+
+	for i, p := range plan.Provisioners {
+		config, err := planConfig(p.Config, "")
+		if err != nil {
+			return err
+		}
+
+		machineUi.Machine("plan", "provisioner", strconv.FormatInt(int64(i), 10),
+			p.Type, p.PauseBefore.String(), p.Timeout.String(), config)
+
+		var options []string
+		if p.PauseBefore > 0 {
+			options = append(options, fmt.Sprintf("pause before %s", p.PauseBefore))
+		}
+		if p.Timeout > 0 {
+			options = append(options, fmt.Sprintf("timeout %s", p.Timeout))
+		}
+
+		if len(options) > 0 {
+			ui.Say(fmt.Sprintf("    %s (%s)", p.Type, strings.Join(options, ", ")))
+		} else {
+			ui.Say(fmt.Sprintf("    %s", p.Type))
+		}
+
+		config, _ = planConfig(p.Config, "      ")
+		ui.Say("      " + config)
+	}
+
+	if len(plan.PostProcessors) == 0 {
+		ui.Say("  Post-processors: <none>")
+	} else {
+		ui.Say("  Post-processors:")
+	}
+
+	for i, ppSeq := range plan.PostProcessors {
+		ui.Say(fmt.Sprintf("    Chain %d:", i+1))
+		for j, pp := range ppSeq {
+			config, err := planConfig(pp.Config, "")
+			if err != nil {
+				return err
+			}
+
+			machineUi.Machine("plan", "post-processor",
+				strconv.FormatInt(int64(i), 10), strconv.FormatInt(int64(j), 10),
+				pp.Type, strconv.FormatBool(pp.KeepInputArtifact), config)
+
+			if pp.KeepInputArtifact {
+				ui.Say(fmt.Sprintf("      %s (keeps the input artifact)", pp.Type))
+			} else {
+				ui.Say(fmt.Sprintf("      %s", pp.Type))
+			}
+
+			config, _ = planConfig(pp.Config, "        ")
+			ui.Say("        " + config)
+		}
+	}
+
+	machineUi.Machine("plan", "end")
+	ui.Say("")
+	return nil
+}
+
+// planConfig encodes a configuration of a plan as JSON, on a single line
+// if the prefix is empty and indented otherwise. The characters that JSON
+// escapes for HTML are kept as they are, so that "<Filtered>" is readable.
+func planConfig(config interface{}, prefix string) (string, error) {
+	var data []byte
+	var err error
+	if prefix == "" {
+		data, err = json.Marshal(config)
+	} else {
+		data, err = json.MarshalIndent(config, prefix, "  ")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return unescapeHTML(data), nil
+}
+
+// htmlEscapes are the escapes that encoding/json uses for the characters
+// that are special in HTML.
+var htmlEscapes = map[string]byte{`\u003c`: '<', `\u003e`: '>', `\u0026`: '&'}
+
+// unescapeHTML reverts the escapes of encoding/json for the characters
+// that are special in HTML. The escapes are only replaced where they
+// start, so that a string with an escaped backslash followed by "u003c"
+// is kept as it is.
+func unescapeHTML(data []byte) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\\' || i+1 == len(data) {
+			buf.WriteByte(data[i])
+			continue
+		}
+
+		if i+6 <= len(data) {
+			if c, ok := htmlEscapes[string(data[i:i+6])]; ok {
+				buf.WriteByte(c)
+				i += 5
+				continue
+			}
+		}
+
+		// Any other escape is kept, including the escaped character
+		buf.Write(data[i : i+2])
+		i++
+	}
+
+	return buf.String()
+}
+
+// logFileName returns the name of the log file of a build, replacing the
+// characters that can't be in file names. The prefix keeps the log files
+// of builds apart from packer.log, which is the log of the whole run.
+func logFileName(name string) string {
+	return "build-" + logFileNameReplacer.Replace(name) + ".log"
+}
+
+var logFileNameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+
+// openLogFile creates the log file of a build, truncating an existing one.
+// The plugins of the build append their logs to the same file, so it is
+// appended to as well, or the writes would overwrite each other.
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+}
+
+// logUi is a Ui that also writes the output of a build to its log file.
+// Sensitive values are scrubbed, like they are from the other logs.
+type logUi struct {
+	packer.Ui
+	Logger *log.Logger
+}
+
+func (u *logUi) Ask(query string) (string, error) {
+	u.Logger.Printf("ui: ask: %s", packer.ScrubSensitive(query))
+	return u.Ui.Ask(query)
+}
+
+func (u *logUi) AskCancel(query string, cancelCh <-chan struct{}) (string, error) {
+	u.Logger.Printf("ui: ask: %s", packer.ScrubSensitive(query))
+	return packer.AskCancel(u.Ui, query, cancelCh)
+}
+
+func (u *logUi) Say(message string) {
+	u.Logger.Printf("ui: %s", packer.ScrubSensitive(message))
+	u.Ui.Say(message)
+}
+
+func (u *logUi) Message(message string) {
+	u.Logger.Printf("ui: %s", packer.ScrubSensitive(message))
+	u.Ui.Message(message)
+}
+
+func (u *logUi) Error(message string) {
+	u.Logger.Printf("ui error: %s", packer.ScrubSensitive(message))
+	u.Ui.Error(message)
+}
+
+// sortBuilds orders the builds such that every build comes after the
+// builds it depends on, keeping the order of the builds otherwise. The
+// template makes sure that there are no dependency cycles.
+func sortBuilds(builds []packer.Build, dependencies map[string][]string) []packer.Build {
+	result := make([]packer.Build, 0, len(builds))
+	added := make(map[string]bool)
+	for len(result) < len(builds) {
+		progress := false
+		for _, b := range builds {
+			if added[b.Name()] {
+				continue
+			}
+
+			ready := true
+			for _, dep := range dependencies[b.Name()] {
+				if !added[dep] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				result = append(result, b)
+				added[b.Name()] = true
+				progress = true
+				break
+			}
+		}
+
+		if !progress {
+			panic("build dependencies can't be satisfied")
+		}
+	}
+
+	return result
+}
+
+// upstreamArtifacts converts the artifacts of a build into the form that
+// is available to the builds depending on it.
+func upstreamArtifacts(artifacts []packer.Artifact) []packer.UpstreamArtifact {
+	result := make([]packer.UpstreamArtifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if artifact == nil {
+			continue
+		}
+
+		result = append(result, packer.UpstreamArtifact{
+			BuilderId: artifact.BuilderId(),
+			Id:        artifact.Id(),
+			Files:     artifact.Files(),
+		})
 	}
 
-	return 0
+	return result
 }
 
 func (Command) Synopsis() string {
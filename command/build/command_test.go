@@ -2,8 +2,17 @@ package build
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func testEnvironment() packer.Environment {
@@ -28,7 +37,7 @@ func TestCommand_Implements(t *testing.T) {
 func TestCommand_Run_NoArgs(t *testing.T) {
 	command := new(Command)
 	result := command.Run(testEnvironment(), make([]string, 0))
-	if result != 1 {
+	if result != ExitUsage {
 		t.Fatalf("bad: %d", result)
 	}
 }
@@ -38,7 +47,7 @@ func TestCommand_Run_MoreThanOneArg(t *testing.T) {
 
 	args := []string{"one", "two"}
 	result := command.Run(testEnvironment(), args)
-	if result != 1 {
+	if result != ExitUsage {
 		t.Fatalf("bad: %d", result)
 	}
 }
@@ -48,7 +57,581 @@ func TestCommand_Run_MissingFile(t *testing.T) {
 
 	args := []string{"i-better-not-exist"}
 	result := command.Run(testEnvironment(), args)
-	if result != 1 {
+	if result != ExitTemplate {
+		t.Fatalf("bad: %d", result)
+	}
+}
+
+func TestSortBuilds(t *testing.T) {
+	data := `{
+	"builders": [
+		{"name": "a", "type": "foo", "depends_on": ["c"]},
+		{"name": "b", "type": "foo"},
+		{"name": "c", "type": "foo", "depends_on": ["b"]},
+		{"name": "d", "type": "foo"}
+	]
+}`
+
+	tpl, err := packer.ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	components := &packer.ComponentFinder{
+		Builder: func(string) (packer.Builder, error) { return new(packer.MockBuilder), nil },
+	}
+
+	builds := make([]packer.Build, 0)
+	dependencies := make(map[string][]string)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		b, err := tpl.Build(name, components)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		builds = append(builds, b)
+		dependencies[name] = tpl.Builders[name].DependsOn
+	}
+
+	var names []string
+	for _, b := range sortBuilds(builds, dependencies) {
+		names = append(names, b.Name())
+	}
+
+	expected := []string{"b", "c", "a", "d"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+}
+
+func TestUpstreamArtifacts(t *testing.T) {
+	artifacts := []packer.Artifact{&packer.MockArtifact{}, nil}
+	result := upstreamArtifacts(artifacts)
+
+	expected := []packer.UpstreamArtifact{
+		{BuilderId: "bid", Id: "id", Files: []string{"a", "b"}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+// testParallelBuilder is a builder that keeps track of how many of its
+// builds run at once.
+type testParallelBuilder struct {
+	l       *sync.Mutex
+	running *int
+	max     *int
+}
+
+func (b *testParallelBuilder) Prepare(...interface{}) ([]string, error) {
+	return nil, nil
+}
+
+func (b *testParallelBuilder) Run(packer.Ui, packer.Hook, packer.Cache) (packer.Artifact, error) {
+	b.l.Lock()
+	*b.running++
+	if *b.running > *b.max {
+		*b.max = *b.running
+	}
+	b.l.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	b.l.Lock()
+	*b.running--
+	b.l.Unlock()
+
+	return new(packer.MockArtifact), nil
+}
+
+func (b *testParallelBuilder) Cancel() {}
+
+func TestCommand_Run_Parallel(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`{"builders": [
+		{"name": "a", "type": "test"},
+		{"name": "b", "type": "test"},
+		{"name": "c", "type": "test"}
+	]}`))
+	tf.Close()
+
+	var l sync.Mutex
+	var running, max int
+	out := new(bytes.Buffer)
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: out,
+	}
+	config.Components.Builder = func(string) (packer.Builder, error) {
+		return &testParallelBuilder{&l, &running, &max}, nil
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	command := new(Command)
+	result := command.Run(env, []string{"-parallel=1", tf.Name()})
+	if result != 0 {
+		t.Fatalf("bad: %d\n\n%s", result, out.String())
+	}
+
+	if max != 1 {
+		t.Fatalf("bad: %d", max)
+	}
+
+	if !strings.Contains(out.String(), "queued") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestCommand_Run_DryRun(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`{
+	"builders": [
+		{"name": "a", "type": "test", "ssh_password": "hunter2"},
+		{"name": "b", "type": "test", "depends_on": ["a"]}
+	],
+	"provisioners": [
+		{"type": "test", "pause_before": "5s", "only": ["b"]}
+	]
+}`))
+	tf.Close()
+
+	builder := new(packer.MockBuilder)
+	provisioner := new(packer.MockProvisioner)
+	out := new(bytes.Buffer)
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: out,
+	}
+	config.Components.Builder = func(string) (packer.Builder, error) {
+		return builder, nil
+	}
+	config.Components.Provisioner = func(string) (packer.Provisioner, error) {
+		return provisioner, nil
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	command := new(Command)
+	result := command.Run(env, []string{"-dry-run", tf.Name()})
+	if result != 0 {
+		t.Fatalf("bad: %d\n\n%s", result, out.String())
+	}
+
+	if builder.RunCalled || provisioner.ProvCalled {
+		t.Fatal("nothing should run")
+	}
+
+	for _, expected := range []string{
+		"Plan for build 'a'",
+		"Plan for build 'b'",
+		"Runs after the builds: a",
+		packer.SensitiveFilterText,
+		"test (pause before 5s)",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("missing %q: %s", expected, out.String())
+		}
+	}
+
+	if strings.Contains(out.String(), "hunter2") {
+		t.Fatalf("secret in output: %s", out.String())
+	}
+}
+
+func TestPlanConfig(t *testing.T) {
+	cases := []struct {
+		Input  interface{}
+		Output string
+	}{
+		{"<Filtered>", `"<Filtered>"`},
+		{"a && b", `"a && b"`},
+		{`\u003c`, `"\\u003c"`},
+		{`\<`, `"\\<"`},
+		{"\n", `"\n"`},
+	}
+
+	for _, tc := range cases {
+		result, err := planConfig(tc.Input, "")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if result != tc.Output {
+			t.Fatalf("bad: %#v %s", tc.Input, result)
+		}
+	}
+}
+
+func TestCommand_Run_LogDir(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`{"builders": [{"name": "a/b", "type": "test"}]}`))
+	tf.Close()
+
+	logDir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(logDir)
+
+	builder := new(packer.MockBuilder)
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	config.Components.Builder = func(string) (packer.Builder, error) {
+		return builder, nil
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	command := new(Command)
+	result := command.Run(env, []string{"-log-dir=" + logDir, tf.Name()})
+	if result != 0 {
+		t.Fatalf("bad: %d", result)
+	}
+
+	path := filepath.Join(logDir, "build-a_b.log")
+	if packer.ConfigLogFile(builder.PrepareConfig...) != path {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.Contains(string(data), "ui: Build 'a/b' finished.") {
+		t.Fatalf("bad: %s", data)
+	}
+}
+
+func TestOpenLogFile(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	// An existing log file is truncated
+	tf.WriteString("old\n")
+	tf.Close()
+
+	core, err := openLogFile(tf.Name())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer core.Close()
+
+	// The plugins open the log file like this, see the plugin client
+	plugin, err := os.OpenFile(tf.Name(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer plugin.Close()
+
+	core.WriteString("core 1\n")
+	plugin.WriteString("plugin 1\n")
+	core.WriteString("core 2\n")
+	plugin.WriteString("plugin 2\n")
+
+	data, err := ioutil.ReadFile(tf.Name())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "core 1\nplugin 1\ncore 2\nplugin 2\n"
+	if string(data) != expected {
+		t.Fatalf("bad: %q", data)
+	}
+}
+
+func TestCommand_Run_Summary(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`{"builders": [
+		{"name": "a", "type": "ok"},
+		{"name": "b", "type": "fail"},
+		{"name": "c", "type": "ok", "depends_on": ["b"]}
+	]}`))
+	tf.Close()
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	config.Components.Builder = func(n string) (packer.Builder, error) {
+		return &packer.MockBuilder{
+			ArtifactId:   "ami-123",
+			RunErrResult: n == "fail",
+		}, nil
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := filepath.Join(dir, "summary.json")
+	command := new(Command)
+	result := command.Run(env, []string{"-summary=" + path, tf.Name()})
+	if result != ExitFailed {
+		t.Fatalf("bad: %d", result)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var summary runSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if summary.ExitCode != ExitFailed {
+		t.Fatalf("bad: %d", summary.ExitCode)
+	}
+	if summary.RunUUID == "" {
+		t.Fatal("should have a run UUID")
+	}
+	if len(summary.Builds) != 3 {
+		t.Fatalf("bad: %s", data)
+	}
+
+	outcomes := make(map[string]*buildSummary)
+	for _, b := range summary.Builds {
+		outcomes[b.Name] = b
+	}
+
+	a := outcomes["a"]
+	if a.Outcome != OutcomeSucceeded || a.Error != "" {
+		t.Fatalf("bad: %#v", a)
+	}
+	if len(a.Artifacts) != 1 || a.Artifacts[0].Id != "ami-123" {
+		t.Fatalf("bad: %#v", a.Artifacts)
+	}
+
+	b := outcomes["b"]
+	if b.Outcome != OutcomeFailed || b.Error != "foo" {
+		t.Fatalf("bad: %#v", b)
+	}
+
+	c := outcomes["c"]
+	if c.Outcome != OutcomeSkipped || !strings.Contains(c.Error, "build 'b'") {
+		t.Fatalf("bad: %#v", c)
+	}
+}
+
+func TestCommand_Run_Invalid(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`{"builders": [{"name": "a", "type": "test"}]}`))
+	tf.Close()
+
+	builder := &packer.MockBuilder{PrepareErr: errors.New("invalid")}
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	config.Components.Builder = func(string) (packer.Builder, error) {
+		return builder, nil
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	command := new(Command)
+	result := command.Run(env, []string{tf.Name()})
+	if result != ExitInvalid {
+		t.Fatalf("bad: %d", result)
+	}
+
+	if builder.RunCalled {
+		t.Fatal("should not run")
+	}
+}
+
+func TestCommand_Run_InvalidDependent(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`{"builders": [
+		{"name": "a", "type": "ok"},
+		{"name": "b", "type": "invalid", "depends_on": ["a"]}
+	]}`))
+	tf.Close()
+
+	upstream := &packer.MockBuilder{ArtifactId: "ami-123"}
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	config.Components.Builder = func(n string) (packer.Builder, error) {
+		if n == "invalid" {
+			return &packer.MockBuilder{PrepareErr: errors.New("invalid")}, nil
+		}
+
+		return upstream, nil
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	command := new(Command)
+	result := command.Run(env, []string{tf.Name()})
+	if result != ExitInvalid {
+		t.Fatalf("bad: %d", result)
+	}
+
+	// The dependent build is invalid, so nothing should have run
+	if upstream.RunCalled {
+		t.Fatal("should not run")
+	}
+}
+
+func TestCommand_Run_OnErrorInvalid(t *testing.T) {
+	command := new(Command)
+	result := command.Run(testEnvironment(), []string{"-on-error=foo", "foo.json"})
+	if result != ExitUsage {
+		t.Fatalf("bad: %d", result)
+	}
+}
+
+func TestCommand_Run_ForceResume(t *testing.T) {
+	command := new(Command)
+	result := command.Run(testEnvironment(), []string{"-force", "-resume", "foo.json"})
+	if result != ExitUsage {
+		t.Fatalf("bad: %d", result)
+	}
+}
+
+func TestCommand_Run_ParallelNegative(t *testing.T) {
+	command := new(Command)
+	result := command.Run(testEnvironment(), []string{"-parallel=-1", "foo.json"})
+	if result != ExitUsage {
+		t.Fatalf("bad: %d", result)
+	}
+}
+
+// testCancelBuilder is a builder whose builds run until they're cancelled.
+type testCancelBuilder struct {
+	cancelCh chan struct{}
+}
+
+func (b *testCancelBuilder) Prepare(...interface{}) ([]string, error) {
+	return nil, nil
+}
+
+func (b *testCancelBuilder) Run(packer.Ui, packer.Hook, packer.Cache) (packer.Artifact, error) {
+	<-b.cancelCh
+	return nil, errors.New("cancelled")
+}
+
+func (b *testCancelBuilder) Cancel() {
+	close(b.cancelCh)
+}
+
+func TestCommand_Run_Timeout(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`{"builders": [
+		{"name": "a", "type": "test"},
+		{"name": "b", "type": "test"}
+	]}`))
+	tf.Close()
+
+	out := new(bytes.Buffer)
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: out,
+	}
+	config.Components.Builder = func(string) (packer.Builder, error) {
+		return &testCancelBuilder{make(chan struct{})}, nil
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	command := new(Command)
+	result := command.Run(env, []string{"-timeout=50ms", tf.Name()})
+	if result != ExitTimedOut {
+		t.Fatalf("bad: %d\n\n%s", result, out.String())
+	}
+
+	if !strings.Contains(out.String(), "timing out") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestCommand_Run_TimeoutInvalid(t *testing.T) {
+	command := new(Command)
+	result := command.Run(testEnvironment(), []string{"-timeout=-1m", "foo.json"})
+	if result != ExitUsage {
+		t.Fatalf("bad: %d", result)
+	}
+
+	result = command.Run(testEnvironment(), []string{"-timeout=foo", "foo.json"})
+	if result != ExitUsage {
 		t.Fatalf("bad: %d", result)
 	}
 }
@@ -9,10 +9,21 @@ Usage: packer build [options] TEMPLATE
 Options:
 
   -debug                     Debug mode enabled for builds
+  -dry-run                   Print what the builds would run without running them.
+                             Only user variables are interpolated, other template
+                             functions are printed as they are
   -force                     Force a build to continue if artifacts exist, deletes existing artifacts
+  -log-dir=DIR               Write a log file for every build and for the
+                             whole run into this directory
   -machine-readable          Machine-readable output
   -except=foo,bar,baz        Build all builds other than these
   -only=foo,bar,baz          Only build the given builds by name
+  -on-error=cleanup          What to do when a build fails: cleanup, abort
+                             to keep its resources, or ask
+  -parallel=N                Run at most N builds at once, queueing the others
+  -resume                    Resume builds from their checkpoints
+  -summary=FILE              Write a JSON summary of the run to this file
+  -timeout=DURATION          Cancel the builds if they take longer than this
   -var 'key=value'           Variable for templates, can be used multiple times.
   -var-file=path             JSON file containing user variables.
 `
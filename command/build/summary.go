@@ -0,0 +1,104 @@
+package build
+
+import (
+	"encoding/json"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"time"
+)
+
+// The outcomes of a build in the summary of a run.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeInvalid   = "invalid"
+	OutcomeSkipped   = "skipped"
+	OutcomeCancelled = "cancelled"
+	OutcomeNotRun    = "not-run"
+)
+
+// runSummary is the summary of a run of the build command, which the
+// "-summary" flag writes as JSON when the command exits.
+type runSummary struct {
+	ExitCode int             `json:"exit_code"`
+	Error    string          `json:"error,omitempty"`
+	RunUUID  string          `json:"run_uuid,omitempty"`
+	Duration float64         `json:"duration_seconds"`
+	Builds   []*buildSummary `json:"builds"`
+
+	path  string
+	start time.Time
+}
+
+// buildSummary is the summary of a single build of a run.
+type buildSummary struct {
+	Name      string            `json:"name"`
+	Outcome   string            `json:"outcome"`
+	Error     string            `json:"error,omitempty"`
+	Duration  float64           `json:"duration_seconds"`
+	Artifacts []artifactSummary `json:"artifacts"`
+}
+
+type artifactSummary struct {
+	BuilderId string   `json:"builder_id"`
+	Id        string   `json:"id"`
+	String    string   `json:"string"`
+	Files     []string `json:"files"`
+}
+
+// addBuild adds a build to the summary, which hasn't run yet.
+func (s *runSummary) addBuild(name string) *buildSummary {
+	result := &buildSummary{
+		Name:      name,
+		Outcome:   OutcomeNotRun,
+		Artifacts: make([]artifactSummary, 0),
+	}
+
+	s.Builds = append(s.Builds, result)
+	return result
+}
+
+// write writes the summary as JSON to its path. The error texts are
+// scrubbed of sensitive values, like the logs are.
+func (s *runSummary) write(exitCode int) error {
+	s.ExitCode = exitCode
+	s.Error = packer.ScrubSensitive(s.Error)
+	s.Duration = time.Since(s.start).Seconds()
+	if s.Builds == nil {
+		s.Builds = make([]*buildSummary, 0)
+	}
+
+	for _, b := range s.Builds {
+		b.Error = packer.ScrubSensitive(b.Error)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.path, append(data, '\n'), 0644)
+}
+
+// setError sets the outcome of a build that didn't complete successfully.
+func (b *buildSummary) setError(outcome string, err error) {
+	b.Outcome = outcome
+	b.Error = err.Error()
+}
+
+// setArtifacts sets the artifacts of a build that completed successfully.
+func (b *buildSummary) setArtifacts(artifacts []packer.Artifact) {
+	b.Outcome = OutcomeSucceeded
+	for _, artifact := range artifacts {
+		if artifact == nil {
+			continue
+		}
+
+		b.Artifacts = append(b.Artifacts, artifactSummary{
+			BuilderId: artifact.BuilderId(),
+			Id:        artifact.Id(),
+			String:    artifact.String(),
+			Files:     artifact.Files(),
+		})
+	}
+}
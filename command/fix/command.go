@@ -5,9 +5,10 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	yamlutil "github.com/mitchellh/packer/common/yaml"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
 	"log"
-	"os"
 	"strings"
 )
 
@@ -31,24 +32,25 @@ func (c Command) Run(env packer.Environment, args []string) int {
 	}
 
 	// Read the file for decoding
-	tplF, err := os.Open(args[0])
+	data, err := ioutil.ReadFile(args[0])
 	if err != nil {
 		env.Ui().Error(fmt.Sprintf("Error opening template: %s", err))
 		return 1
 	}
-	defer tplF.Close()
 
-	// Decode the JSON into a generic map structure
+	// Decode the JSON or YAML into a generic map structure
 	var templateData map[string]interface{}
-	decoder := json.NewDecoder(tplF)
-	if err := decoder.Decode(&templateData); err != nil {
+	isYAML := yamlutil.IsYAML(args[0], data)
+	if isYAML {
+		err = yamlutil.Unmarshal(data, &templateData)
+	} else {
+		err = json.Unmarshal(data, &templateData)
+	}
+	if err != nil {
 		env.Ui().Error(fmt.Sprintf("Error parsing template: %s", err))
 		return 1
 	}
 
-	// Close the file since we're done with that
-	tplF.Close()
-
 	input := templateData
 	for _, name := range FixerOrder {
 		var err error
@@ -65,6 +67,19 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		}
 	}
 
+	// YAML templates are fixed into YAML. Comments in the template are
+	// lost, since the template is decoded.
+	if isYAML {
+		output, err := yamlutil.Marshal(input)
+		if err != nil {
+			env.Ui().Error(fmt.Sprintf("Error encoding: %s", err))
+			return 1
+		}
+
+		env.Ui().Say(strings.TrimSpace(string(output)))
+		return 0
+	}
+
 	var output bytes.Buffer
 	encoder := json.NewEncoder(&output)
 	if err := encoder.Encode(input); err != nil {
@@ -3,8 +3,9 @@ package fix
 const helpString = `
 Usage: packer fix [options] TEMPLATE
 
-  Reads the JSON template and attempts to fix known backwards
-  incompatibilities. The fixed template will be outputted to standard out.
+  Reads the JSON or YAML template and attempts to fix known backwards
+  incompatibilities. The fixed template will be outputted to standard out,
+  in the same format as the template.
 
   If the template cannot be fixed due to an error, the command will exit
   with a non-zero exit status. Error messages will appear on standard error.
@@ -1,6 +1,7 @@
 package inspect
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/mitchellh/packer/packer"
@@ -89,10 +90,16 @@ func (c Command) Run(env packer.Environment, args []string) int {
 				continue
 			}
 
+			// Never show the defaults of sensitive variables
+			defaultValue := v.Default
+			if v.Sensitive {
+				defaultValue = packer.SensitiveFilterText
+			}
+
 			padding := strings.Repeat(" ", max-len(k))
-			output := fmt.Sprintf("  %s%s = %s", k, padding, v.Default)
+			output := fmt.Sprintf("  %s%s = %s", k, padding, defaultValue)
 
-			ui.Machine("template-variable", k, v.Default, "0")
+			ui.Machine("template-variable", k, defaultValue, "0")
 			ui.Say(output)
 		}
 	}
@@ -126,6 +133,22 @@ func (c Command) Run(env packer.Environment, args []string) int {
 			ui.Machine("template-builder", k, v.Type)
 			ui.Say(output)
 
+			// Show the effective configuration of builders that are based
+			// on another builder, since it isn't in the template as such.
+			if v.Base != "" {
+				config, err := json.Marshal(v.RawConfig)
+				if err != nil {
+					ui.Error(fmt.Sprintf(
+						"Error encoding config of builder '%s': %s", k, err))
+					return 1
+				}
+
+				ui.Machine("template-builder-config", k, v.Base, string(config))
+
+				config, _ = json.MarshalIndent(v.RawConfig, "    ", "  ")
+				ui.Say(fmt.Sprintf("    based on '%s', effective config:", v.Base))
+				ui.Say("    " + string(config))
+			}
 		}
 	}
 
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	cmdcommon "github.com/mitchellh/packer/common/command"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
 	"log"
+	"os"
 	"strings"
 )
 
@@ -79,8 +81,21 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		return 1
 	}
 
-	// Check the configuration of all builds
+	// The configuration of builds that depend on other builds can refer to
+	// the artifacts of those builds, which are stood in for by placeholders.
+	placeholderDir, err := ioutil.TempDir("", "packer-placeholder")
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Error creating placeholder directory: %s", err))
+		return 1
+	}
+	defer os.RemoveAll(placeholderDir)
+
+	// Check the configuration of all builds.
 	for _, b := range builds {
+		if deps := tpl.Builders[b.Name()].DependsOn; len(deps) > 0 {
+			b.SetUpstreamArtifacts(packer.PlaceholderUpstreamArtifacts(deps, placeholderDir))
+		}
+
 		log.Printf("Preparing build: %s", b.Name())
 		warns, err := b.Prepare()
 		if len(warns) > 0 {
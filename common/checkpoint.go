@@ -0,0 +1,357 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CheckpointFile is the name of the file within the output directory of a
+// build that holds the checkpoint of the build.
+const CheckpointFile = "packer-checkpoint.json"
+
+// ResumableStep is a step that does something when a build is resumed
+// after the step completed. Steps that completed aren't run again, so
+// Resume is called instead in order to restore what the cleanup of the
+// step needs, or resources that don't outlive a run of Packer, such as
+// an HTTP server. Steps that aren't resumable are skipped.
+type ResumableStep interface {
+	multistep.Step
+
+	Resume(multistep.StateBag) multistep.StepAction
+}
+
+// CheckpointExists returns whether there's a checkpoint in the given
+// output directory that a build can resume from.
+func CheckpointExists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, CheckpointFile))
+	return err == nil
+}
+
+// NewResumableRunner returns the runner for the steps of a builder like
+// NewRunner does, also saving a checkpoint of the build in the output
+// directory after every step that completes. The checkpoint is made of
+// the values that the steps put in the state, which must be strings,
+// numbers or booleans to be saved, since the rest can't outlive a run
+// of Packer. If resuming is enabled, the build resumes from the
+// checkpoint if there's one.
+func NewResumableRunner(
+	steps []multistep.Step, config PackerConfig, ui packer.Ui,
+	leftovers LeftoverFunc, outputDir string) multistep.Runner {
+	c := &checkpointer{
+		path:    filepath.Join(outputDir, CheckpointFile),
+		builder: config.PackerBuilderType,
+		resume:  config.PackerResume,
+		ui:      ui,
+	}
+
+	wrapped := make([]multistep.Step, len(steps))
+	for i, step := range steps {
+		c.steps = append(c.steps, fmt.Sprintf("%T", step))
+		wrapped[i] = &checkpointStep{Step: step, index: i, checkpointer: c}
+	}
+
+	// A resumed build keeps its resources if it fails again, unless told
+	// otherwise, so that it can be resumed again.
+	if config.PackerResume && config.PackerOnError == "" {
+		config.PackerOnError = packer.OnErrorAbort
+	}
+
+	return &checkpointRunner{
+		Runner:       NewRunner(wrapped, config, ui, leftovers),
+		checkpointer: c,
+	}
+}
+
+// checkpoint is what is saved in the checkpoint file.
+type checkpoint struct {
+	Builder string
+
+	// The types of the steps, to verify that the checkpoint is resumed
+	// by the same build.
+	Steps []string
+
+	// The number of steps that completed.
+	Completed int
+
+	State map[string]checkpointValue
+}
+
+// checkpointValue is a value of the state along with its type, since
+// steps expect the exact type they put in the state.
+type checkpointValue struct {
+	Type  string
+	Value json.RawMessage
+}
+
+// checkpointer saves and loads the checkpoint of a build, keeping track
+// of the keys of the state that the steps put values in.
+type checkpointer struct {
+	path    string
+	builder string
+	resume  bool
+	steps   []string
+	ui      packer.Ui
+
+	// The number of steps that completed before resuming
+	completed int
+
+	l     sync.Mutex
+	state multistep.StateBag
+	keys  map[string]bool
+}
+
+// load restores the state from the checkpoint, if there's one, returning
+// whether it did.
+func (c *checkpointer) load() (bool, error) {
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error reading checkpoint: %s", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return false, fmt.Errorf("Error reading checkpoint %s: %s", c.path, err)
+	}
+
+	if cp.Builder != c.builder || !stringsEqual(cp.Steps, c.steps) {
+		return false, fmt.Errorf(
+			"Checkpoint %s is from a different build and can't be resumed.\n"+
+				"Build with -force to start over.", c.path)
+	}
+
+	for k, v := range cp.State {
+		value, err := v.decode()
+		if err != nil {
+			return false, fmt.Errorf("Error reading checkpoint %s: '%s': %s", c.path, k, err)
+		}
+
+		c.Put(k, value)
+	}
+
+	c.completed = cp.Completed
+	return true, nil
+}
+
+// save saves the checkpoint after the given number of steps completed.
+// Nothing is saved until the output directory exists.
+func (c *checkpointer) save(completed int) error {
+	if _, err := os.Stat(filepath.Dir(c.path)); err != nil {
+		return nil
+	}
+
+	cp := checkpoint{
+		Builder:   c.builder,
+		Steps:     c.steps,
+		Completed: completed,
+		State:     make(map[string]checkpointValue),
+	}
+
+	c.l.Lock()
+	for k, _ := range c.keys {
+		v, ok := newCheckpointValue(c.state.Get(k))
+		if !ok {
+			log.Printf("Not saving '%s' in the checkpoint, its type isn't supported", k)
+			continue
+		}
+
+		cp.State[k] = v
+	}
+	c.l.Unlock()
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write the checkpoint to a temporary file first so that it is never
+	// left half written.
+	tempPath := c.path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, c.path)
+}
+
+// Get, GetOk and Put make the checkpointer the state bag that is given to
+// the steps, so that it knows the keys of the values they put in it.
+func (c *checkpointer) Get(k string) interface{} {
+	return c.state.Get(k)
+}
+
+func (c *checkpointer) GetOk(k string) (interface{}, bool) {
+	return c.state.GetOk(k)
+}
+
+func (c *checkpointer) Put(k string, v interface{}) {
+	switch k {
+	case "error", multistep.StateCancelled, multistep.StateHalted:
+	default:
+		c.l.Lock()
+		c.keys[k] = true
+		c.l.Unlock()
+	}
+
+	c.state.Put(k, v)
+}
+
+func newCheckpointValue(v interface{}) (checkpointValue, bool) {
+	var t string
+	switch v.(type) {
+	case string:
+		t = "string"
+	case bool:
+		t = "bool"
+	case int:
+		t = "int"
+	case uint:
+		t = "uint"
+	case float64:
+		t = "float64"
+	case []string:
+		t = "[]string"
+	default:
+		return checkpointValue{}, false
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return checkpointValue{}, false
+	}
+
+	return checkpointValue{Type: t, Value: data}, true
+}
+
+func (v checkpointValue) decode() (interface{}, error) {
+	var err error
+	switch v.Type {
+	case "string":
+		var result string
+		err = json.Unmarshal(v.Value, &result)
+		return result, err
+	case "bool":
+		var result bool
+		err = json.Unmarshal(v.Value, &result)
+		return result, err
+	case "int":
+		var result int
+		err = json.Unmarshal(v.Value, &result)
+		return result, err
+	case "uint":
+		var result uint
+		err = json.Unmarshal(v.Value, &result)
+		return result, err
+	case "float64":
+		var result float64
+		err = json.Unmarshal(v.Value, &result)
+		return result, err
+	case "[]string":
+		var result []string
+		err = json.Unmarshal(v.Value, &result)
+		return result, err
+	default:
+		return nil, fmt.Errorf("unknown type: %s", v.Type)
+	}
+}
+
+// checkpointRunner runs the steps with the checkpointer as the state,
+// resuming from the checkpoint first if resuming is enabled. The
+// checkpoint is removed once the build succeeds.
+type checkpointRunner struct {
+	multistep.Runner
+	checkpointer *checkpointer
+}
+
+func (r *checkpointRunner) Run(state multistep.StateBag) {
+	c := r.checkpointer
+	c.state = state
+	c.keys = make(map[string]bool)
+
+	if c.resume {
+		ok, err := c.load()
+		if err != nil {
+			state.Put("error", err)
+			return
+		}
+
+		if ok {
+			c.ui.Say(fmt.Sprintf(
+				"Resuming the build from its checkpoint, %d steps already completed.",
+				c.completed))
+		} else {
+			c.ui.Say("No checkpoint found, starting the build from the beginning.")
+		}
+	}
+
+	r.Runner.Run(c)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if cancelled || halted {
+		// The checkpoint is only left if the cleanup was skipped
+		if _, err := os.Stat(c.path); err == nil {
+			c.ui.Say("The build can be resumed from its checkpoint with -resume.")
+		}
+
+		return
+	}
+
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing checkpoint: %s", err)
+	}
+}
+
+// checkpointStep wraps a step in order to skip it if it completed before
+// the build was resumed, and to save the checkpoint once it completes.
+type checkpointStep struct {
+	multistep.Step
+	index        int
+	checkpointer *checkpointer
+}
+
+func (s *checkpointStep) Run(state multistep.StateBag) multistep.StepAction {
+	if s.index < s.checkpointer.completed {
+		if step, ok := s.Step.(ResumableStep); ok {
+			return step.Resume(state)
+		}
+
+		return multistep.ActionContinue
+	}
+
+	action := s.Step.Run(state)
+	if action == multistep.ActionContinue {
+		if err := s.checkpointer.save(s.index + 1); err != nil {
+			log.Printf("Error saving checkpoint: %s", err)
+		}
+	}
+
+	return action
+}
+
+func (s *checkpointStep) innerStep() multistep.Step {
+	return s.Step
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,199 @@
+package common
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testCheckpointStep puts a value in the state and halts if told to.
+type testCheckpointStep struct {
+	key   string
+	value interface{}
+	halt  bool
+
+	ran     bool
+	resumed bool
+}
+
+func (s *testCheckpointStep) Run(state multistep.StateBag) multistep.StepAction {
+	s.ran = true
+	state.Put(s.key, s.value)
+	if s.halt {
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *testCheckpointStep) Cleanup(multistep.StateBag) {}
+
+type testResumableStep struct {
+	testCheckpointStep
+}
+
+func (s *testResumableStep) Resume(multistep.StateBag) multistep.StepAction {
+	s.resumed = true
+	return multistep.ActionContinue
+}
+
+func testCheckpointDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return dir
+}
+
+func testCheckpointRun(dir string, resume bool, steps ...multistep.Step) multistep.StateBag {
+	config := PackerConfig{
+		PackerBuilderType: "foo",
+		PackerOnError:     packer.OnErrorAbort,
+		PackerResume:      resume,
+	}
+
+	ui := &packer.MachineReadableUi{Writer: new(bytes.Buffer)}
+	state := new(multistep.BasicStateBag)
+	NewResumableRunner(steps, config, ui, nil, dir).Run(state)
+	return state
+}
+
+func TestNewResumableRunner(t *testing.T) {
+	dir := testCheckpointDir(t)
+	defer os.RemoveAll(dir)
+
+	// The first build fails at the last step, leaving a checkpoint
+	testCheckpointRun(dir, false,
+		&testResumableStep{testCheckpointStep{key: "a", value: "foo"}},
+		&testResumableStep{testCheckpointStep{key: "b", value: uint(42)}},
+		&testResumableStep{testCheckpointStep{key: "c", value: true, halt: true}})
+	if !CheckpointExists(dir) {
+		t.Fatal("checkpoint should exist")
+	}
+
+	// The resumed build only runs the last step
+	steps := []*testResumableStep{
+		{testCheckpointStep{key: "a", value: "bar"}},
+		{testCheckpointStep{key: "b", value: uint(0)}},
+		{testCheckpointStep{key: "c", value: true}},
+	}
+
+	state := testCheckpointRun(dir, true, steps[0], steps[1], steps[2])
+	if steps[0].ran || steps[1].ran {
+		t.Fatal("completed steps should not run")
+	}
+	if !steps[0].resumed || !steps[1].resumed {
+		t.Fatal("completed steps should be resumed")
+	}
+	if !steps[2].ran || steps[2].resumed {
+		t.Fatal("last step should run")
+	}
+
+	if state.Get("a") != "foo" {
+		t.Fatalf("bad: %#v", state.Get("a"))
+	}
+	if state.Get("b") != uint(42) {
+		t.Fatalf("bad: %#v", state.Get("b"))
+	}
+
+	// The checkpoint is removed once the build succeeds
+	if CheckpointExists(dir) {
+		t.Fatal("checkpoint should be removed")
+	}
+}
+
+// testOutputDirStep removes the output directory when it is cleaned up,
+// like the steps that create it.
+type testOutputDirStep struct {
+	dir string
+}
+
+func (s *testOutputDirStep) Run(multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (s *testOutputDirStep) Cleanup(multistep.StateBag) {
+	os.RemoveAll(s.dir)
+}
+
+func TestNewResumableRunner_defaultOnError(t *testing.T) {
+	dir := testCheckpointDir(t)
+	defer os.RemoveAll(dir)
+
+	run := func(resume bool) {
+		config := PackerConfig{PackerBuilderType: "foo", PackerResume: resume}
+		steps := []multistep.Step{
+			&testOutputDirStep{dir: dir},
+			&testCheckpointStep{key: "a", value: "foo"},
+			&testCheckpointStep{key: "b", value: "bar", halt: true},
+		}
+
+		ui := &packer.MachineReadableUi{Writer: new(bytes.Buffer)}
+		NewResumableRunner(steps, config, ui, nil, dir).Run(new(multistep.BasicStateBag))
+	}
+
+	// Without resuming, a failed build is cleaned up as usual
+	run(false)
+	if CheckpointExists(dir) {
+		t.Fatal("checkpoint should be cleaned up")
+	}
+
+	// A resumed build keeps its resources if it fails again
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	run(true)
+	if !CheckpointExists(dir) {
+		t.Fatal("checkpoint should exist")
+	}
+}
+
+func TestNewResumableRunner_noResume(t *testing.T) {
+	dir := testCheckpointDir(t)
+	defer os.RemoveAll(dir)
+
+	testCheckpointRun(dir, false,
+		&testCheckpointStep{key: "a", value: "foo"},
+		&testCheckpointStep{key: "b", value: "bar", halt: true})
+
+	step := &testCheckpointStep{key: "a", value: "bar"}
+	testCheckpointRun(dir, false, step, &testCheckpointStep{key: "b", value: "baz"})
+	if !step.ran {
+		t.Fatal("step should run if not resuming")
+	}
+}
+
+func TestNewResumableRunner_noOutputDir(t *testing.T) {
+	dir := testCheckpointDir(t)
+	defer os.RemoveAll(dir)
+
+	outputDir := filepath.Join(dir, "output")
+	testCheckpointRun(outputDir, false,
+		&testCheckpointStep{key: "a", value: "foo", halt: true})
+	if _, err := os.Stat(outputDir); err == nil {
+		t.Fatal("output directory should not be created")
+	}
+}
+
+func TestNewResumableRunner_differentBuild(t *testing.T) {
+	dir := testCheckpointDir(t)
+	defer os.RemoveAll(dir)
+
+	testCheckpointRun(dir, false,
+		&testCheckpointStep{key: "a", value: "foo"},
+		&testCheckpointStep{key: "b", value: "bar", halt: true})
+
+	step := &testCheckpointStep{key: "a", value: "bar"}
+	state := testCheckpointRun(dir, true, step)
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if step.ran {
+		t.Fatal("step should not run")
+	}
+}
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	jsonutil "github.com/mitchellh/packer/common/json"
@@ -62,18 +63,19 @@ func (f *BuildOptions) AllUserVars() (map[string]string, error) {
 }
 
 // Builds returns the builds out of the given template that pass the
-// configured options.
+// configured options. A name given to -only or -except that is the name
+// of a builder with a matrix refers to every build of that matrix.
 func (f *BuildOptions) Builds(t *packer.Template, cf *packer.ComponentFinder) ([]packer.Build, error) {
 	buildNames := t.BuildNames()
 
 	checks := make(map[string][]string)
 	checks["except"] = f.Except
 	checks["only"] = f.Only
-	for t, ns := range checks {
+	for flagName, ns := range checks {
 		for _, n := range ns {
 			found := false
 			for _, actual := range buildNames {
-				if actual == n {
+				if matchesBuild(t, actual, n) {
 					found = true
 					break
 				}
@@ -81,7 +83,7 @@ func (f *BuildOptions) Builds(t *packer.Template, cf *packer.ComponentFinder) ([
 
 			if !found {
 				return nil, fmt.Errorf(
-					"Unknown build in '%s' flag: %s", t, n)
+					"Unknown build in '%s' flag: %s", flagName, n)
 			}
 		}
 	}
@@ -91,7 +93,7 @@ func (f *BuildOptions) Builds(t *packer.Template, cf *packer.ComponentFinder) ([
 		if len(f.Except) > 0 {
 			found := false
 			for _, except := range f.Except {
-				if buildName == except {
+				if matchesBuild(t, buildName, except) {
 					found = true
 					break
 				}
@@ -106,7 +108,7 @@ func (f *BuildOptions) Builds(t *packer.Template, cf *packer.ComponentFinder) ([
 		if len(f.Only) > 0 {
 			found := false
 			for _, only := range f.Only {
-				if buildName == only {
+				if matchesBuild(t, buildName, only) {
 					found = true
 					break
 				}
@@ -127,20 +129,64 @@ func (f *BuildOptions) Builds(t *packer.Template, cf *packer.ComponentFinder) ([
 		builds = append(builds, build)
 	}
 
+	// The builds that the builds depend on can't be left out
+	for _, build := range builds {
+		for _, dep := range t.Builders[build.Name()].DependsOn {
+			found := false
+			for _, other := range builds {
+				if other.Name() == dep {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return nil, fmt.Errorf(
+					"Build '%s' depends on build '%s', which isn't being built.",
+					build.Name(), dep)
+			}
+		}
+	}
+
 	return builds, nil
 }
 
+// matchesBuild tests if the name given to -only or -except refers to the
+// build with the given name.
+func matchesBuild(t *packer.Template, buildName string, name string) bool {
+	return buildName == name || t.Builders[buildName].MatrixName == name
+}
+
+// readFileVars reads the user variables from a JSON file. Values that
+// aren't strings, such as lists and maps for typed variables, are
+// JSON-encoded so that the template can decode them according to the
+// type of the variable.
 func readFileVars(path string) (map[string]string, error) {
 	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	vars := make(map[string]string)
-	err = jsonutil.Unmarshal(bytes, &vars)
+	rawVars := make(map[string]interface{})
+	err = jsonutil.Unmarshal(bytes, &rawVars)
 	if err != nil {
 		return nil, err
 	}
 
+	vars := make(map[string]string)
+	for k, v := range rawVars {
+		if s, ok := v.(string); ok {
+			vars[k] = s
+			continue
+		}
+
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding variable '%s': %s", k, err)
+		}
+
+		vars[k] = string(encoded)
+	}
+
 	return vars, nil
 }
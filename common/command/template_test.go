@@ -2,6 +2,9 @@ package command
 
 import (
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
 )
 
@@ -144,3 +147,131 @@ func TestBuildOptionsValidate_userVarFiles(t *testing.T) {
 		t.Fatal("should error")
 	}
 }
+
+func TestBuildOptionsAllUserVars_typedFile(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.Write([]byte(`{"foo": "bar", "list": ["a", "b"], "num": 3}`))
+	tf.Close()
+
+	opts := new(BuildOptions)
+	opts.UserVarFiles = []string{tf.Name()}
+
+	vars, err := opts.AllUserVars()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"foo":  "bar",
+		"list": `["a","b"]`,
+		"num":  "3",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("bad: %#v", vars)
+	}
+}
+
+func TestBuildOptionsBuilds_matrix(t *testing.T) {
+	tplData := `{
+	"builders": [
+	{
+		"name": "ubuntu",
+		"type": "foo",
+		"matrix": {
+			"arch": ["amd64", "i386"],
+			"version": ["12.04", "14.04"]
+		}
+	},
+	{
+		"type": "bar"
+	}
+	]
+}`
+
+	tpl, err := packer.ParseTemplate([]byte(tplData), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cf := &packer.ComponentFinder{
+		Builder: func(string) (packer.Builder, error) { return new(packer.MockBuilder), nil },
+	}
+
+	opts := new(BuildOptions)
+	opts.Only = []string{"ubuntu"}
+	bs, err := opts.Builds(tpl, cf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(bs) != 4 {
+		t.Fatalf("bad: %d", len(bs))
+	}
+
+	opts = new(BuildOptions)
+	opts.Only = []string{"ubuntu-i386-14.04"}
+	bs, err = opts.Builds(tpl, cf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(bs) != 1 || bs[0].Name() != "ubuntu-i386-14.04" {
+		t.Fatalf("bad: %#v", bs)
+	}
+
+	opts = new(BuildOptions)
+	opts.Except = []string{"ubuntu"}
+	bs, err = opts.Builds(tpl, cf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(bs) != 1 || bs[0].Name() != "bar" {
+		t.Fatalf("bad: %#v", bs)
+	}
+}
+
+func TestBuildOptionsBuilds_dependsOn(t *testing.T) {
+	tplData := `{
+	"builders": [
+	{
+		"type": "foo"
+	},
+	{
+		"type": "bar",
+		"depends_on": ["foo"]
+	}
+	]
+}`
+
+	tpl, err := packer.ParseTemplate([]byte(tplData), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cf := &packer.ComponentFinder{
+		Builder: func(string) (packer.Builder, error) { return new(packer.MockBuilder), nil },
+	}
+
+	opts := new(BuildOptions)
+	opts.Only = []string{"foo"}
+	bs, err := opts.Builds(tpl, cf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(bs) != 1 {
+		t.Fatalf("bad: %d", len(bs))
+	}
+
+	opts = new(BuildOptions)
+	opts.Except = []string{"foo"}
+	_, err = opts.Builds(tpl, cf)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
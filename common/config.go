@@ -14,13 +14,14 @@ import (
 )
 
 // ScrubConfig is a helper that returns a string representation of
-// any struct with the given values stripped out.
+// any struct with the given values stripped out, along with the values
+// of any sensitive user variables.
 func ScrubConfig(target interface{}, values ...string) string {
 	conf := fmt.Sprintf("Config: %+v", target)
 	for _, value := range values {
-		conf = strings.Replace(conf, value, "<Filtered>", -1)
+		conf = strings.Replace(conf, value, packer.SensitiveFilterText, -1)
 	}
-	return conf
+	return packer.ScrubSensitive(conf)
 }
 
 // CheckUnusedConfig is a helper that makes sure that the there are no
@@ -179,6 +180,7 @@ func decodeConfigHook(raws []interface{}) (mapstructure.DecodeHookFunc, error) {
 		return nil, err
 	}
 	tpl.UserVars = pc.PackerUserVars
+	tpl.UpstreamArtifacts = pc.PackerUpstreamArtifacts
 
 	return func(f reflect.Kind, t reflect.Kind, v interface{}) (interface{}, error) {
 		if t != reflect.String {
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CopyDir copies the contents of the directory src recursively into the
+// directory dst, creating it if it doesn't exist and keeping the modes of
+// the files. Symlinks are copied as symlinks. The paths in exclude are
+// relative to src, and are skipped along with everything below them.
+func CopyDir(dst string, src string, exclude []string) error {
+	excluded := make(map[string]bool)
+	for _, path := range exclude {
+		excluded[filepath.Clean(path)] = true
+	}
+
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relpath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		if excluded[relpath] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		dstPath := filepath.Join(dst, relpath)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, info.Mode()|0700)
+		}
+
+		// Symlinks are copied as they are, rather than followed, since
+		// they may point outside of src.
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(target, dstPath)
+		}
+
+		return copyFile(dstPath, path, info.Mode())
+	}
+
+	return filepath.Walk(src, walkFn)
+}
+
+func copyFile(dst string, src string, mode os.FileMode) error {
+	srcF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+
+	dstF, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	_, err = io.Copy(dstF, srcF)
+	return err
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	src := filepath.Join(td, "src")
+	files := map[string]string{
+		"a.txt":      "foo",
+		"sub/b.txt":  "bar",
+		"skip/c.txt": "baz",
+	}
+	for name, contents := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	dst := filepath.Join(td, "dst")
+	if err := CopyDir(dst, src, []string{"skip"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		path := filepath.Join(dst, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if string(data) != files[name] {
+			t.Fatalf("bad %s: %q", name, data)
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if fi.Mode().Perm() != 0600 {
+			t.Fatalf("bad mode %s: %s", name, fi.Mode())
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "skip")); !os.IsNotExist(err) {
+		t.Fatalf("excluded directory should not exist: %v", err)
+	}
+}
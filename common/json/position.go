@@ -0,0 +1,226 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position is the position of a value within a document, both counting
+// from 1. The column counts characters, not bytes.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Path returns the path of a value within a document, which is the key
+// of the value in the map returned by Positions. The parts are object
+// keys, as strings, and array indexes, as ints. The path is a JSON
+// pointer, such as "/builders/0/type".
+func Path(parts ...interface{}) string {
+	var buf bytes.Buffer
+	for _, part := range parts {
+		buf.WriteByte('/')
+		switch p := part.(type) {
+		case int:
+			buf.WriteString(strconv.Itoa(p))
+		case string:
+			p = strings.Replace(p, "~", "~0", -1)
+			p = strings.Replace(p, "/", "~1", -1)
+			buf.WriteString(p)
+		default:
+			panic(fmt.Sprintf("bad path part: %#v", part))
+		}
+	}
+
+	return buf.String()
+}
+
+// Positions returns the positions of all the values within the JSON
+// document, keyed by their Path. The position of an object member is
+// the position of its key, and the position of an array element is the
+// position of the element itself.
+func Positions(data []byte) (map[string]Position, error) {
+	s := &positionScanner{
+		data:       data,
+		lineStarts: []int{0},
+		positions:  make(map[string]Position),
+	}
+
+	for i, b := range data {
+		if b == '\n' {
+			s.lineStarts = append(s.lineStarts, i+1)
+		}
+	}
+
+	s.skipSpace()
+	s.positions[""] = s.position()
+	if err := s.value(""); err != nil {
+		return nil, err
+	}
+
+	return s.positions, nil
+}
+
+// positionScanner walks over a JSON document recording the position of
+// every value. It doesn't validate the document beyond what's needed to
+// find the values, since the document is decoded separately.
+type positionScanner struct {
+	data       []byte
+	offset     int
+	lineStarts []int
+	positions  map[string]Position
+}
+
+func (s *positionScanner) position() Position {
+	line := sort.Search(len(s.lineStarts), func(i int) bool {
+		return s.lineStarts[i] > s.offset
+	}) - 1
+
+	return Position{
+		Line:   line + 1,
+		Column: utf8.RuneCount(s.data[s.lineStarts[line]:s.offset]) + 1,
+	}
+}
+
+func (s *positionScanner) skipSpace() {
+	for s.offset < len(s.data) {
+		switch s.data[s.offset] {
+		case ' ', '\t', '\r', '\n':
+			s.offset++
+		default:
+			return
+		}
+	}
+}
+
+func (s *positionScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", s.position(), fmt.Sprintf(format, args...))
+}
+
+// value scans the value at the current offset, which has the given path.
+func (s *positionScanner) value(path string) error {
+	if s.offset >= len(s.data) {
+		return s.errorf("unexpected end of document")
+	}
+
+	switch s.data[s.offset] {
+	case '{':
+		return s.object(path)
+	case '[':
+		return s.array(path)
+	case '"':
+		_, err := s.str()
+		return err
+	default:
+		// A number, boolean or null, which all end at a delimiter
+		for s.offset < len(s.data) {
+			switch s.data[s.offset] {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				return nil
+			}
+
+			s.offset++
+		}
+
+		return nil
+	}
+}
+
+func (s *positionScanner) object(path string) error {
+	s.offset++
+	for {
+		s.skipSpace()
+		if s.offset >= len(s.data) {
+			return s.errorf("unexpected end of document")
+		}
+
+		switch s.data[s.offset] {
+		case '}':
+			s.offset++
+			return nil
+		case ',':
+			s.offset++
+			continue
+		}
+
+		pos := s.position()
+		key, err := s.str()
+		if err != nil {
+			return err
+		}
+
+		s.skipSpace()
+		if s.offset >= len(s.data) || s.data[s.offset] != ':' {
+			return s.errorf("expected ':'")
+		}
+		s.offset++
+		s.skipSpace()
+
+		keyPath := path + Path(key)
+		s.positions[keyPath] = pos
+		if err := s.value(keyPath); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *positionScanner) array(path string) error {
+	s.offset++
+	for i := 0; ; {
+		s.skipSpace()
+		if s.offset >= len(s.data) {
+			return s.errorf("unexpected end of document")
+		}
+
+		switch s.data[s.offset] {
+		case ']':
+			s.offset++
+			return nil
+		case ',':
+			s.offset++
+			continue
+		}
+
+		elemPath := path + Path(i)
+		s.positions[elemPath] = s.position()
+		if err := s.value(elemPath); err != nil {
+			return err
+		}
+
+		i++
+	}
+}
+
+// str scans the string at the current offset and returns its value.
+func (s *positionScanner) str() (string, error) {
+	if s.data[s.offset] != '"' {
+		return "", s.errorf("expected string")
+	}
+
+	start := s.offset
+	for s.offset++; s.offset < len(s.data); s.offset++ {
+		switch s.data[s.offset] {
+		case '\\':
+			s.offset++
+		case '"':
+			s.offset++
+			result, err := strconv.Unquote(string(s.data[start:s.offset]))
+			if err != nil {
+				// JSON escapes that Go doesn't know, such as "\/"
+				result = string(s.data[start+1 : s.offset-1])
+			}
+
+			return result, nil
+		}
+	}
+
+	return "", s.errorf("unexpected end of document")
+}
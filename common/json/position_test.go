@@ -0,0 +1,67 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	cases := []struct {
+		Parts  []interface{}
+		Result string
+	}{
+		{nil, ""},
+		{[]interface{}{"builders", 0, "type"}, "/builders/0/type"},
+		{[]interface{}{"a/b", "c~d"}, "/a~1b/c~0d"},
+	}
+
+	for _, tc := range cases {
+		if result := Path(tc.Parts...); result != tc.Result {
+			t.Fatalf("bad: %#v %s", tc, result)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	data := `{
+  "builders": [
+    {"type": "foo", "list": [1, true, null]},
+    { "name": "b\"\/ar" }
+  ],
+  "é": "x"
+}`
+
+	result, err := Positions([]byte(data))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]Position{
+		"":                   {Line: 1, Column: 1},
+		"/builders":          {Line: 2, Column: 3},
+		"/builders/0":        {Line: 3, Column: 5},
+		"/builders/0/type":   {Line: 3, Column: 6},
+		"/builders/0/list":   {Line: 3, Column: 21},
+		"/builders/0/list/0": {Line: 3, Column: 30},
+		"/builders/0/list/1": {Line: 3, Column: 33},
+		"/builders/0/list/2": {Line: 3, Column: 39},
+		"/builders/1":        {Line: 4, Column: 5},
+		"/builders/1/name":   {Line: 4, Column: 7},
+		"/é":                 {Line: 6, Column: 3},
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestPositions_error(t *testing.T) {
+	_, err := Positions([]byte("{\n  \"foo\": [1,"))
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if err.Error() != "2:13: unexpected end of document" {
+		t.Fatalf("bad: %s", err)
+	}
+}
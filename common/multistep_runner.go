@@ -0,0 +1,245 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Leftover is a resource of a build, such as a VM or an instance, that is
+// left behind when the cleanup of a failed build is skipped.
+type Leftover struct {
+	Type  string // The machine-readable type, such as "instance-id"
+	Name  string // The human-readable name, such as "Instance ID"
+	Value string
+}
+
+// LeftoverFunc returns the resources of a build that are left behind if
+// the cleanup of the build is skipped, using what is in the state.
+type LeftoverFunc func(multistep.StateBag) []Leftover
+
+// NewRunner returns the runner for the steps of a builder. This is a
+// debug runner if debug mode is enabled. If the on-error mode says to
+// keep the resources of a failed build, the cleanup of the steps is
+// skipped when the build fails, and the resources returned by leftovers
+// are reported so they can be looked at. Builds that succeed or are
+// cancelled are always cleaned up. The start and end of every step are
+// reported to the machine-readable output.
+func NewRunner(
+	steps []multistep.Step, config PackerConfig,
+	ui packer.Ui, leftovers LeftoverFunc) multistep.Runner {
+	timedSteps := make([]multistep.Step, len(steps))
+	for i, step := range steps {
+		timedSteps[i] = &timedStep{Step: step, ui: ui}
+	}
+	steps = timedSteps
+
+	switch config.PackerOnError {
+	case packer.OnErrorAbort, packer.OnErrorAsk:
+		h := &onErrorHandler{
+			mode:      config.PackerOnError,
+			ui:        ui,
+			leftovers: leftovers,
+		}
+
+		wrapped := make([]multistep.Step, len(steps))
+		for i, step := range steps {
+			wrapped[i] = &onErrorStep{Step: step, handler: h}
+		}
+
+		steps = wrapped
+	}
+
+	if config.PackerDebug {
+		// This pauses between the steps like a multistep.DebugRunner, which
+		// would name the pauses after the types of the wrapped steps.
+		pauseFn := MultistepDebugFn(ui)
+		debugSteps := make([]multistep.Step, 0, len(steps)*2)
+		for _, step := range steps {
+			debugSteps = append(debugSteps, step, &debugStepPause{
+				StepName: stepName(step),
+				PauseFn:  pauseFn,
+			})
+		}
+
+		steps = debugSteps
+	}
+
+	return &multistep.BasicRunner{Steps: steps}
+}
+
+// wrappedStep is a step that wraps another step.
+type wrappedStep interface {
+	innerStep() multistep.Step
+}
+
+// stepName returns the name of the type of a step, looking through the
+// steps that wrap it.
+func stepName(step multistep.Step) string {
+	for {
+		wrapped, ok := step.(wrappedStep)
+		if !ok {
+			break
+		}
+
+		step = wrapped.innerStep()
+	}
+
+	return reflect.Indirect(reflect.ValueOf(step)).Type().Name()
+}
+
+// debugStepPause pauses after a step runs and before it is cleaned up.
+type debugStepPause struct {
+	StepName string
+	PauseFn  multistep.DebugPauseFn
+}
+
+func (s *debugStepPause) Run(state multistep.StateBag) multistep.StepAction {
+	s.PauseFn(multistep.DebugLocationAfterRun, s.StepName, state)
+	return multistep.ActionContinue
+}
+
+func (s *debugStepPause) Cleanup(state multistep.StateBag) {
+	s.PauseFn(multistep.DebugLocationBeforeCleanup, s.StepName, state)
+}
+
+// timedStep wraps a step to report when it starts and finishes running
+// to the machine-readable output.
+type timedStep struct {
+	multistep.Step
+	ui packer.Ui
+}
+
+func (s *timedStep) Run(state multistep.StateBag) multistep.StepAction {
+	name := stepName(s.Step)
+	s.ui.Machine(packer.MachineStepStarted, name)
+
+	start := time.Now()
+	action := s.Step.Run(state)
+
+	outcome := packer.OutcomeSuccess
+	if action != multistep.ActionContinue {
+		outcome = packer.OutcomeError
+		if _, ok := state.GetOk(multistep.StateCancelled); ok {
+			outcome = packer.OutcomeCancelled
+		}
+	}
+
+	s.ui.Machine(packer.MachineStepFinished,
+		name, packer.MachineDuration(time.Since(start)), outcome)
+	return action
+}
+
+func (s *timedStep) innerStep() multistep.Step {
+	return s.Step
+}
+
+// onErrorStep wraps a step so that its cleanup is skipped if the handler
+// decides to keep the resources of the build.
+type onErrorStep struct {
+	multistep.Step
+	handler *onErrorHandler
+}
+
+func (s *onErrorStep) Cleanup(state multistep.StateBag) {
+	if s.handler.keep(state) {
+		return
+	}
+
+	s.Step.Cleanup(state)
+}
+
+func (s *onErrorStep) innerStep() multistep.Step {
+	return s.Step
+}
+
+// onErrorHandler decides whether the resources of a build are kept when
+// the cleanup starts, which is only decided once for all the steps.
+type onErrorHandler struct {
+	mode      string
+	ui        packer.Ui
+	leftovers LeftoverFunc
+
+	once   sync.Once
+	result bool
+}
+
+func (h *onErrorHandler) keep(state multistep.StateBag) bool {
+	h.once.Do(func() {
+		if _, ok := state.GetOk(multistep.StateCancelled); ok {
+			return
+		}
+
+		if _, ok := state.GetOk(multistep.StateHalted); !ok {
+			return
+		}
+
+		switch h.mode {
+		case packer.OnErrorAbort:
+			h.ui.Error("Build failed, skipping cleanup.")
+			h.sayLeftovers(state)
+			h.result = true
+		case packer.OnErrorAsk:
+			h.ui.Error("Build failed.")
+			h.sayLeftovers(state)
+			h.result = h.ask()
+		}
+
+		if h.result {
+			h.ui.Say("The resources of the build must be cleaned up manually.")
+		}
+	})
+
+	return h.result
+}
+
+// sayLeftovers reports the resources of the build to the Ui and to the
+// machine-readable output.
+func (h *onErrorHandler) sayLeftovers(state multistep.StateBag) {
+	var leftovers []Leftover
+	if h.leftovers != nil {
+		leftovers = h.leftovers(state)
+	}
+
+	if address, ok := state.GetOk("ssh_address"); ok {
+		leftovers = append(leftovers, Leftover{Type: "ssh-address", Name: "SSH address", Value: address.(string)})
+	}
+
+	if len(leftovers) == 0 {
+		h.ui.Say("The build has no resources to look at.")
+		return
+	}
+
+	h.ui.Say("The resources of the build are:")
+	for _, leftover := range leftovers {
+		h.ui.Message(fmt.Sprintf("%s: %s", leftover.Name, leftover.Value))
+		h.ui.Machine("leftover", leftover.Type, leftover.Value)
+	}
+}
+
+// ask asks whether to keep the resources of the build. They are cleaned
+// up if there is no answer, such as when there is no input to read.
+func (h *onErrorHandler) ask() bool {
+	for {
+		line, err := h.ui.Ask(
+			"[c] Clean up the resources and exit, [a] abort and keep them (default c):")
+		if err != nil {
+			log.Printf("Error asking for input, cleaning up: %s", err)
+			return false
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "", "c", "cleanup":
+			return false
+		case "a", "abort":
+			return true
+		}
+
+		h.ui.Say("Please answer 'c' to clean up or 'a' to abort.")
+	}
+}
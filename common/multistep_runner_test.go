@@ -0,0 +1,127 @@
+package common
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type testRunnerStep struct {
+	action  multistep.StepAction
+	cleaned bool
+}
+
+func (s *testRunnerStep) Run(multistep.StateBag) multistep.StepAction {
+	return s.action
+}
+
+func (s *testRunnerStep) Cleanup(multistep.StateBag) {
+	s.cleaned = true
+}
+
+func testRunnerLeftovers(multistep.StateBag) []Leftover {
+	return []Leftover{{Type: "vm-name", Name: "VM name", Value: "foo"}}
+}
+
+func testRunner(onError string, ui packer.Ui, action multistep.StepAction) []*testRunnerStep {
+	steps := []*testRunnerStep{
+		{action: multistep.ActionContinue},
+		{action: action},
+	}
+
+	config := PackerConfig{PackerOnError: onError}
+	runner := NewRunner(
+		[]multistep.Step{steps[0], steps[1]}, config, ui, testRunnerLeftovers)
+	runner.Run(new(multistep.BasicStateBag))
+	return steps
+}
+
+func TestNewRunner_cleanup(t *testing.T) {
+	ui := &packer.MachineReadableUi{Writer: new(bytes.Buffer)}
+	steps := testRunner(packer.OnErrorCleanup, ui, multistep.ActionHalt)
+	for i, step := range steps {
+		if !step.cleaned {
+			t.Fatalf("step %d should be cleaned up", i)
+		}
+	}
+}
+
+func TestNewRunner_machineEvents(t *testing.T) {
+	out := new(bytes.Buffer)
+	ui := &packer.MachineReadableUi{Writer: out}
+	testRunner(packer.OnErrorCleanup, ui, multistep.ActionHalt)
+
+	re := regexp.MustCompile(`,step-(started|finished),testRunnerStep(,[0-9]+\.[0-9]{3},([a-z]+))?\n`)
+	matches := re.FindAllStringSubmatch(out.String(), -1)
+	if len(matches) != 4 {
+		t.Fatalf("bad: %s", out.String())
+	}
+
+	expected := []string{"started", "finished", "started", "finished"}
+	outcomes := []string{"", packer.OutcomeSuccess, "", packer.OutcomeError}
+	for i, match := range matches {
+		if match[1] != expected[i] || match[3] != outcomes[i] {
+			t.Fatalf("bad: %d: %#v", i, match)
+		}
+	}
+}
+
+func TestNewRunner_abort(t *testing.T) {
+	out := new(bytes.Buffer)
+	ui := &packer.MachineReadableUi{Writer: out}
+	steps := testRunner(packer.OnErrorAbort, ui, multistep.ActionHalt)
+	for i, step := range steps {
+		if step.cleaned {
+			t.Fatalf("step %d should not be cleaned up", i)
+		}
+	}
+
+	if !strings.Contains(out.String(), ",leftover,vm-name,foo\n") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestNewRunner_abortSuccess(t *testing.T) {
+	out := new(bytes.Buffer)
+	ui := &packer.MachineReadableUi{Writer: out}
+	steps := testRunner(packer.OnErrorAbort, ui, multistep.ActionContinue)
+	for i, step := range steps {
+		if !step.cleaned {
+			t.Fatalf("step %d should be cleaned up", i)
+		}
+	}
+
+	if strings.Contains(out.String(), "leftover") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestNewRunner_ask(t *testing.T) {
+	cases := []struct {
+		input   string
+		cleaned bool
+	}{
+		{"a\n", false},
+		{"c\n", true},
+		{"foo\nabort\n", false},
+		{"", true},
+	}
+
+	for _, tc := range cases {
+		ui := &packer.BasicUi{
+			Reader:      strings.NewReader(tc.input),
+			Writer:      new(bytes.Buffer),
+			ErrorWriter: new(bytes.Buffer),
+		}
+
+		steps := testRunner(packer.OnErrorAsk, ui, multistep.ActionHalt)
+		for i, step := range steps {
+			if step.cleaned != tc.cleaned {
+				t.Fatalf("input %q: step %d cleaned: %v", tc.input, i, step.cleaned)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/packer/communicator/ssh"
+	"github.com/mitchellh/packer/packer"
+	gossh "golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// The methods of transferring files to and from the machine over SSH.
+const (
+	SSHFileTransferSCP  = "scp"
+	SSHFileTransferSFTP = "sftp"
+)
+
+// SSHCommConfig is the configuration of the SSH communicator that is
+// shared by all the builders that connect to the machine over SSH.
+type SSHCommConfig struct {
+	SSHFileTransferMethod string `mapstructure:"ssh_file_transfer_method"`
+
+	// The bastion host that the connection to the machine is tunneled
+	// through, if any.
+	SSHBastionHost           string `mapstructure:"ssh_bastion_host"`
+	SSHBastionPort           int    `mapstructure:"ssh_bastion_port"`
+	SSHBastionUsername       string `mapstructure:"ssh_bastion_username"`
+	SSHBastionPassword       string `mapstructure:"ssh_bastion_password"`
+	SSHBastionPrivateKeyFile string `mapstructure:"ssh_bastion_private_key_file"`
+
+	// The URL of a SOCKS5 or HTTP proxy that the machine, or the bastion
+	// host if there is one, is connected to through.
+	SSHProxy string `mapstructure:"ssh_proxy"`
+
+	// Whether to authenticate with the local SSH agent, and whether to
+	// forward it into the commands run on the machine.
+	SSHAgentAuth    bool `mapstructure:"ssh_agent_auth"`
+	SSHForwardAgent bool `mapstructure:"ssh_forward_agent"`
+}
+
+func (c *SSHCommConfig) Prepare(t *packer.ConfigTemplate) []error {
+	if t == nil {
+		var err error
+		t, err = packer.NewConfigTemplate()
+		if err != nil {
+			return []error{err}
+		}
+	}
+
+	templates := map[string]*string{
+		"ssh_file_transfer_method":     &c.SSHFileTransferMethod,
+		"ssh_bastion_host":             &c.SSHBastionHost,
+		"ssh_bastion_username":         &c.SSHBastionUsername,
+		"ssh_bastion_password":         &c.SSHBastionPassword,
+		"ssh_bastion_private_key_file": &c.SSHBastionPrivateKeyFile,
+		"ssh_proxy":                    &c.SSHProxy,
+	}
+
+	errs := make([]error, 0)
+	for n, ptr := range templates {
+		var err error
+		*ptr, err = t.Process(*ptr, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error processing %s: %s", n, err))
+		}
+	}
+
+	// The config is logged by the builders, so the passwords are kept out
+	// of it, along with any other output of this process.
+	packer.AddSensitiveValues(c.SSHBastionPassword)
+
+	if c.SSHFileTransferMethod == "" {
+		c.SSHFileTransferMethod = SSHFileTransferSCP
+	}
+
+	switch c.SSHFileTransferMethod {
+	case SSHFileTransferSCP, SSHFileTransferSFTP:
+	default:
+		errs = append(errs, fmt.Errorf(
+			"ssh_file_transfer_method must be 'scp' or 'sftp', got '%s'",
+			c.SSHFileTransferMethod))
+	}
+
+	if c.SSHBastionHost != "" {
+		if c.SSHBastionPort == 0 {
+			c.SSHBastionPort = 22
+		}
+
+		if c.SSHBastionUsername == "" {
+			errs = append(errs, errors.New(
+				"ssh_bastion_username must be specified with ssh_bastion_host"))
+		}
+
+		if c.SSHBastionPassword == "" && c.SSHBastionPrivateKeyFile == "" && !c.SSHAgentAuth {
+			errs = append(errs, errors.New(
+				"ssh_bastion_password, ssh_bastion_private_key_file or "+
+					"ssh_agent_auth must be specified with ssh_bastion_host"))
+		}
+
+		if c.SSHBastionPrivateKeyFile != "" {
+			if _, err := bastionSigner(c.SSHBastionPrivateKeyFile); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"ssh_bastion_private_key_file is invalid: %s", err))
+			}
+		}
+	}
+
+	if c.SSHProxy != "" {
+		u, err := url.Parse(c.SSHProxy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ssh_proxy is invalid: %s", err))
+		} else if u.Scheme != "socks5" && u.Scheme != "http" {
+			errs = append(errs, fmt.Errorf(
+				"ssh_proxy must be a 'socks5' or 'http' URL, got '%s'", u.Scheme))
+		} else if u.Host == "" {
+			errs = append(errs, errors.New("ssh_proxy must have a host"))
+		}
+
+		if err == nil && u.User != nil {
+			if password, ok := u.User.Password(); ok {
+				packer.AddSensitiveValues(password)
+			}
+		}
+	}
+
+	if (c.SSHAgentAuth || c.SSHForwardAgent) && os.Getenv("SSH_AUTH_SOCK") == "" {
+		errs = append(errs, errors.New(
+			"ssh_agent_auth and ssh_forward_agent require an SSH agent, "+
+				"but SSH_AUTH_SOCK is not set"))
+	}
+
+	return errs
+}
+
+// ConnectFunc returns the function that the SSH communicator connects to
+// the machine at the given address with, going through the proxy and the
+// bastion host if they are configured. The agent authentication, if not
+// nil, is also used to authenticate to the bastion host.
+func (c *SSHCommConfig) ConnectFunc(address string, agentAuth gossh.AuthMethod) (func() (net.Conn, error), error) {
+	// The proxy is used to reach the bastion host if there is one, and
+	// the machine otherwise.
+	target := address
+	if c.SSHBastionHost != "" {
+		target = net.JoinHostPort(c.SSHBastionHost, strconv.Itoa(c.SSHBastionPort))
+	}
+
+	connFunc := ssh.ConnectFunc("tcp", target)
+	if c.SSHProxy != "" {
+		u, err := url.Parse(c.SSHProxy)
+		if err != nil {
+			return nil, err
+		}
+
+		connFunc, err = ssh.ProxyConnectFunc(u, target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.SSHBastionHost != "" {
+		config, err := c.bastionConfig(agentAuth)
+		if err != nil {
+			return nil, err
+		}
+
+		connFunc = ssh.BastionConnectFunc(connFunc, config, address)
+	}
+
+	return connFunc, nil
+}
+
+func (c *SSHCommConfig) bastionConfig(agentAuth gossh.AuthMethod) (*gossh.ClientConfig, error) {
+	var auth []gossh.AuthMethod
+	if c.SSHBastionPassword != "" {
+		auth = append(auth,
+			gossh.Password(c.SSHBastionPassword),
+			gossh.KeyboardInteractive(
+				ssh.PasswordKeyboardInteractive(c.SSHBastionPassword).Challenge))
+	}
+
+	if c.SSHBastionPrivateKeyFile != "" {
+		signer, err := bastionSigner(c.SSHBastionPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading bastion private key: %s", err)
+		}
+
+		auth = append(auth, gossh.PublicKeys(signer))
+	}
+
+	if agentAuth != nil {
+		auth = append(auth, agentAuth)
+	}
+
+	return &gossh.ClientConfig{
+		User: c.SSHBastionUsername,
+		Auth: auth,
+	}, nil
+}
+
+func bastionSigner(path string) (gossh.Signer, error) {
+	keyBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return gossh.ParsePrivateKey(keyBytes)
+}
@@ -1,13 +1,15 @@
 package common
 
 import (
-	gossh "code.google.com/p/go.crypto/ssh"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/mitchellh/packer/packer"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"log"
+	"net"
 	"strings"
 	"time"
 )
@@ -18,9 +20,13 @@ import (
 //
 // Uses:
 //   ui packer.Ui
+//   vm_stopped bool (optional) - When resuming, the VM was shut down
 //
 // Produces:
 //   communicator packer.Communicator
+//   ssh_address string - The address that SSH is connected to
+//   ssh_user string - The user that SSH is connected as
+//   ssh_comm_config *SSHCommConfig - The communicator configuration, if any
 type StepConnectSSH struct {
 	// SSHAddress is a function that returns the TCP address to connect to
 	// for SSH. This is a function so that you can query information
@@ -37,7 +43,15 @@ type StepConnectSSH struct {
 	// NoPty, if true, will not request a Pty from the remote end.
 	NoPty bool
 
-	comm packer.Communicator
+	// CommConfig is the configuration of the communicator, such as how
+	// files are transferred and the bastion host or proxy to connect
+	// through. The defaults are used if it is nil.
+	CommConfig *SSHCommConfig
+
+	address   string
+	user      string
+	comm      packer.Communicator
+	agentConn net.Conn
 }
 
 func (s *StepConnectSSH) Run(state multistep.StateBag) multistep.StepAction {
@@ -70,6 +84,11 @@ WaitLoop:
 			ui.Say("Connected to SSH!")
 			s.comm = comm
 			state.Put("communicator", comm)
+			state.Put("ssh_address", s.address)
+			state.Put("ssh_user", s.user)
+			if s.CommConfig != nil {
+				state.Put("ssh_comm_config", s.CommConfig)
+			}
 			break WaitLoop
 		case <-timeout:
 			err := fmt.Errorf("Timeout waiting for SSH.")
@@ -91,12 +110,42 @@ WaitLoop:
 	return multistep.ActionContinue
 }
 
+// Resume connects to SSH again, since the connection doesn't outlive a
+// run of Packer, unless the VM was shut down.
+func (s *StepConnectSSH) Resume(state multistep.StateBag) multistep.StepAction {
+	if _, ok := state.GetOk("vm_stopped"); ok {
+		return multistep.ActionContinue
+	}
+
+	return s.Run(state)
+}
+
 func (s *StepConnectSSH) Cleanup(multistep.StateBag) {
+	if s.agentConn != nil {
+		s.agentConn.Close()
+		s.agentConn = nil
+	}
 }
 
 func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan struct{}) (packer.Communicator, error) {
 	handshakeAttempts := 0
 
+	// The agent authenticates every connection, including reconnects,
+	// so the connection to it stays open until the step is cleaned up.
+	var agentAuth gossh.AuthMethod
+	if s.CommConfig != nil && s.CommConfig.SSHAgentAuth {
+		if s.agentConn == nil {
+			agentConn, err := ssh.AgentConn()
+			if err != nil {
+				return nil, err
+			}
+
+			s.agentConn = agentConn
+		}
+
+		agentAuth = gossh.PublicKeysCallback(agent.NewClient(s.agentConn).Signers)
+	}
+
 	var comm packer.Communicator
 	for {
 		select {
@@ -120,8 +169,20 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 			continue
 		}
 
-		// Attempt to connect to SSH port
+		if agentAuth != nil {
+			sshConfig.Auth = append(sshConfig.Auth, agentAuth)
+		}
+
+		// Attempt to connect to SSH port, through the bastion host
+		// and proxy if there are any
 		connFunc := ssh.ConnectFunc("tcp", address)
+		if s.CommConfig != nil {
+			connFunc, err = s.CommConfig.ConnectFunc(address, agentAuth)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		nc, err := connFunc()
 		if err != nil {
 			log.Printf("TCP connection to SSH ip/port failed: %s", err)
@@ -136,6 +197,11 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 			NoPty:      s.NoPty,
 		}
 
+		if s.CommConfig != nil {
+			config.UseSFTP = s.CommConfig.SSHFileTransferMethod == SSHFileTransferSFTP
+			config.ForwardAgent = s.CommConfig.SSHForwardAgent
+		}
+
 		log.Println("Attempting SSH connection...")
 		comm, err = ssh.New(config)
 		if err != nil {
@@ -157,6 +223,8 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 			return nil, err
 		}
 
+		s.address = address
+		s.user = sshConfig.User
 		break
 	}
 
@@ -102,6 +102,16 @@ func (s *StepCreateFloppy) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
+// Resume restores the path of the floppy disk so that it is deleted when
+// the build is done.
+func (s *StepCreateFloppy) Resume(state multistep.StateBag) multistep.StepAction {
+	if floppyPath, ok := state.GetOk("floppy_path"); ok {
+		s.floppyPath = floppyPath.(string)
+	}
+
+	return multistep.ActionContinue
+}
+
 func (s *StepCreateFloppy) Cleanup(multistep.StateBag) {
 	if s.floppyPath != "" {
 		log.Printf("Deleting floppy disk: %s", s.floppyPath)
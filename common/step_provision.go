@@ -2,17 +2,26 @@ package common
 
 import (
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/mitchellh/packer/packer"
 	"log"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 )
 
-// StepProvision runs the provisioners.
+// StepProvision runs the provisioners. If the builder connected over SSH,
+// the hook is given how to reach the machine as a *packer.ConnectionInfo.
 //
 // Uses:
 //   communicator packer.Communicator
 //   hook         packer.Hook
 //   ui           packer.Ui
+//   ssh_address string (optional)
+//   ssh_user string (optional)
+//   ssh_comm_config *SSHCommConfig (optional)
+//   privateKey or ssh_private_key string (optional) - A temporary SSH key
 //
 // Produces:
 //   <nothing>
@@ -29,12 +38,19 @@ func (s *StepProvision) Run(state multistep.StateBag) multistep.StepAction {
 	hook := state.Get("hook").(packer.Hook)
 	ui := state.Get("ui").(packer.Ui)
 
+	// The data is only set if there is connection information, since a nil
+	// pointer can't be sent to the hook over RPC.
+	var data interface{}
+	if info := connectionInfo(state); info != nil {
+		data = info
+	}
+
 	// Run the provisioner in a goroutine so we can continually check
 	// for cancellations...
 	log.Println("Running the provision hook")
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- hook.Run(packer.HookProvision, ui, comm, nil)
+		errCh <- hook.Run(packer.HookProvision, ui, comm, data)
 	}()
 
 	for {
@@ -57,3 +73,62 @@ func (s *StepProvision) Run(state multistep.StateBag) multistep.StepAction {
 }
 
 func (*StepProvision) Cleanup(multistep.StateBag) {}
+
+// connectionInfo returns how to reach the machine over SSH, or nil if the
+// builder didn't connect over SSH.
+func connectionInfo(state multistep.StateBag) *packer.ConnectionInfo {
+	address, ok := state.GetOk("ssh_address")
+	if !ok {
+		return nil
+	}
+
+	host, portStr, err := net.SplitHostPort(address.(string))
+	if err != nil {
+		log.Printf("Error parsing SSH address '%s': %s", address, err)
+		return nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		log.Printf("Error parsing SSH port '%s': %s", portStr, err)
+		return nil
+	}
+
+	info := &packer.ConnectionInfo{
+		Host: host,
+		Port: port,
+	}
+
+	if user, ok := state.GetOk("ssh_user"); ok {
+		info.User = user.(string)
+	}
+
+	// The machine may only be reachable through the bastion host and
+	// proxy that the communicator connected through.
+	if raw, ok := state.GetOk("ssh_comm_config"); ok {
+		config := raw.(*SSHCommConfig)
+		if config.SSHBastionHost != "" {
+			info.BastionHost = config.SSHBastionHost
+			info.BastionPort = config.SSHBastionPort
+			info.BastionUser = config.SSHBastionUsername
+		}
+
+		if config.SSHProxy != "" {
+			if u, err := url.Parse(config.SSHProxy); err == nil {
+				info.ProxyType = u.Scheme
+				info.ProxyAddress = ssh.ProxyAddress(u)
+			}
+		}
+	}
+
+	// The builders that create a temporary key pair keep the private key
+	// under one of these keys.
+	for _, key := range []string{"privateKey", "ssh_private_key"} {
+		if privateKey, ok := state.GetOk(key); ok {
+			info.PrivateKey = privateKey.(string)
+			break
+		}
+	}
+
+	return info
+}
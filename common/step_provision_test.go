@@ -2,6 +2,8 @@ package common
 
 import (
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"reflect"
 	"testing"
 )
 
@@ -12,3 +14,39 @@ func TestStepProvision_Impl(t *testing.T) {
 		t.Fatalf("provision should be a step")
 	}
 }
+
+func TestStepProvision_connectionInfo(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if info := connectionInfo(state); info != nil {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	state.Put("ssh_address", "10.0.0.1:2222")
+	state.Put("ssh_user", "root")
+	state.Put("privateKey", "KEY")
+
+	expected := &packer.ConnectionInfo{
+		Host:       "10.0.0.1",
+		Port:       2222,
+		User:       "root",
+		PrivateKey: "KEY",
+	}
+	if info := connectionInfo(state); !reflect.DeepEqual(info, expected) {
+		t.Fatalf("bad: %#v", info)
+	}
+	state.Put("ssh_comm_config", &SSHCommConfig{
+		SSHBastionHost:     "bastion",
+		SSHBastionPort:     2200,
+		SSHBastionUsername: "bob",
+		SSHProxy:           "socks5://proxy",
+	})
+
+	expected.BastionHost = "bastion"
+	expected.BastionPort = 2200
+	expected.BastionUser = "bob"
+	expected.ProxyType = "socks5"
+	expected.ProxyAddress = "proxy:1080"
+	if info := connectionInfo(state); !reflect.DeepEqual(info, expected) {
+		t.Fatalf("bad: %#v", info)
+	}
+}
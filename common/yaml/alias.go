@@ -0,0 +1,40 @@
+package yaml
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// maxAliasExpansions is the number of aliases that a document can expand
+// in total. Aliases within the values of other aliases multiply, so a
+// small document could otherwise expand to billions of values.
+const maxAliasExpansions = 10000
+
+// aliasGuard tracks the aliases that are being expanded while walking a
+// document, so that an alias within the value it refers to is an error
+// instead of an endless recursion, and so is expanding too many aliases.
+type aliasGuard struct {
+	expanding map[*yaml.Node]bool
+	count     int
+}
+
+func newAliasGuard() *aliasGuard {
+	return &aliasGuard{expanding: make(map[*yaml.Node]bool)}
+}
+
+// expand calls f with the value that the alias node refers to, unless the
+// alias is already being expanded.
+func (g *aliasGuard) expand(n *yaml.Node, f func(*yaml.Node) error) error {
+	if g.expanding[n] {
+		return newNodeError(n, "alias '%s' refers to a value that contains it", n.Value)
+	}
+
+	g.count++
+	if g.count > maxAliasExpansions {
+		return newNodeError(n, "document expands more than %d aliases", maxAliasExpansions)
+	}
+
+	g.expanding[n] = true
+	defer delete(g.expanding, n)
+
+	return f(n.Alias)
+}
@@ -0,0 +1,23 @@
+package yaml
+
+import (
+	"bytes"
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal encodes the value as a YAML document, indented with two
+// spaces like the JSON that Packer outputs.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,100 @@
+package yaml
+
+import (
+	jsonutil "github.com/mitchellh/packer/common/json"
+	"gopkg.in/yaml.v3"
+)
+
+// Positions returns the positions of all the values within the YAML
+// document, keyed by their path as with the JSON positions. The position
+// of a mapping member is the position of its key, and the position of a
+// sequence element is the position of the element itself. Values merged
+// in with "<<" have the position of their definition.
+func Positions(data []byte) (map[string]jsonutil.Position, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]jsonutil.Position)
+	if len(root.Content) > 0 {
+		if err := nodePositions(root.Content[0], "", result, newAliasGuard()); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func nodePositions(n *yaml.Node, path string, result map[string]jsonutil.Position, g *aliasGuard) error {
+	if _, ok := result[path]; !ok {
+		result[path] = jsonutil.Position{Line: n.Line, Column: n.Column}
+	}
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		return g.expand(n, func(alias *yaml.Node) error {
+			return nodePositions(alias, path, result, g)
+		})
+	case yaml.SequenceNode:
+		for i, elem := range n.Content {
+			if err := nodePositions(elem, path+jsonutil.Path(i), result, g); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		var merges []*yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Tag == "!!merge" {
+				merges = append(merges, value)
+				continue
+			}
+
+			keyPath := path + jsonutil.Path(key.Value)
+			result[keyPath] = jsonutil.Position{Line: key.Line, Column: key.Column}
+			if err := nodePositions(value, keyPath, result, g); err != nil {
+				return err
+			}
+		}
+
+		// Keys set directly take precedence over merged keys
+		for _, merge := range merges {
+			sources := []*yaml.Node{merge}
+			if merge.Kind == yaml.SequenceNode {
+				sources = merge.Content
+			}
+
+			for _, source := range sources {
+				merge := func(source *yaml.Node) error {
+					for i := 0; i+1 < len(source.Content); i += 2 {
+						keyPath := path + jsonutil.Path(source.Content[i].Value)
+						if _, ok := result[keyPath]; ok {
+							continue
+						}
+
+						key := source.Content[i]
+						result[keyPath] = jsonutil.Position{Line: key.Line, Column: key.Column}
+						if err := nodePositions(source.Content[i+1], keyPath, result, g); err != nil {
+							return err
+						}
+					}
+
+					return nil
+				}
+
+				var err error
+				if source.Kind == yaml.AliasNode {
+					err = g.expand(source, merge)
+				} else {
+					err = merge(source)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package yaml
+
+import (
+	jsonutil "github.com/mitchellh/packer/common/json"
+	"reflect"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	data := `base: &base
+  size: 10
+builders:
+  - type: foo
+    <<: *base
+  - name: bar
+`
+
+	result, err := Positions([]byte(data))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]jsonutil.Position{
+		"":                 {Line: 1, Column: 1},
+		"/base":            {Line: 1, Column: 1},
+		"/base/size":       {Line: 2, Column: 3},
+		"/builders":        {Line: 3, Column: 1},
+		"/builders/0":      {Line: 4, Column: 5},
+		"/builders/0/type": {Line: 4, Column: 5},
+		"/builders/0/size": {Line: 2, Column: 3},
+		"/builders/1":      {Line: 6, Column: 5},
+		"/builders/1/name": {Line: 6, Column: 5},
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestPositions_recursiveAlias(t *testing.T) {
+	cases := []string{
+		"a: &x\n  b: *x\n",
+		"a: &x\n  <<: *x\n",
+		"a: &x\n  - [*x]\n",
+	}
+
+	for _, data := range cases {
+		if _, err := Positions([]byte(data)); err == nil {
+			t.Fatalf("should error: %s", data)
+		}
+	}
+}
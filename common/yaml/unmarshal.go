@@ -0,0 +1,244 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// syntaxErrorRe matches the syntax errors of the YAML parser in order to
+// extract the line number. The parser leaves the line out of some errors
+// on the first line.
+var syntaxErrorRe = regexp.MustCompile(`^yaml: (?:line (\d+): )?(.*)$`)
+
+// IsYAML tests if the data, read from the given path, is YAML rather than
+// JSON. Files with a ".yml" or ".yaml" extension are YAML. Otherwise, such
+// as when reading from stdin, data that doesn't start like a JSON object
+// or array is YAML.
+func IsYAML(path string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return true
+	case ".json":
+		return false
+	}
+
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] != '{' && data[0] != '['
+}
+
+// Unmarshal decodes YAML data into i the same way that JSON would be
+// decoded, so the result uses the same types as JSON: objects are
+// map[string]interface{} and numbers are float64. Values that aren't
+// numbers, booleans or null, such as timestamps, are strings. Errors
+// are returned with the line and column they refer to.
+func Unmarshal(data []byte, i interface{}) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		if match := syntaxErrorRe.FindStringSubmatch(err.Error()); match != nil {
+			line := 1
+			if match[1] != "" {
+				line, _ = strconv.Atoi(match[1])
+			}
+
+			// The parser only reports the line of syntax errors, so the
+			// column is where the content of the line starts.
+			source := sourceLine(data, line)
+			column := len(source) - len(strings.TrimLeft(source, " \t")) + 1
+			return fmt.Errorf("Error in line %d, char %d: %s\n%s",
+				line, column, match[2], source)
+		}
+
+		return err
+	}
+
+	var result interface{}
+	if len(root.Content) > 0 {
+		var err error
+		result, err = convertNode(root.Content[0], newAliasGuard())
+		if err != nil {
+			if nodeErr, ok := err.(*nodeError); ok {
+				return fmt.Errorf("Error in line %d, char %d: %s\n%s",
+					nodeErr.Line, nodeErr.Column, nodeErr.Err,
+					sourceLine(data, nodeErr.Line))
+			}
+
+			return err
+		}
+	}
+
+	if p, ok := i.(*interface{}); ok {
+		*p = result
+		return nil
+	}
+
+	// Decode into anything else through JSON, which handles the
+	// conversion to structures and types just like a JSON file would.
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, i)
+}
+
+// nodeError is an error for a specific node of the document.
+type nodeError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *nodeError) Error() string {
+	return fmt.Sprintf("line %d, char %d: %s", e.Line, e.Column, e.Err)
+}
+
+func newNodeError(n *yaml.Node, format string, args ...interface{}) error {
+	return &nodeError{
+		Line:   n.Line,
+		Column: n.Column,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+// convertNode converts a YAML node into the value JSON would decode it to.
+func convertNode(n *yaml.Node, g *aliasGuard) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+
+		return convertNode(n.Content[0], g)
+	case yaml.AliasNode:
+		var result interface{}
+		err := g.expand(n, func(alias *yaml.Node) error {
+			var err error
+			result, err = convertNode(alias, g)
+			return err
+		})
+
+		return result, err
+	case yaml.ScalarNode:
+		return convertScalar(n)
+	case yaml.SequenceNode:
+		result := make([]interface{}, len(n.Content))
+		for i, elem := range n.Content {
+			v, err := convertNode(elem, g)
+			if err != nil {
+				return nil, err
+			}
+
+			result[i] = v
+		}
+
+		return result, nil
+	case yaml.MappingNode:
+		return convertMapping(n, g)
+	default:
+		return nil, newNodeError(n, "unknown YAML node")
+	}
+}
+
+// convertMapping converts a mapping node into a map, including the
+// values of merge keys ("<<"). Keys set directly in the mapping take
+// precedence over merged keys.
+func convertMapping(n *yaml.Node, g *aliasGuard) (interface{}, error) {
+	result := make(map[string]interface{})
+	lines := make(map[string]int)
+	var merges []*yaml.Node
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind == yaml.ScalarNode && key.Tag == "!!merge" {
+			merges = append(merges, value)
+			continue
+		}
+
+		if key.Kind != yaml.ScalarNode {
+			return nil, newNodeError(key, "keys must be strings")
+		}
+
+		if line, ok := lines[key.Value]; ok {
+			return nil, newNodeError(key,
+				"key '%s' already defined at line %d", key.Value, line)
+		}
+
+		v, err := convertNode(value, g)
+		if err != nil {
+			return nil, err
+		}
+
+		result[key.Value] = v
+		lines[key.Value] = key.Line
+	}
+
+	for _, merge := range merges {
+		sources := []*yaml.Node{merge}
+		if merge.Kind == yaml.SequenceNode {
+			sources = merge.Content
+		}
+
+		for _, source := range sources {
+			v, err := convertNode(source, g)
+			if err != nil {
+				return nil, err
+			}
+
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, newNodeError(source, "merge value must be a mapping")
+			}
+
+			for k, v := range m {
+				if _, ok := result[k]; !ok {
+					result[k] = v
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// convertScalar converts a scalar node into a float64, bool, nil or
+// string, depending on its tag.
+func convertScalar(n *yaml.Node) (interface{}, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool", "!!int", "!!float":
+		var result interface{}
+		if err := n.Decode(&result); err != nil {
+			return nil, newNodeError(n, "%s", err)
+		}
+
+		switch v := result.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case uint64:
+			return float64(v), nil
+		default:
+			return v, nil
+		}
+	default:
+		return n.Value, nil
+	}
+}
+
+// sourceLine returns the given line of the data, counting from 1.
+func sourceLine(data []byte, line int) string {
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	return lines[line-1]
+}
@@ -0,0 +1,132 @@
+package yaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsYAML(t *testing.T) {
+	cases := []struct {
+		Path   string
+		Data   string
+		Result bool
+	}{
+		{"template.yml", "{}", true},
+		{"template.YAML", "", true},
+		{"template.json", "builders: []", false},
+		{"template", "  {\"builders\": []}", false},
+		{"-", "builders: []", true},
+		{"", "", false},
+	}
+
+	for _, tc := range cases {
+		if IsYAML(tc.Path, []byte(tc.Data)) != tc.Result {
+			t.Fatalf("bad: %#v", tc)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	data := `
+# Comments are allowed
+defaults: &defaults
+  ssh_username: packer
+  disk_size: 20000
+
+builders:
+  - <<: *defaults
+    type: virtualbox-iso
+    disk_size: 40000
+    headless: true
+    boot_wait: 10s
+    date: 2014-01-02
+    boot_command:
+      - "<esc><wait>"
+      - >-
+        linux
+        noapic
+`
+
+	var result interface{}
+	if err := Unmarshal([]byte(data), &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	builder := result.(map[string]interface{})["builders"].([]interface{})[0]
+	expected := map[string]interface{}{
+		"ssh_username": "packer",
+		"type":         "virtualbox-iso",
+		"disk_size":    float64(40000),
+		"headless":     true,
+		"boot_wait":    "10s",
+		"date":         "2014-01-02",
+		"boot_command": []interface{}{"<esc><wait>", "linux noapic"},
+	}
+
+	if !reflect.DeepEqual(builder, expected) {
+		t.Fatalf("bad: %#v", builder)
+	}
+}
+
+func TestUnmarshal_struct(t *testing.T) {
+	var result struct {
+		Foo []string
+	}
+
+	if err := Unmarshal([]byte("foo: [a, b]"), &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(result.Foo, []string{"a", "b"}) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestUnmarshal_aliasExpansions(t *testing.T) {
+	// Every level expands the aliases of the one before it nine times
+	data := "a: &a [x, x, x, x, x, x, x, x, x]\n"
+	for i := 'b'; i <= 'j'; i++ {
+		prev := string(i - 1)
+		data += fmt.Sprintf("%c: &%c [*%s, *%s, *%s, *%s, *%s, *%s, *%s, *%s, *%s]\n",
+			i, i, prev, prev, prev, prev, prev, prev, prev, prev, prev)
+	}
+
+	var result interface{}
+	err := Unmarshal([]byte(data), &result)
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	if !strings.Contains(err.Error(), "expands more than 10000 aliases") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if _, err := Positions([]byte(data)); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	cases := map[string]string{
+		"foo: bar\n  baz: [\nqux: 1\n": "Error in line 2, char 3:",
+		"foo: @bar\n":                  "Error in line 1, char 1: found character",
+		"foo: &x\n  bar: *x\n":         "Error in line 2, char 8: alias 'x' refers to a value that contains it",
+		"foo: &x\n  <<: *x\n":          "Error in line 2, char 7: alias 'x' refers to a value that contains it",
+		"foo: 1\nfoo: 2\n":             "Error in line 2, char 1: key 'foo' already defined at line 1",
+		"foo:\n  [a]: b\n":             "Error in line 2, char 3: keys must be strings",
+	}
+
+	for data, expected := range cases {
+		var result interface{}
+		err := Unmarshal([]byte(data), &result)
+		if err == nil {
+			t.Fatalf("should error: %s", data)
+		}
+
+		if !strings.HasPrefix(err.Error(), expected) {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// AgentConn connects to the local SSH agent at SSH_AUTH_SOCK.
+func AgentConn() (net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set, so there is no SSH agent to use")
+	}
+
+	return net.Dial("unix", socket)
+}
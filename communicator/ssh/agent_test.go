@@ -0,0 +1,165 @@
+// +build !race
+
+package ssh
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testAgent starts an agent that answers the first request of every
+// connection with a fixed response, and returns its socket.
+func testAgent(t *testing.T, dir string) string {
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	go func() {
+		defer l.Close()
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			buf := make([]byte, 4)
+			io.ReadFull(c, buf)
+			io.WriteString(c, "pong")
+			c.Close()
+		}
+	}()
+
+	return socket
+}
+
+// newMockAgentServer starts a server that asks the agent forwarded by the
+// first session for a response, which is sent on the returned channel.
+func newMockAgentServer(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resultCh := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		conn, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+		if err != nil {
+			t.Logf("Handshaking error: %v", err)
+			return
+		}
+		defer conn.Close()
+		go ssh.DiscardRequests(reqs)
+
+		newChannel, ok := <-chans
+		if !ok {
+			return
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer channel.Close()
+
+		for req := range requests {
+			req.Reply(true, nil)
+			if req.Type != "auth-agent-req@openssh.com" {
+				continue
+			}
+
+			go func() {
+				agent, agentReqs, err := conn.OpenChannel("auth-agent@openssh.com", nil)
+				if err != nil {
+					resultCh <- err.Error()
+					return
+				}
+				defer agent.Close()
+				go ssh.DiscardRequests(agentReqs)
+
+				io.WriteString(agent, "ping")
+				data, _ := ioutil.ReadAll(agent)
+				resultCh <- string(data)
+			}()
+		}
+	}()
+
+	return l.Addr().String(), resultCh
+}
+
+func TestAgentConn(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("SSH_AUTH_SOCK", "")
+	if _, err := AgentConn(); err == nil {
+		t.Fatal("should have error")
+	}
+
+	os.Setenv("SSH_AUTH_SOCK", testAgent(t, td))
+	c, err := AgentConn()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c.Close()
+}
+
+func TestForwardAgent(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("SSH_AUTH_SOCK", testAgent(t, td))
+
+	addr, resultCh := newMockAgentServer(t)
+	config := &Config{
+		Connection: ConnectFunc("tcp", addr),
+		SSHConfig: &ssh.ClientConfig{
+			User: "user",
+			Auth: []ssh.AuthMethod{ssh.Password("pass")},
+		},
+		NoPty:        true,
+		ForwardAgent: true,
+	}
+
+	client, err := New(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cmd := &packer.RemoteCmd{Command: "ssh-add -l"}
+	if err := client.Start(cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	select {
+	case result := <-resultCh:
+		if result != "pong" {
+			t.Fatalf("bad: %#v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent was not forwarded")
+	}
+}
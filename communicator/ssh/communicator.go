@@ -3,22 +3,26 @@ package ssh
 import (
 	"bufio"
 	"bytes"
-	"code.google.com/p/go.crypto/ssh"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/packer/packer"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 type comm struct {
-	client *ssh.ClientConn
+	client *ssh.Client
 	config *Config
 	conn   net.Conn
 }
@@ -35,6 +39,14 @@ type Config struct {
 
 	// NoPty, if true, will not request a pty from the remote end.
 	NoPty bool
+
+	// UseSFTP, if true, transfers files over SFTP instead of SCP, so
+	// that the remote end doesn't need an scp binary.
+	UseSFTP bool
+
+	// ForwardAgent, if true, forwards the local SSH agent at SSH_AUTH_SOCK
+	// into the sessions of the commands that are started.
+	ForwardAgent bool
 }
 
 // Creates a new packer.Communicator implementation over SSH. This takes
@@ -77,6 +89,12 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 		}
 	}
 
+	if c.config.ForwardAgent {
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			log.Printf("remote end refused to forward the SSH agent: %s", err)
+		}
+	}
+
 	log.Printf("starting remote command: %s", cmd.Command)
 	err = session.Start(cmd.Command + "\n")
 	if err != nil {
@@ -87,6 +105,7 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 	doneCh := make(chan struct{})
 	sessionLock := new(sync.Mutex)
 	timedOut := false
+	killed := false
 
 	// Start a goroutine to wait for the session to end and set the
 	// exit boolean and status.
@@ -110,11 +129,35 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 			exitStatus = -1
 		}
 
+		if killed {
+			// We killed the command, so it didn't exit on its own
+			exitStatus = -1
+		}
+
 		log.Printf("remote command exited with '%d': %s", exitStatus, cmd.Command)
 		cmd.SetExited(exitStatus)
 		close(doneCh)
 	}()
 
+	// Start a goroutine to kill the command if we're asked to. Not every
+	// SSH server supports signals, so the session is closed as well,
+	// which hangs up the PTY of the command.
+	go func() {
+		select {
+		case <-cmd.KillCh():
+		case <-doneCh:
+			return
+		}
+
+		sessionLock.Lock()
+		defer sessionLock.Unlock()
+
+		log.Printf("killing remote command: %s", cmd.Command)
+		killed = true
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+	}()
+
 	go func() {
 		failures := 0
 		for {
@@ -158,6 +201,10 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 }
 
 func (c *comm) Upload(path string, input io.Reader) error {
+	if c.config.UseSFTP {
+		return c.sftpUpload(path, input)
+	}
+
 	// The target directory and file for talking the SCP protocol
 	target_dir := filepath.Dir(path)
 	target_file := filepath.Base(path)
@@ -176,6 +223,10 @@ func (c *comm) Upload(path string, input io.Reader) error {
 
 func (c *comm) UploadDir(dst string, src string, excl []string) error {
 	log.Printf("Upload dir '%s' to '%s'", src, dst)
+	if c.config.UseSFTP {
+		return c.sftpUploadDir(dst, src, excl)
+	}
+
 	scpFunc := func(w io.Writer, r *bufio.Reader) error {
 		uploadEntries := func() error {
 			f, err := os.Open(src)
@@ -204,8 +255,29 @@ func (c *comm) UploadDir(dst string, src string, excl []string) error {
 	return c.scpSession("scp -rvt "+dst, scpFunc)
 }
 
-func (c *comm) Download(string, io.Writer) error {
-	panic("not implemented yet")
+func (c *comm) Download(path string, output io.Writer) error {
+	if c.config.UseSFTP {
+		return c.sftpDownload(path, output)
+	}
+
+	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
+		return scpDownloadFile(output, w, stdoutR)
+	}
+
+	return c.scpSession("scp -vf "+path, scpFunc)
+}
+
+func (c *comm) DownloadDir(src string, dst string, excl []string) error {
+	log.Printf("Download dir '%s' to '%s'", src, dst)
+	if c.config.UseSFTP {
+		return c.sftpDownloadDir(src, dst, excl)
+	}
+
+	scpFunc := func(w io.Writer, r *bufio.Reader) error {
+		return scpDownloadDir(dst, excl, w, r)
+	}
+
+	return c.scpSession("scp -rvf "+src, scpFunc)
 }
 
 func (c *comm) newSession() (session *ssh.Session, err error) {
@@ -253,9 +325,20 @@ func (c *comm) reconnect() (err error) {
 	}
 
 	log.Printf("handshaking with SSH")
-	c.client, err = ssh.Client(c.conn, c.config.SSHConfig)
+	c.client, err = newClient(c.conn, c.config.SSHConfig)
 	if err != nil {
 		log.Printf("handshake error: %s", err)
+		return
+	}
+
+	if c.config.ForwardAgent {
+		// Every agent channel that the remote end opens gets its own
+		// connection to the local agent.
+		err = agent.ForwardToRemote(c.client, os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			log.Printf("error forwarding SSH agent: %s", err)
+			return
+		}
 	}
 
 	return
@@ -497,3 +580,199 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 
 	return nil
 }
+
+// scpHeader is a message that the source side of SCP sends to the sink,
+// which starts a file ('C'), starts a directory ('D'), ends a directory
+// ('E') or sets the times of the next file or directory ('T').
+type scpHeader struct {
+	Kind byte
+	Mode os.FileMode
+	Size int64
+	Name string
+}
+
+// readSCPHeader reads the next message from the source side of SCP. The
+// warnings and errors that the source sends instead are returned as errors.
+func readSCPHeader(r *bufio.Reader) (*scpHeader, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	if line == "" {
+		return nil, errors.New("Empty SCP message")
+	}
+
+	header := &scpHeader{Kind: line[0]}
+	switch header.Kind {
+	case '\x01', '\x02':
+		return nil, errors.New(line[1:])
+	case 'E', 'T':
+		return header, nil
+	case 'C', 'D':
+	default:
+		return nil, fmt.Errorf("Unexpected SCP message: %q", line)
+	}
+
+	parts := strings.SplitN(line[1:], " ", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Bad SCP message: %q", line)
+	}
+
+	mode, err := strconv.ParseUint(parts[0], 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Bad mode in SCP message %q: %s", line, err)
+	}
+
+	header.Size, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || header.Size < 0 {
+		return nil, fmt.Errorf("Bad size in SCP message: %q", line)
+	}
+
+	// The name must be a single path element, so that a misbehaving
+	// remote side can't write outside of the destination.
+	header.Mode = os.FileMode(mode) & os.ModePerm
+	header.Name = parts[2]
+	if header.Name == "" || header.Name == "." || header.Name == ".." ||
+		strings.ContainsAny(header.Name, "/\\") {
+		return nil, fmt.Errorf("Bad name in SCP message: %q", line)
+	}
+
+	return header, nil
+}
+
+// scpReadFile reads the contents of a file whose header was just read
+// from the source side of SCP into dst.
+func scpReadFile(dst io.Writer, size int64, w io.Writer, r *bufio.Reader) error {
+	fmt.Fprint(w, "\x00")
+	if _, err := io.CopyN(dst, r, size); err != nil {
+		return err
+	}
+
+	if err := checkSCPStatus(r); err != nil {
+		return err
+	}
+
+	fmt.Fprint(w, "\x00")
+	return nil
+}
+
+func scpDownloadFile(dst io.Writer, w io.Writer, r *bufio.Reader) error {
+	// Tell the source side that we're ready
+	log.Println("Beginning file download...")
+	fmt.Fprint(w, "\x00")
+
+	for {
+		header, err := readSCPHeader(r)
+		if err != nil {
+			return err
+		}
+
+		switch header.Kind {
+		case 'T':
+			fmt.Fprint(w, "\x00")
+		case 'C':
+			return scpReadFile(dst, header.Size, w, r)
+		default:
+			return errors.New("Can't download a directory as a file")
+		}
+	}
+}
+
+func scpDownloadDir(dst string, exclude []string, w io.Writer, r *bufio.Reader) error {
+	// A directory that is being downloaded, where rel is its path
+	// relative to the source directory.
+	type scpDir struct {
+		path     string
+		rel      string
+		excluded bool
+	}
+
+	// The first directory that the source side sends is the source
+	// directory itself, whose contents go into the destination.
+	log.Printf("SCP: starting directory download: %s", dst)
+	fmt.Fprint(w, "\x00")
+
+	var stack []scpDir
+	for {
+		header, err := readSCPHeader(r)
+		if err != nil {
+			if err == io.EOF && len(stack) == 0 {
+				return nil
+			}
+
+			return err
+		}
+
+		parent := scpDir{path: dst}
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+
+		dir := parent
+		if header.Kind == 'C' || (header.Kind == 'D' && len(stack) > 0) {
+			dir = scpDir{
+				path:     filepath.Join(parent.path, header.Name),
+				rel:      path.Join(parent.rel, header.Name),
+				excluded: parent.excluded,
+			}
+
+			if excluded(dir.rel, exclude) {
+				dir.excluded = true
+			}
+		}
+
+		switch header.Kind {
+		case 'T':
+		case 'D':
+			if !dir.excluded {
+				if err := os.MkdirAll(dir.path, header.Mode|0700); err != nil {
+					return err
+				}
+			}
+
+			stack = append(stack, dir)
+		case 'E':
+			if len(stack) == 0 {
+				return errors.New("Unexpected end of directory in SCP")
+			}
+
+			stack = stack[:len(stack)-1]
+		case 'C':
+			if dir.excluded {
+				if err := scpReadFile(ioutil.Discard, header.Size, w, r); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := os.MkdirAll(parent.path, 0755); err != nil {
+				return err
+			}
+
+			f, err := os.OpenFile(
+				dir.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.Mode)
+			if err != nil {
+				return err
+			}
+
+			err = scpReadFile(f, header.Size, w, r)
+			f.Close()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		fmt.Fprint(w, "\x00")
+		if header.Kind == 'E' && len(stack) == 0 {
+			return nil
+		}
+	}
+}
@@ -3,10 +3,16 @@
 package ssh
 
 import (
+	"bufio"
 	"bytes"
-	"code.google.com/p/go.crypto/ssh"
+	"errors"
 	"github.com/mitchellh/packer/packer"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -39,28 +45,28 @@ gqnBycHj6AhEycjda75cs+0zybZvN4x65KZHOGW/O/7OAWEcZP5TPb3zf9ned3Hl
 NsZoFj52ponUM6+99A2CmezFCN16c4mbA//luWF+k3VVqR6BpkrhKw==
 -----END RSA PRIVATE KEY-----`
 
-// password implements the ClientPassword interface
-type password string
-
-func (p password) Password(user string) (string, error) {
-	return string(p), nil
-}
-
 var serverConfig = &ssh.ServerConfig{
-	PasswordCallback: func(c *ssh.ServerConn, user, pass string) bool {
-		return user == "user" && pass == "pass"
+	PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+		if c.User() == "user" && string(pass) == "pass" {
+			return nil, nil
+		}
+
+		return nil, errors.New("invalid password")
 	},
 }
 
 func init() {
 	// Set the private key of the server, required to accept connections
-	if err := serverConfig.SetRSAPrivateKey([]byte(testServerPrivateKey)); err != nil {
-		panic("unable to set private key: " + err.Error())
+	signer, err := ssh.ParsePrivateKey([]byte(testServerPrivateKey))
+	if err != nil {
+		panic("unable to parse private key: " + err.Error())
 	}
+
+	serverConfig.AddHostKey(signer)
 }
 
 func newMockLineServer(t *testing.T) string {
-	l, err := ssh.Listen("tcp", "127.0.0.1:0", serverConfig)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("unable to newMockAuthServer: %s", err)
 	}
@@ -71,8 +77,10 @@ func newMockLineServer(t *testing.T) string {
 			t.Errorf("Unable to accept incoming connection: %v", err)
 			return
 		}
+		defer c.Close()
 
-		if err := c.Handshake(); err != nil {
+		conn, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+		if err != nil {
 			// not Errorf because this is expected to
 			// fail for some tests.
 			t.Logf("Handshaking error: %v", err)
@@ -80,21 +88,25 @@ func newMockLineServer(t *testing.T) string {
 		}
 
 		t.Log("Accepted SSH connection")
-		defer c.Close()
+		defer conn.Close()
 
-		channel, err := c.Accept()
+		// Just discard the global requests now, we need to
+		// do this to handle packets for SSH.
+		go ssh.DiscardRequests(reqs)
+
+		newChannel, ok := <-chans
+		if !ok {
+			t.Errorf("Unable to accept a channel")
+			return
+		}
+
+		channel, requests, err := newChannel.Accept()
 		if err != nil {
 			t.Errorf("Unable to accept a channel: %s", err)
 			return
 		}
+		go ssh.DiscardRequests(requests)
 
-		// Just go in a loop now accepting things... we need to
-		// do this to handle packets for SSH.
-		go func() {
-			c.Accept()
-		}()
-
-		channel.Accept()
 		t.Log("Accepted channel")
 		defer channel.Close()
 	}()
@@ -112,8 +124,8 @@ func TestCommIsCommunicator(t *testing.T) {
 func TestNew_Invalid(t *testing.T) {
 	clientConfig := &ssh.ClientConfig{
 		User: "user",
-		Auth: []ssh.ClientAuth{
-			ssh.ClientAuthPassword(password("i-am-invalid")),
+		Auth: []ssh.AuthMethod{
+			ssh.Password("i-am-invalid"),
 		},
 	}
 
@@ -139,8 +151,8 @@ func TestNew_Invalid(t *testing.T) {
 func TestStart(t *testing.T) {
 	clientConfig := &ssh.ClientConfig{
 		User: "user",
-		Auth: []ssh.ClientAuth{
-			ssh.ClientAuthPassword(password("pass")),
+		Auth: []ssh.AuthMethod{
+			ssh.Password("pass"),
 		},
 	}
 
@@ -169,3 +181,93 @@ func TestStart(t *testing.T) {
 
 	client.Start(&cmd)
 }
+
+func TestSCPDownloadFile(t *testing.T) {
+	source := "T1390000000 0 1390000000 0\nC0644 6 foo.txt\nhello\n\x00"
+	r := bufio.NewReader(strings.NewReader(source))
+	w := new(bytes.Buffer)
+	dst := new(bytes.Buffer)
+
+	if err := scpDownloadFile(dst, w, r); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if dst.String() != "hello\n" {
+		t.Fatalf("bad: %q", dst.String())
+	}
+
+	// One acknowledgement to start, and one for each message and file
+	if w.String() != "\x00\x00\x00\x00" {
+		t.Fatalf("bad: %q", w.String())
+	}
+}
+
+func TestSCPDownloadFile_error(t *testing.T) {
+	source := "\x01scp: /foo: No such file or directory\n"
+	r := bufio.NewReader(strings.NewReader(source))
+
+	err := scpDownloadFile(new(bytes.Buffer), new(bytes.Buffer), r)
+	if err == nil || err.Error() != "scp: /foo: No such file or directory" {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestSCPDownloadDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	source := "D0755 0 src\n" +
+		"C0644 3 a.txt\nfoo\x00" +
+		"D0700 0 sub\n" +
+		"C0600 3 b.txt\nbar\x00" +
+		"E\n" +
+		"D0755 0 skip\n" +
+		"C0644 3 c.txt\nbaz\x00" +
+		"E\n" +
+		"E\n"
+	r := bufio.NewReader(strings.NewReader(source))
+	dst := filepath.Join(td, "dst")
+
+	err = scpDownloadDir(dst, []string{"skip"}, new(bytes.Buffer), r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"a.txt":     "foo",
+		"sub/b.txt": "bar",
+	}
+	for name, contents := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if string(data) != contents {
+			t.Fatalf("bad %s: %q", name, data)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "skip")); !os.IsNotExist(err) {
+		t.Fatalf("excluded directory should not exist: %v", err)
+	}
+}
+
+func TestSCPDownloadDir_badName(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	source := "D0755 0 src\nC0644 3 ../a.txt\nfoo\x00E\n"
+	r := bufio.NewReader(strings.NewReader(source))
+
+	err = scpDownloadDir(td, nil, new(bytes.Buffer), r)
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -1,7 +1,17 @@
 package ssh
 
 import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
 	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -22,3 +32,307 @@ func ConnectFunc(network, addr string) func() (net.Conn, error) {
 		return c, nil
 	}
 }
+
+// newClient starts an SSH client over the connection. Packer doesn't know
+// the host keys of the machines it creates, so any host key is accepted
+// unless the configuration checks them.
+func newClient(c net.Conn, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if config.HostKeyCallback == nil {
+		copied := *config
+		copied.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		config = &copied
+	}
+
+	conn, chans, reqs, err := ssh.NewClientConn(c, c.RemoteAddr().String(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewClient(conn, chans, reqs), nil
+}
+
+// BastionConnectFunc returns a function that connects to the remote end
+// through an SSH bastion host, which is reached with the given function.
+// Every connection uses its own connection to the bastion host, which is
+// closed along with it.
+func BastionConnectFunc(bastion func() (net.Conn, error), config *ssh.ClientConfig, addr string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		bc, err := bastion()
+		if err != nil {
+			return nil, fmt.Errorf("Error connecting to bastion: %s", err)
+		}
+
+		client, err := newClient(bc, config)
+		if err != nil {
+			bc.Close()
+			return nil, fmt.Errorf("Error connecting to bastion: %s", err)
+		}
+
+		c, err := client.Dial("tcp", addr)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf(
+				"Error connecting to %s through bastion: %s", addr, err)
+		}
+
+		return &bastionConn{Conn: c, client: client}, nil
+	}
+}
+
+// ProxyConnectFunc returns a function that connects to the remote end
+// through the proxy at the given URL. The "socks5" scheme is a SOCKS5
+// proxy and the "http" scheme is an HTTP proxy that supports the CONNECT
+// method. The user info of the URL, if any, is used to authenticate.
+func ProxyConnectFunc(proxyURL *url.URL, addr string) (func() (net.Conn, error), error) {
+	var handshake func(net.Conn, *url.Userinfo, string) (net.Conn, error)
+	switch proxyURL.Scheme {
+	case "socks5":
+		handshake = socks5Handshake
+	case "http":
+		handshake = httpConnectHandshake
+	default:
+		return nil, fmt.Errorf("Unsupported proxy scheme: %s", proxyURL.Scheme)
+	}
+
+	proxyAddr := ProxyAddress(proxyURL)
+	connect := ConnectFunc("tcp", proxyAddr)
+	return func() (net.Conn, error) {
+		c, err := connect()
+		if err != nil {
+			return nil, fmt.Errorf("Error connecting to proxy %s: %s", proxyAddr, err)
+		}
+
+		c.SetDeadline(time.Now().Add(15 * time.Second))
+		result, err := handshake(c, proxyURL.User, addr)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf(
+				"Error connecting to %s through proxy %s: %s", addr, proxyAddr, err)
+		}
+		c.SetDeadline(time.Time{})
+
+		return result, nil
+	}, nil
+}
+
+// ProxyAddress returns the host and port of the proxy at the given URL,
+// using the default port of the scheme if the URL has none.
+func ProxyAddress(proxyURL *url.URL) string {
+	if _, _, err := net.SplitHostPort(proxyURL.Host); err == nil {
+		return proxyURL.Host
+	}
+
+	port := "1080"
+	if proxyURL.Scheme == "http" {
+		port = "80"
+	}
+
+	// Without a port, an IPv6 host is still in brackets
+	host := strings.TrimSuffix(strings.TrimPrefix(proxyURL.Host, "["), "]")
+	return net.JoinHostPort(host, port)
+}
+
+// bastionConn is a connection through a bastion host, which closes the
+// connection to the bastion host when it is closed.
+type bastionConn struct {
+	net.Conn
+	client *ssh.Client
+}
+
+func (c *bastionConn) Close() error {
+	err := c.Conn.Close()
+	c.client.Close()
+	return err
+}
+
+// bufferedConn is a connection that reads through a buffered reader,
+// which may already hold data read from the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// httpConnectHandshake asks an HTTP proxy to connect to addr with the
+// CONNECT method.
+func httpConnectHandshake(c net.Conn, user *url.Userinfo, addr string) (net.Conn, error) {
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString(
+			[]byte(user.Username() + ":" + password))
+		req += fmt.Sprintf("Proxy-Authorization: Basic %s\r\n", auth)
+	}
+	req += "\r\n"
+
+	if _, err := io.WriteString(c, req); err != nil {
+		return nil, err
+	}
+
+	// The proxy may send data of the remote end right after the response,
+	// so the connection keeps reading from the same buffer.
+	r := bufio.NewReader(c)
+	resp, err := http.ReadResponse(r, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("proxy responded with: %s", resp.Status)
+	}
+
+	return &bufferedConn{Conn: c, r: r}, nil
+}
+
+// The SOCKS5 protocol values, from RFC 1928 and RFC 1929.
+const (
+	socks5Version          = 5
+	socks5AuthNone         = 0
+	socks5AuthPassword     = 2
+	socks5AuthNoAcceptable = 0xff
+	socks5Connect          = 1
+	socks5AddrIPv4         = 1
+	socks5AddrDomain       = 3
+	socks5AddrIPv6         = 4
+)
+
+var socks5Errors = []string{
+	"",
+	"general failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+// socks5Handshake asks a SOCKS5 proxy to connect to addr.
+func socks5Handshake(c net.Conn, user *url.Userinfo, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return nil, fmt.Errorf("invalid port: %s", portStr)
+	}
+
+	methods := []byte{socks5AuthNone}
+	if user != nil {
+		methods = append(methods, socks5AuthPassword)
+	}
+
+	buf := []byte{socks5Version, byte(len(methods))}
+	buf = append(buf, methods...)
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(c, reply); err != nil {
+		return nil, err
+	}
+
+	if reply[0] != socks5Version {
+		return nil, fmt.Errorf("unexpected SOCKS version: %d", reply[0])
+	}
+
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if user == nil {
+			return nil, errors.New("proxy requires a username and password")
+		}
+
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return nil, errors.New("username or password is too long")
+		}
+
+		buf = []byte{1, byte(len(user.Username()))}
+		buf = append(buf, user.Username()...)
+		buf = append(buf, byte(len(password)))
+		buf = append(buf, password...)
+		if _, err := c.Write(buf); err != nil {
+			return nil, err
+		}
+
+		if _, err := io.ReadFull(c, reply); err != nil {
+			return nil, err
+		}
+
+		if reply[1] != 0 {
+			return nil, errors.New("proxy rejected the username and password")
+		}
+	case socks5AuthNoAcceptable:
+		return nil, errors.New("proxy accepted none of the authentication methods")
+	default:
+		return nil, fmt.Errorf("proxy chose an unknown authentication method: %d", reply[1])
+	}
+
+	buf = []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			buf = append(buf, socks5AddrIPv4)
+			buf = append(buf, ip4...)
+		} else {
+			buf = append(buf, socks5AddrIPv6)
+			buf = append(buf, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name is too long: %s", host)
+		}
+
+		buf = append(buf, socks5AddrDomain, byte(len(host)))
+		buf = append(buf, host...)
+	}
+	buf = append(buf, byte(port>>8), byte(port))
+
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c, header); err != nil {
+		return nil, err
+	}
+
+	if header[1] != 0 {
+		msg := fmt.Sprintf("unknown error %d", header[1])
+		if int(header[1]) < len(socks5Errors) {
+			msg = socks5Errors[header[1]]
+		}
+
+		return nil, fmt.Errorf("proxy failed to connect: %s", msg)
+	}
+
+	// Skip the address the proxy bound to, and its port
+	var skip int
+	switch header[3] {
+	case socks5AddrIPv4:
+		skip = net.IPv4len + 2
+	case socks5AddrIPv6:
+		skip = net.IPv6len + 2
+	case socks5AddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(c, length); err != nil {
+			return nil, err
+		}
+		skip = int(length[0]) + 2
+	default:
+		return nil, fmt.Errorf("unknown address type: %d", header[3])
+	}
+
+	if _, err := io.ReadFull(c, make([]byte, skip)); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,238 @@
+package ssh
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// testTarget starts a server that greets every connection, the way an
+// SSH server sends its version first, and returns its address.
+func testTarget(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			io.WriteString(c, "hello")
+			c.Close()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// testSOCKS5Proxy starts a SOCKS5 proxy that requires the username and
+// password "user" and "pass", and returns its address.
+func testSOCKS5Proxy(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(c net.Conn) {
+				defer c.Close()
+
+				buf := make([]byte, 2)
+				io.ReadFull(c, buf)
+				io.ReadFull(c, make([]byte, buf[1]))
+				c.Write([]byte{5, socks5AuthPassword})
+
+				io.ReadFull(c, buf)
+				user := make([]byte, buf[1])
+				io.ReadFull(c, user)
+				io.ReadFull(c, buf[:1])
+				pass := make([]byte, buf[0])
+				io.ReadFull(c, pass)
+				if string(user) != "user" || string(pass) != "pass" {
+					c.Write([]byte{1, 1})
+					return
+				}
+				c.Write([]byte{1, 0})
+
+				header := make([]byte, 4)
+				io.ReadFull(c, header)
+				addr := make([]byte, 4)
+				io.ReadFull(c, addr)
+				port := make([]byte, 2)
+				io.ReadFull(c, port)
+
+				target := net.JoinHostPort(
+					net.IP(addr).String(),
+					strconv.Itoa(int(port[0])<<8|int(port[1])))
+				tc, err := net.Dial("tcp", target)
+				if err != nil {
+					c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer tc.Close()
+
+				c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+				io.Copy(c, tc)
+			}(c)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// testHTTPProxy starts an HTTP proxy that supports the CONNECT method
+// and returns its address.
+func testHTTPProxy(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(c net.Conn) {
+				defer c.Close()
+
+				req, err := http.ReadRequest(bufio.NewReader(c))
+				if err != nil || req.Method != "CONNECT" {
+					io.WriteString(c, "HTTP/1.1 400 Bad Request\r\n\r\n")
+					return
+				}
+
+				tc, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(c, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer tc.Close()
+
+				// Send the data of the target along with the response
+				data, _ := ioutil.ReadAll(tc)
+				io.WriteString(c, "HTTP/1.1 200 OK\r\n\r\n"+string(data))
+			}(c)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func testProxyConnect(t *testing.T, rawurl string, addr string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	connFunc, err := ProxyConnectFunc(u, addr)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c, err := connFunc()
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	data, err := ioutil.ReadAll(c)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return string(data), nil
+}
+
+func TestProxyConnectFunc_socks5(t *testing.T) {
+	target := testTarget(t)
+	proxy := testSOCKS5Proxy(t)
+
+	data, err := testProxyConnect(t, "socks5://user:pass@"+proxy, target)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if data != "hello" {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	_, err = testProxyConnect(t, "socks5://user:bad@"+proxy, target)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	_, err = testProxyConnect(t, "socks5://"+proxy, target)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestProxyConnectFunc_http(t *testing.T) {
+	target := testTarget(t)
+	proxy := testHTTPProxy(t)
+
+	data, err := testProxyConnect(t, "http://"+proxy, target)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if data != "hello" {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	_, err = testProxyConnect(t, "http://"+proxy, "127.0.0.1:1")
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestProxyAddress(t *testing.T) {
+	cases := map[string]string{
+		"socks5://127.0.0.1":      "127.0.0.1:1080",
+		"socks5://127.0.0.1:1234": "127.0.0.1:1234",
+		"http://proxy":            "proxy:80",
+		"http://u:p@proxy:3128":   "proxy:3128",
+		"socks5://[::1]":          "[::1]:1080",
+		"http://[::1]:3128":       "[::1]:3128",
+	}
+
+	for raw, expected := range cases {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if actual := ProxyAddress(u); actual != expected {
+			t.Fatalf("%s: bad: %s", raw, actual)
+		}
+	}
+}
+
+func TestProxyConnectFunc_badScheme(t *testing.T) {
+	u, err := url.Parse("ftp://127.0.0.1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := ProxyConnectFunc(u, "127.0.0.1:22"); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -1,19 +1,23 @@
 package ssh
 
 import (
-	"code.google.com/p/go.crypto/ssh"
 	"crypto"
 	"crypto/dsa"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"golang.org/x/crypto/ssh"
 	"io"
 )
 
 // SimpleKeychain makes it easy to use private keys in order to connect
 // via SSH, since the interface exposed by Go isn't the easiest to use
 // right away.
+//
+// Deprecated: The SSH package authenticates with signers rather than a
+// keyring. Use ssh.ParsePrivateKey and ssh.PublicKeys, or the Signers of
+// the keychain with ssh.PublicKeysCallback.
 type SimpleKeychain struct {
 	keys []interface{}
 }
@@ -53,7 +57,7 @@ func (k *SimpleKeychain) AddPEMKeyPassword(key string, password string) (err err
 	return
 }
 
-// Key method for ssh.ClientKeyring interface
+// Key returns the public key of the i-th key of the keychain.
 func (k *SimpleKeychain) Key(i int) (ssh.PublicKey, error) {
 	if i < 0 || i >= len(k.keys) {
 		return nil, nil
@@ -67,7 +71,7 @@ func (k *SimpleKeychain) Key(i int) (ssh.PublicKey, error) {
 	panic("unknown key type")
 }
 
-// Sign method for ssh.ClientKeyring interface
+// Sign signs the data with the i-th key of the keychain.
 func (k *SimpleKeychain) Sign(i int, rand io.Reader, data []byte) (sig []byte, err error) {
 	hashFunc := crypto.SHA1
 	h := hashFunc.New()
@@ -79,3 +83,19 @@ func (k *SimpleKeychain) Sign(i int, rand io.Reader, data []byte) (sig []byte, e
 	}
 	return nil, errors.New("ssh: unknown key type")
 }
+
+// Signers returns the keys of the keychain as signers, which can be used
+// with ssh.PublicKeysCallback.
+func (k *SimpleKeychain) Signers() ([]ssh.Signer, error) {
+	signers := make([]ssh.Signer, 0, len(k.keys))
+	for _, key := range k.keys {
+		signer, err := ssh.NewSignerFromKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	return signers, nil
+}
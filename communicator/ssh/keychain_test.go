@@ -1,7 +1,7 @@
 package ssh
 
 import (
-	"code.google.com/p/go.crypto/ssh"
+	"golang.org/x/crypto/ssh"
 	"testing"
 )
 
@@ -23,10 +23,27 @@ func TestAddPEMKey(t *testing.T) {
 	}
 }
 
-func TestSimpleKeyChain_ImplementsClientkeyring(t *testing.T) {
-	var raw interface{}
-	raw = &SimpleKeychain{}
-	if _, ok := raw.(ssh.ClientKeyring); !ok {
-		t.Fatal("SimpleKeychain is not a valid ssh.ClientKeyring")
+func TestSimpleKeychainSigners(t *testing.T) {
+	k := &SimpleKeychain{}
+	if err := k.AddPEMKey(testPrivateKey); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	signers, err := k.Signers()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(signers) != 1 {
+		t.Fatalf("bad: %#v", signers)
+	}
+
+	expected, err := k.Key(0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := signers[0].PublicKey()
+	if string(actual.Marshal()) != string(expected.Marshal()) {
+		t.Fatalf("bad: %s", ssh.MarshalAuthorizedKey(actual))
 	}
 }
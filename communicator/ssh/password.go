@@ -2,16 +2,18 @@ package ssh
 
 import "log"
 
-// An implementation of ssh.ClientPassword so that you can use a static
-// string password for the password to ClientAuthPassword.
+// Password is a static password that is returned for any user.
+//
+// Deprecated: The SSH package takes the password itself with ssh.Password.
 type Password string
 
 func (p Password) Password(user string) (string, error) {
 	return string(p), nil
 }
 
-// An implementation of ssh.ClientKeyboardInteractive that simply sends
-// back the password for all questions. The questions are logged.
+// PasswordKeyboardInteractive answers keyboard interactive challenges by
+// simply sending back the password for all questions. The questions are
+// logged. Its Challenge method is given to ssh.KeyboardInteractive.
 type PasswordKeyboardInteractive string
 
 func (p PasswordKeyboardInteractive) Challenge(user, instruction string, questions []string, echos []bool) ([]string, error) {
@@ -1,19 +1,11 @@
 package ssh
 
 import (
-	"code.google.com/p/go.crypto/ssh"
+	"golang.org/x/crypto/ssh"
 	"reflect"
 	"testing"
 )
 
-func TestPassword_Impl(t *testing.T) {
-	var raw interface{}
-	raw = Password("foo")
-	if _, ok := raw.(ssh.ClientPassword); !ok {
-		t.Fatal("Password must implement ClientPassword")
-	}
-}
-
 func TestPasswordPassword(t *testing.T) {
 	p := Password("foo")
 	result, err := p.Password("user")
@@ -27,11 +19,7 @@ func TestPasswordPassword(t *testing.T) {
 }
 
 func TestPasswordKeyboardInteractive_Impl(t *testing.T) {
-	var raw interface{}
-	raw = PasswordKeyboardInteractive("foo")
-	if _, ok := raw.(ssh.ClientKeyboardInteractive); !ok {
-		t.Fatal("PasswordKeyboardInteractive must implement ClientKeyboardInteractive")
-	}
+	var _ ssh.KeyboardInteractiveChallenge = PasswordKeyboardInteractive("foo").Challenge
 }
 
 func TestPasswordKeybardInteractive_Challenge(t *testing.T) {
@@ -0,0 +1,289 @@
+package ssh
+
+import (
+	"fmt"
+	"github.com/pkg/sftp"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sftpUpload uploads the data of the reader to a file with mode 0644, the
+// same as SCP does. The reader has no mode to keep, since it comes from a
+// plugin over RPC, so files that need another mode must be changed with a
+// command afterwards. Uploading a directory keeps the modes of its files.
+func (c *comm) sftpUpload(dst string, input io.Reader) error {
+	return c.sftpSession(func(client *sftp.Client) error {
+		return sftpUploadFile(client, dst, input, 0644)
+	})
+}
+
+func (c *comm) sftpUploadDir(dst string, src string, excl []string) error {
+	return c.sftpSession(func(client *sftp.Client) error {
+		fi, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+
+		// The same as with SCP, the directory itself is only created
+		// if there is no trailing slash on the source.
+		if !strings.HasSuffix(src, "/") {
+			dst = path.Join(filepath.ToSlash(dst), filepath.Base(src))
+			if err := sftpMkdir(client, dst, fi.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+
+		return sftpUploadDir(client, dst, src, "", excl)
+	})
+}
+
+func (c *comm) sftpDownload(src string, output io.Writer) error {
+	return c.sftpSession(func(client *sftp.Client) error {
+		f, err := client.Open(src)
+		if err != nil {
+			return fmt.Errorf("Error opening remote file %s: %s", src, err)
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(output, f); err != nil {
+			return fmt.Errorf("Error downloading %s: %s", src, err)
+		}
+
+		return nil
+	})
+}
+
+func (c *comm) sftpDownloadDir(src string, dst string, excl []string) error {
+	return c.sftpSession(func(client *sftp.Client) error {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+
+		return sftpDownloadDir(client, dst, src, "", excl)
+	})
+}
+
+// sftpSession starts the SFTP subsystem in a new SSH session and calls
+// the function with a client for it, closing both afterwards.
+func (c *comm) sftpSession(f func(*sftp.Client) error) error {
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdinW, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdoutR, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	log.Println("Starting remote SFTP subsystem")
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return fmt.Errorf(
+			"SFTP failed to start. This usually means that SFTP is not\n"+
+				"enabled on the remote system: %s", err)
+	}
+
+	client, err := sftp.NewClientPipe(stdoutR, stdinW)
+	if err != nil {
+		return fmt.Errorf("Error starting SFTP session: %s", err)
+	}
+	defer client.Close()
+
+	return f(client)
+}
+
+func sftpUploadFile(client *sftp.Client, dst string, src io.Reader, mode os.FileMode) error {
+	log.Printf("SFTP: uploading file: %s", dst)
+	f, err := client.Create(dst)
+	if err != nil {
+		return fmt.Errorf("Error creating remote file %s: %s", dst, err)
+	}
+
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Error uploading to %s: %s", dst, err)
+	}
+
+	if err := client.Chmod(dst, mode); err != nil {
+		return fmt.Errorf("Error setting the mode of %s: %s", dst, err)
+	}
+
+	return nil
+}
+
+// sftpMkdir creates a remote directory with the given mode, unless it
+// already exists.
+func sftpMkdir(client *sftp.Client, dir string, mode os.FileMode) error {
+	if fi, err := client.Stat(dir); err == nil {
+		if !fi.IsDir() {
+			return fmt.Errorf("Remote path %s exists and isn't a directory", dir)
+		}
+
+		return nil
+	}
+
+	log.Printf("SFTP: creating directory: %s", dir)
+	if err := client.Mkdir(dir); err != nil {
+		return fmt.Errorf("Error creating remote directory %s: %s", dir, err)
+	}
+
+	if err := client.Chmod(dir, mode); err != nil {
+		return fmt.Errorf("Error setting the mode of %s: %s", dir, err)
+	}
+
+	return nil
+}
+
+// sftpUploadDir uploads the contents of the local directory src into the
+// remote directory dst, where rel is the path of src relative to the
+// directory being uploaded.
+func sftpUploadDir(client *sftp.Client, dst string, src string, rel string, excl []string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	entries, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range entries {
+		entryRel := path.Join(rel, fi.Name())
+		if excluded(entryRel, excl) {
+			log.Printf("SFTP: skipping excluded path: %s", entryRel)
+			continue
+		}
+
+		localPath := filepath.Join(src, fi.Name())
+		remotePath := path.Join(dst, fi.Name())
+
+		// Follow symlinks, the same as uploading over SCP does
+		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+			fi, err = os.Stat(localPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		if fi.IsDir() {
+			if err := sftpMkdir(client, remotePath, fi.Mode().Perm()); err != nil {
+				return err
+			}
+
+			if err := sftpUploadDir(client, remotePath, localPath, entryRel, excl); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := func() error {
+			f, err := os.Open(localPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			return sftpUploadFile(client, remotePath, f, fi.Mode().Perm())
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sftpDownloadDir downloads the contents of the remote directory src into
+// the local directory dst, where rel is the path of src relative to the
+// directory being downloaded.
+func sftpDownloadDir(client *sftp.Client, dst string, src string, rel string, excl []string) error {
+	entries, err := client.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("Error reading remote directory %s: %s", src, err)
+	}
+
+	for _, fi := range entries {
+		entryRel := path.Join(rel, fi.Name())
+		if excluded(entryRel, excl) {
+			log.Printf("SFTP: skipping excluded path: %s", entryRel)
+			continue
+		}
+
+		remotePath := path.Join(src, fi.Name())
+		localPath := filepath.Join(dst, fi.Name())
+
+		// Follow symlinks, the same as downloading over SCP does
+		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+			fi, err = client.Stat(remotePath)
+			if err != nil {
+				return fmt.Errorf("Error reading remote symlink %s: %s", remotePath, err)
+			}
+		}
+
+		if fi.IsDir() {
+			if err := os.MkdirAll(localPath, fi.Mode().Perm()|0700); err != nil {
+				return err
+			}
+
+			if err := sftpDownloadDir(client, localPath, remotePath, entryRel, excl); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		log.Printf("SFTP: downloading file: %s", remotePath)
+		err := func() error {
+			rf, err := client.Open(remotePath)
+			if err != nil {
+				return fmt.Errorf("Error opening remote file %s: %s", remotePath, err)
+			}
+			defer rf.Close()
+
+			f, err := os.OpenFile(
+				localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if _, err := io.Copy(f, rf); err != nil {
+				return fmt.Errorf("Error downloading %s: %s", remotePath, err)
+			}
+
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// excluded returns whether the path, relative to the directory being
+// transferred, is one of the excluded paths.
+func excluded(rel string, excl []string) bool {
+	for _, e := range excl {
+		if path.Clean(filepath.ToSlash(e)) == rel {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,26 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestExcluded(t *testing.T) {
+	cases := []struct {
+		Rel      string
+		Exclude  []string
+		Excluded bool
+	}{
+		{"foo", nil, false},
+		{"foo", []string{"foo"}, true},
+		{"foo/bar", []string{"foo/bar/"}, true},
+		{"foo/bar", []string{"./foo/bar"}, true},
+		{"foo/bar", []string{"foo"}, false},
+		{"foo", []string{"foo/bar"}, false},
+	}
+
+	for _, tc := range cases {
+		if excluded(tc.Rel, tc.Exclude) != tc.Excluded {
+			t.Fatalf("bad: %s %#v", tc.Rel, tc.Exclude)
+		}
+	}
+}
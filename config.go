@@ -45,7 +45,10 @@ const defaultConfig = `
 		"vagrant": "packer-post-processor-vagrant",
 		"vsphere": "packer-post-processor-vsphere",
 		"docker-push": "packer-post-processor-docker-push",
-		"docker-import": "packer-post-processor-docker-import"
+		"docker-import": "packer-post-processor-docker-import",
+		"manifest": "packer-post-processor-manifest",
+		"checksum": "packer-post-processor-checksum",
+		"compress": "packer-post-processor-compress"
 	},
 
 	"provisioners": {
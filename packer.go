@@ -13,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 func main() {
@@ -76,7 +77,30 @@ func realMain() int {
 // wrappedMain is called only when we're wrapped by panicwrap and
 // returns the exit status to exit with.
 func wrappedMain() int {
-	log.SetOutput(os.Stderr)
+	// With a log directory for the builds, the log of the whole run, which
+	// includes the logs of the plugins, is also written to packer.log in
+	// that directory.
+	var logWriter io.Writer = os.Stderr
+	if logDir := extractLogDir(os.Args[1:]); logDir != "" {
+		if err := os.MkdirAll(logDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating log directory: %s\n", err)
+			return 1
+		}
+
+		f, err := os.Create(filepath.Join(logDir, "packer.log"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating log file: %s\n", err)
+			return 1
+		}
+		defer f.Close()
+
+		logWriter = io.MultiWriter(os.Stderr, f)
+	}
+
+	// Scrub any sensitive user variables from the logs. Plugins scrub
+	// their own logs, so their output relayed by the plugin client is
+	// already clean.
+	log.SetOutput(&packer.SensitiveWriter{Writer: logWriter})
 
 	log.Printf(
 		"Packer Version: %s %s %s",
@@ -176,6 +200,37 @@ func extractMachineReadable(args []string) ([]string, bool) {
 	return args, false
 }
 
+// extractLogDir returns the value of the -log-dir flag of the build
+// command, which is handled by the command itself, or an empty string if
+// it isn't set.
+func extractLogDir(args []string) string {
+	args, _ = extractMachineReadable(args)
+	if len(args) == 0 || args[0] != "build" {
+		return ""
+	}
+
+	for i := 1; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+
+		arg := strings.TrimLeft(args[i], "-")
+		if arg == "log-dir" {
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+
+			return ""
+		}
+
+		if strings.HasPrefix(arg, "log-dir=") {
+			return arg[len("log-dir="):]
+		}
+	}
+
+	return ""
+}
+
 func loadConfig() (*config, error) {
 	var config config
 	if err := decodeConfig(bytes.NewBufferString(defaultConfig), &config); err != nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 )
 
 const (
@@ -27,8 +28,58 @@ const (
 	// This key contains a map[string]string of the user variables for
 	// template processing.
 	UserVariablesConfigKey = "packer_user_variables"
+
+	// This key contains a []string of the values of sensitive user
+	// variables. These are registered with AddSensitiveValues by the
+	// plugin RPC servers so the values are scrubbed from all output.
+	SensitiveVariablesConfigKey = "packer_sensitive_variables"
+
+	// This key contains the artifacts of the builds that the build depends
+	// on, as a map of the build name to a list of artifacts that decode
+	// into UpstreamArtifact.
+	UpstreamArtifactsConfigKey = "packer_upstream_artifacts"
+
+	// This key contains the on-error mode, which is what builders do with
+	// the resources of a build when it fails. See the OnError constants.
+	OnErrorConfigKey = "packer_on_error"
+
+	// This key contains a boolean that says whether builders that
+	// support it should resume the build from its checkpoint.
+	ResumeConfigKey = "packer_resume"
+
+	// This key contains the UUID of the run of Packer that the build is
+	// part of, which is the same for all the builds of a run.
+	RunUUIDConfigKey = "packer_run_uuid"
+
+	// This key contains the path of the log file of the build. The
+	// plugin clients append the stderr of the plugins serving the build
+	// to this file.
+	LogFileConfigKey = "packer_log_file"
 )
 
+// The on-error modes. By default, the resources of a failed build are
+// cleaned up. They can be kept for debugging instead, either always with
+// abort or by asking the user.
+const (
+	OnErrorCleanup = "cleanup"
+	OnErrorAbort   = "abort"
+	OnErrorAsk     = "ask"
+)
+
+// UpstreamArtifact describes an artifact of a build that another build
+// depends on. These are available to the configuration templates of the
+// dependent build.
+type UpstreamArtifact struct {
+	BuilderId string `mapstructure:"builder_id"`
+	Id        string `mapstructure:"id"`
+	Files     []string
+
+	// PlaceholderDir is set for an artifact that stands in for the
+	// artifact of a build that hasn't run yet. See
+	// PlaceholderUpstreamArtifacts.
+	PlaceholderDir string `mapstructure:"placeholder_dir"`
+}
+
 // A Build represents a single job within Packer that is responsible for
 // building some machine image artifact. Builds are meant to be parallelized.
 type Build interface {
@@ -46,6 +97,11 @@ type Build interface {
 	// of what is built. If anything goes wrong, an error is returned.
 	Run(Ui, Cache) ([]Artifact, error)
 
+	// Plan returns what the build would run, with the configurations of
+	// its components interpolated and their secrets redacted. Nothing is
+	// run or created.
+	Plan() BuildPlan
+
 	// Cancel will cancel a running build. This will block until the build
 	// is actually completely cancelled.
 	Cancel()
@@ -64,6 +120,28 @@ type Build interface {
 	// When SetForce is set to true, existing artifacts from the build are
 	// deleted prior to the build.
 	SetForce(bool)
+
+	// SetLogFile sets the path of the log file of the build, which the
+	// plugins serving the build write their logs to. This must be called
+	// prior to Prepare.
+	SetLogFile(string)
+
+	// SetOnError sets the on-error mode of the build, which is one of the
+	// OnError constants. This must be called prior to Prepare.
+	SetOnError(string)
+
+	// SetResume sets whether the build resumes from its checkpoint, for
+	// the builders that support it. This must be called prior to Prepare.
+	SetResume(bool)
+
+	// SetRunUUID sets the UUID of the run of Packer that the build is part
+	// of. This must be called prior to Prepare.
+	SetRunUUID(string)
+
+	// SetUpstreamArtifacts sets the artifacts of the builds that this build
+	// depends on, keyed by the build name. This must be called prior to
+	// Prepare.
+	SetUpstreamArtifacts(map[string][]UpstreamArtifact)
 }
 
 // A build struct represents a single build job, the result of which should
@@ -79,9 +157,16 @@ type coreBuild struct {
 	postProcessors [][]coreBuildPostProcessor
 	provisioners   []coreBuildProvisioner
 	variables      map[string]string
+	sensitive      []string
+	sources        coreBuildSources
+	upstream       map[string][]UpstreamArtifact
 
 	debug         bool
 	force         bool
+	logFile       string
+	onError       string
+	resume        bool
+	runUUID       string
 	l             sync.Mutex
 	prepareCalled bool
 }
@@ -95,11 +180,38 @@ type coreBuildPostProcessor struct {
 	keepInputArtifact bool
 }
 
+// coreBuildSources are the template definitions of the builder,
+// provisioners and post-processors of a build, in the same order as
+// within the build. Errors from preparing a component are prefixed with
+// the position of its definition.
+type coreBuildSources struct {
+	builder        rawSource
+	provisioners   []rawSource
+	postProcessors [][]rawSource
+}
+
+func (s *coreBuildSources) provisioner(i int) rawSource {
+	if i < len(s.provisioners) {
+		return s.provisioners[i]
+	}
+
+	return rawSource{}
+}
+
+func (s *coreBuildSources) postProcessor(i, j int) rawSource {
+	if i < len(s.postProcessors) && j < len(s.postProcessors[i]) {
+		return s.postProcessors[i][j]
+	}
+
+	return rawSource{}
+}
+
 // Keeps track of the provisioner and the configuration of the provisioner
 // within the build.
 type coreBuildProvisioner struct {
-	provisioner Provisioner
-	config      []interface{}
+	provisioner     Provisioner
+	provisionerType string
+	config          []interface{}
 }
 
 // Returns the name of the build.
@@ -128,29 +240,73 @@ func (b *coreBuild) Prepare() (warn []string, err error) {
 		UserVariablesConfigKey: b.variables,
 	}
 
+	if len(b.sensitive) > 0 {
+		packerConfig[SensitiveVariablesConfigKey] = b.sensitive
+	}
+
+	if b.onError != "" {
+		packerConfig[OnErrorConfigKey] = b.onError
+	}
+
+	if b.resume {
+		packerConfig[ResumeConfigKey] = true
+	}
+
+	if b.runUUID != "" {
+		packerConfig[RunUUIDConfigKey] = b.runUUID
+	}
+
+	if b.logFile != "" {
+		packerConfig[LogFileConfigKey] = b.logFile
+	}
+
+	if len(b.upstream) > 0 {
+		// The artifacts are sent as plain maps and lists so that they
+		// can be sent to plugins just like the rest of the configuration.
+		upstream := make(map[string]interface{})
+		for name, artifacts := range b.upstream {
+			configs := make([]interface{}, len(artifacts))
+			for i, artifact := range artifacts {
+				configs[i] = map[string]interface{}{
+					"builder_id":      artifact.BuilderId,
+					"id":              artifact.Id,
+					"files":           artifact.Files,
+					"placeholder_dir": artifact.PlaceholderDir,
+				}
+			}
+
+			upstream[name] = configs
+		}
+
+		packerConfig[UpstreamArtifactsConfigKey] = upstream
+	}
+
 	// Prepare the builder
 	warn, err = b.builder.Prepare(b.builderConfig, packerConfig)
 	if err != nil {
 		log.Printf("Build '%s' prepare failure: %s\n", b.name, err)
+		err = b.sources.builder.prepareError(err)
 		return
 	}
 
 	// Prepare the provisioners
-	for _, coreProv := range b.provisioners {
+	for i, coreProv := range b.provisioners {
 		configs := make([]interface{}, len(coreProv.config), len(coreProv.config)+1)
 		copy(configs, coreProv.config)
 		configs = append(configs, packerConfig)
 
 		if err = coreProv.provisioner.Prepare(configs...); err != nil {
+			err = b.sources.provisioner(i).prepareError(err)
 			return
 		}
 	}
 
 	// Prepare the post-processors
-	for _, ppSeq := range b.postProcessors {
-		for _, corePP := range ppSeq {
+	for i, ppSeq := range b.postProcessors {
+		for j, corePP := range ppSeq {
 			err = corePP.processor.Configure(corePP.config, packerConfig)
 			if err != nil {
+				err = b.sources.postProcessor(i, j).prepareError(err)
 				return
 			}
 		}
@@ -165,6 +321,12 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 		panic("Prepare must be called first")
 	}
 
+	// The builder just has a normal Ui, but targetted
+	builderUi := &TargettedUi{
+		Target: b.Name(),
+		Ui:     originalUi,
+	}
+
 	// Copy the hooks
 	hooks := make(map[string][]Hook)
 	for hookName, hookList := range b.hooks {
@@ -175,8 +337,10 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 	// Add a hook for the provisioners if we have provisioners
 	if len(b.provisioners) > 0 {
 		provisioners := make([]Provisioner, len(b.provisioners))
+		provisionerTypes := make([]string, len(b.provisioners))
 		for i, p := range b.provisioners {
 			provisioners[i] = p.provisioner
+			provisionerTypes[i] = p.provisionerType
 		}
 
 		if _, ok := hooks[HookProvision]; !ok {
@@ -184,19 +348,15 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 		}
 
 		hooks[HookProvision] = append(hooks[HookProvision], &ProvisionHook{
-			Provisioners: provisioners,
+			Provisioners:     provisioners,
+			ProvisionerTypes: provisionerTypes,
+			BreakpointUi:     builderUi,
 		})
 	}
 
 	hook := &DispatchHook{Mapping: hooks}
 	artifacts := make([]Artifact, 0, 1)
 
-	// The builder just has a normal Ui, but targetted
-	builderUi := &TargettedUi{
-		Target: b.Name(),
-		Ui:     originalUi,
-	}
-
 	log.Printf("Running builder: %s", b.builderType)
 	builderArtifact, err := b.builder.Run(builderUi, hook, cache)
 	if err != nil {
@@ -223,7 +383,17 @@ PostProcessorRunSeqLoop:
 			}
 
 			builderUi.Say(fmt.Sprintf("Running post-processor: %s", corePP.processorType))
+			builderUi.Machine(MachinePostProcessorStarted, corePP.processorType)
+			start := time.Now()
 			artifact, keep, err := corePP.processor.PostProcess(ppUi, priorArtifact)
+
+			outcome := OutcomeSuccess
+			if err != nil {
+				outcome = OutcomeError
+			}
+			builderUi.Machine(MachinePostProcessorFinished,
+				corePP.processorType, MachineDuration(time.Since(start)), outcome)
+
 			if err != nil {
 				errors = append(errors, fmt.Errorf("Post-processor failed: %s", err))
 				continue PostProcessorRunSeqLoop
@@ -301,7 +471,80 @@ func (b *coreBuild) SetForce(val bool) {
 	b.force = val
 }
 
+func (b *coreBuild) SetLogFile(val string) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.logFile = val
+}
+
+func (b *coreBuild) SetOnError(val string) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.onError = val
+}
+
+func (b *coreBuild) SetResume(val bool) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.resume = val
+}
+
+func (b *coreBuild) SetRunUUID(val string) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.runUUID = val
+}
+
+func (b *coreBuild) SetUpstreamArtifacts(val map[string][]UpstreamArtifact) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.upstream = val
+}
+
 // Cancels the build if it is running.
 func (b *coreBuild) Cancel() {
 	b.builder.Cancel()
 }
+
+// ConfigLogFile returns the log file found under LogFileConfigKey in any
+// of the given raw configurations, or an empty string if there is none.
+// This is called with the configurations given to plugins.
+func ConfigLogFile(raws ...interface{}) string {
+	for _, raw := range raws {
+		m, ok := configMap(raw)
+		if !ok {
+			continue
+		}
+
+		if path, ok := m[LogFileConfigKey].(string); ok && path != "" {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// configMap returns a raw configuration as a map. Configurations that
+// were sent over RPC are pointers to maps.
+func configMap(raw interface{}) (map[string]interface{}, bool) {
+	switch m := raw.(type) {
+	case map[string]interface{}:
+		return m, true
+	case *map[string]interface{}:
+		if m != nil {
+			return *m, true
+		}
+	}
+
+	return nil, false
+}
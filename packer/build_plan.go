@@ -0,0 +1,208 @@
+package packer
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BuildPlan describes what a build would run, without running anything.
+// The configurations have the user variables interpolated and secrets
+// redacted. Other functions of the configuration templates, such as
+// "timestamp", are processed by the components when they run, so they
+// are left as they are.
+type BuildPlan struct {
+	Name           string
+	BuilderType    string
+	BuilderConfig  interface{}
+	Provisioners   []ProvisionerPlan
+	PostProcessors [][]PostProcessorPlan
+}
+
+// ProvisionerPlan is a provisioner of a BuildPlan. The configuration has
+// the override for the build applied.
+type ProvisionerPlan struct {
+	Type        string
+	Config      interface{}
+	PauseBefore time.Duration
+	Timeout     time.Duration
+}
+
+// PostProcessorPlan is a post-processor of a BuildPlan. The post-processors
+// of a build are a list of sequences, where each post-processor of a
+// sequence processes the artifact of the previous one.
+type PostProcessorPlan struct {
+	Type              string
+	Config            interface{}
+	KeepInputArtifact bool
+}
+
+// secretKeyParts are the parts of the names of configuration keys that
+// hold secrets, such as "password" or "secret_key".
+var secretKeyParts = []string{
+	"api_key", "passphrase", "password", "private_key", "secret", "token",
+}
+
+// userVariableRe matches the use of a user variable in a configuration
+// template, such as {{user `foo`}}.
+var userVariableRe = regexp.MustCompile("{{\\s*user\\s+[`\"]([^`\"]+)[`\"]\\s*}}")
+
+// planner turns the configurations of a build into the configurations of
+// its plan.
+type planner struct {
+	variables map[string]string
+	sensitive *strings.Replacer
+}
+
+func newPlanner(variables map[string]string, sensitive []string) *planner {
+	pairs := make([]string, 0, len(sensitive)*2)
+	sorted := make([]string, 0, len(sensitive))
+	for _, v := range sensitive {
+		if v != "" {
+			sorted = append(sorted, v)
+		}
+	}
+
+	// Replace longer values first like the sensitive filter does
+	sort.Sort(byLengthDesc(sorted))
+	for _, v := range sorted {
+		pairs = append(pairs, v, SensitiveFilterText)
+	}
+
+	return &planner{
+		variables: variables,
+		sensitive: strings.NewReplacer(pairs...),
+	}
+}
+
+// config returns a copy of the configuration with the user variables
+// interpolated and the secrets redacted, which are the values of keys
+// that hold secrets and the values of sensitive variables. The other
+// template functions are left as they are, since the components process
+// them with data that only exists while the build runs, such as the
+// port of the HTTP server of a builder.
+func (p *planner) config(raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			if isSecretKey(k) {
+				result[k] = SensitiveFilterText
+				continue
+			}
+
+			result[k] = p.config(value)
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			result[i] = p.config(value)
+		}
+
+		return result
+	case []string:
+		result := make([]string, len(v))
+		for i, value := range v {
+			result[i] = p.config(value).(string)
+		}
+
+		return result
+	case string:
+		v = userVariableRe.ReplaceAllStringFunc(v, func(s string) string {
+			name := userVariableRe.FindStringSubmatch(s)[1]
+			if value, ok := p.variables[name]; ok {
+				return value
+			}
+
+			return s
+		})
+
+		return p.sensitive.Replace(v)
+	default:
+		return raw
+	}
+}
+
+// mergedConfig merges the configurations of a component like decoding
+// them in order does, where the keys of later configurations replace
+// those of earlier ones.
+func (p *planner) mergedConfig(raws []interface{}) interface{} {
+	if len(raws) == 1 {
+		return p.config(raws[0])
+	}
+
+	result := make(map[string]interface{})
+	for _, raw := range raws {
+		m, ok := p.config(raw).(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for k, v := range m {
+			result[k] = v
+		}
+	}
+
+	return result
+}
+
+func isSecretKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, part := range secretKeyParts {
+		if strings.Contains(k, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Plan returns what the build would run. The components are not run, so
+// nothing is created.
+func (b *coreBuild) Plan() BuildPlan {
+	p := newPlanner(b.variables, b.sensitive)
+
+	result := BuildPlan{
+		Name:           b.name,
+		BuilderType:    b.builderType,
+		BuilderConfig:  p.config(b.builderConfig),
+		Provisioners:   make([]ProvisionerPlan, len(b.provisioners)),
+		PostProcessors: make([][]PostProcessorPlan, len(b.postProcessors)),
+	}
+
+	for i, coreProv := range b.provisioners {
+		provPlan := ProvisionerPlan{
+			Type:   coreProv.provisionerType,
+			Config: p.mergedConfig(coreProv.config),
+		}
+
+		// Unwrap the provisioner the same way it was wrapped by the template
+		provisioner := coreProv.provisioner
+		if paused, ok := provisioner.(*PausedProvisioner); ok {
+			provPlan.PauseBefore = paused.PauseBefore
+			provisioner = paused.Provisioner
+		}
+
+		if timeout, ok := provisioner.(*TimeoutProvisioner); ok {
+			provPlan.Timeout = timeout.Timeout
+		}
+
+		result.Provisioners[i] = provPlan
+	}
+
+	for i, ppSeq := range b.postProcessors {
+		result.PostProcessors[i] = make([]PostProcessorPlan, len(ppSeq))
+		for j, corePP := range ppSeq {
+			result.PostProcessors[i][j] = PostProcessorPlan{
+				Type:              corePP.processorType,
+				Config:            p.config(corePP.config),
+				KeepInputArtifact: corePP.keepInputArtifact,
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,77 @@
+package packer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuild_Plan(t *testing.T) {
+	build := testBuild()
+	build.builderConfig = map[string]interface{}{
+		"ssh_username": "{{user `user`}}",
+		"ssh_password": "hunter2",
+		"boot_command": []interface{}{"{{user `secret`}}<enter>", "{{.HTTPIP}}"},
+		"disk_size":    float64(10),
+	}
+	build.variables = map[string]string{"user": "vagrant", "secret": "swordfish"}
+	build.sensitive = []string{"swordfish"}
+	build.provisioners = []coreBuildProvisioner{
+		{
+			&PausedProvisioner{
+				PauseBefore: 5 * time.Second,
+				Provisioner: &TimeoutProvisioner{
+					Timeout:     time.Minute,
+					Provisioner: &MockProvisioner{},
+				},
+			},
+			"shell",
+			[]interface{}{
+				map[string]interface{}{"inline": []interface{}{"ls"}, "api_token": "x"},
+				map[string]interface{}{"inline": []interface{}{"dir"}},
+			},
+		},
+	}
+
+	plan := build.Plan()
+	if plan.Name != "test" || plan.BuilderType != "foo" {
+		t.Fatalf("bad: %#v", plan)
+	}
+
+	expectedBuilder := map[string]interface{}{
+		"ssh_username": "vagrant",
+		"ssh_password": SensitiveFilterText,
+		"boot_command": []interface{}{SensitiveFilterText + "<enter>", "{{.HTTPIP}}"},
+		"disk_size":    float64(10),
+	}
+	if !reflect.DeepEqual(plan.BuilderConfig, expectedBuilder) {
+		t.Fatalf("bad: %#v", plan.BuilderConfig)
+	}
+
+	expectedProvisioners := []ProvisionerPlan{
+		{
+			Type: "shell",
+			Config: map[string]interface{}{
+				"inline":    []interface{}{"dir"},
+				"api_token": SensitiveFilterText,
+			},
+			PauseBefore: 5 * time.Second,
+			Timeout:     time.Minute,
+		},
+	}
+	if !reflect.DeepEqual(plan.Provisioners, expectedProvisioners) {
+		t.Fatalf("bad: %#v", plan.Provisioners)
+	}
+
+	expectedPostProcessors := [][]PostProcessorPlan{
+		{{Type: "testPP", Config: map[string]interface{}{}, KeepInputArtifact: true}},
+	}
+	if !reflect.DeepEqual(plan.PostProcessors, expectedPostProcessors) {
+		t.Fatalf("bad: %#v", plan.PostProcessors)
+	}
+
+	// The build itself isn't modified
+	if build.builderConfig.(map[string]interface{})["ssh_password"] != "hunter2" {
+		t.Fatalf("bad: %#v", build.builderConfig)
+	}
+}
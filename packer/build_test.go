@@ -1,7 +1,10 @@
 package packer
 
 import (
+	"bytes"
+	"github.com/mitchellh/mapstructure"
 	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -15,7 +18,7 @@ func testBuild() *coreBuild {
 			"foo": []Hook{&MockHook{}},
 		},
 		provisioners: []coreBuildProvisioner{
-			coreBuildProvisioner{&MockProvisioner{}, []interface{}{42}},
+			coreBuildProvisioner{&MockProvisioner{}, "mock", []interface{}{42}},
 		},
 		postProcessors: [][]coreBuildPostProcessor{
 			[]coreBuildPostProcessor{
@@ -141,6 +144,78 @@ func TestBuild_Prepare_Debug(t *testing.T) {
 	}
 }
 
+func TestBuild_Prepare_OnError(t *testing.T) {
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[OnErrorConfigKey] = OnErrorAbort
+
+	build := testBuild()
+	builder := build.builder.(*MockBuilder)
+
+	build.SetOnError(OnErrorAbort)
+	build.Prepare()
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, packerConfig}) {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+}
+
+func TestBuild_Prepare_Resume(t *testing.T) {
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[ResumeConfigKey] = true
+
+	build := testBuild()
+	builder := build.builder.(*MockBuilder)
+
+	build.SetResume(true)
+	build.Prepare()
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, packerConfig}) {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+}
+
+func TestBuild_Prepare_RunUUID(t *testing.T) {
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[RunUUIDConfigKey] = "foo"
+
+	build := testBuild()
+	builder := build.builder.(*MockBuilder)
+
+	build.SetRunUUID("foo")
+	build.Prepare()
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, packerConfig}) {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+}
+
+func TestBuild_Prepare_LogFile(t *testing.T) {
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[LogFileConfigKey] = "logs/test.log"
+
+	build := testBuild()
+	builder := build.builder.(*MockBuilder)
+
+	build.SetLogFile("logs/test.log")
+	build.Prepare()
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, packerConfig}) {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+}
+
+func TestConfigLogFile(t *testing.T) {
+	config := map[string]interface{}{LogFileConfigKey: "foo.log"}
+	if path := ConfigLogFile(42, config); path != "foo.log" {
+		t.Fatalf("bad: %s", path)
+	}
+
+	// Configurations sent over RPC are pointers to maps
+	if path := ConfigLogFile(&config); path != "foo.log" {
+		t.Fatalf("bad: %s", path)
+	}
+
+	if path := ConfigLogFile(42); path != "" {
+		t.Fatalf("bad: %s", path)
+	}
+}
+
 func TestBuildPrepare_variables_default(t *testing.T) {
 	packerConfig := testDefaultPackerConfig()
 	packerConfig[UserVariablesConfigKey] = map[string]string{
@@ -168,6 +243,34 @@ func TestBuildPrepare_variables_default(t *testing.T) {
 	}
 }
 
+func TestBuildPrepare_upstreamArtifacts(t *testing.T) {
+	upstream := map[string][]UpstreamArtifact{
+		"base": []UpstreamArtifact{
+			{BuilderId: "foo", Id: "bar", Files: []string{"a.ovf", "a.vmdk"}},
+		},
+	}
+
+	build := testBuild()
+	build.SetUpstreamArtifacts(upstream)
+	builder := build.builder.(*MockBuilder)
+
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The artifacts decode back into the same artifacts in plugins
+	var config struct {
+		Upstream map[string][]UpstreamArtifact `mapstructure:"packer_upstream_artifacts"`
+	}
+	if err := mapstructure.Decode(builder.PrepareConfig[1], &config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(config.Upstream, upstream) {
+		t.Fatalf("bad: %#v", config.Upstream)
+	}
+}
+
 func TestBuild_Run(t *testing.T) {
 	cache := &TestCache{}
 	ui := testUi()
@@ -201,7 +304,7 @@ func TestBuild_Run(t *testing.T) {
 	}
 
 	// Verify provisioners run
-	dispatchHook.Run(HookProvision, nil, nil, 42)
+	dispatchHook.Run(HookProvision, ui, nil, 42)
 	prov := build.provisioners[0].provisioner.(*MockProvisioner)
 	if !prov.ProvCalled {
 		t.Fatal("should be called")
@@ -350,6 +453,32 @@ func TestBuild_Run_Artifacts(t *testing.T) {
 	}
 }
 
+func TestBuild_Run_machineEvents(t *testing.T) {
+	out := new(bytes.Buffer)
+	ui := &MachineReadableUi{Writer: out}
+
+	build := testBuild()
+	build.Prepare()
+	if _, err := build.Run(ui, &TestCache{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	builder := build.builder.(*MockBuilder)
+	builder.RunHook.Run(HookProvision, ui, nil, nil)
+
+	expected := []*regexp.Regexp{
+		regexp.MustCompile(`,test,post-processor-started,testPP\n`),
+		regexp.MustCompile(`,test,post-processor-finished,testPP,[0-9.]+,success\n`),
+		regexp.MustCompile(`,provisioner-started,mock\n`),
+		regexp.MustCompile(`,provisioner-finished,mock,[0-9.]+,success\n`),
+	}
+	for _, re := range expected {
+		if !re.MatchString(out.String()) {
+			t.Fatalf("bad: %s", out.String())
+		}
+	}
+}
+
 func TestBuild_RunBeforePrepare(t *testing.T) {
 	defer func() {
 		p := recover()
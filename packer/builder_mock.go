@@ -9,6 +9,7 @@ import (
 // methods were called on the builder. It is fairly basic.
 type MockBuilder struct {
 	ArtifactId      string
+	PrepareErr      error
 	PrepareWarnings []string
 	RunErrResult    bool
 	RunNilResult    bool
@@ -25,7 +26,7 @@ type MockBuilder struct {
 func (tb *MockBuilder) Prepare(config ...interface{}) ([]string, error) {
 	tb.PrepareCalled = true
 	tb.PrepareConfig = config
-	return tb.PrepareWarnings, nil
+	return tb.PrepareWarnings, tb.PrepareErr
 }
 
 func (tb *MockBuilder) Run(ui Ui, h Hook, c Cache) (Artifact, error) {
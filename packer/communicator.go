@@ -35,6 +35,8 @@ type RemoteCmd struct {
 
 	// Internal fields
 	exitCh chan struct{}
+	killCh chan struct{}
+	killed bool
 
 	// This thing is a mutex, lock when making modifications concurrently
 	sync.Mutex
@@ -73,6 +75,12 @@ type Communicator interface {
 	// with the contents writing to the given writer. This method will
 	// block until it completes.
 	Download(string, io.Writer) error
+
+	// DownloadDir downloads the contents of a directory on the machine
+	// recursively to the local path dst, creating it if it doesn't exist.
+	// It also takes an optional slice of paths, relative to the source
+	// directory, to ignore when downloading.
+	DownloadDir(src string, dst string, exclude []string) error
 }
 
 // StartWithUi runs the remote command and streams the output to any
@@ -180,6 +188,37 @@ func (r *RemoteCmd) Wait() {
 	<-r.exitCh
 }
 
+// Kill asks the communicator running the remote command to kill it. It
+// returns immediately, so Wait should be used to wait for the command to
+// actually exit. Killing a command more than once has no effect.
+func (r *RemoteCmd) Kill() {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.killCh == nil {
+		r.killCh = make(chan struct{})
+	}
+
+	if !r.killed {
+		r.killed = true
+		close(r.killCh)
+	}
+}
+
+// KillCh returns a channel that is closed when Kill is called. This
+// should be watched by communicators running a remote command in order
+// to kill it when asked to.
+func (r *RemoteCmd) KillCh() <-chan struct{} {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.killCh == nil {
+		r.killCh = make(chan struct{})
+	}
+
+	return r.killCh
+}
+
 // cleanOutputLine cleans up a line so that '\r' don't muck up the
 // UI output when we're reading from a remote command.
 func (r *RemoteCmd) cleanOutputLine(line string) string {
@@ -27,6 +27,10 @@ type MockCommunicator struct {
 	DownloadCalled bool
 	DownloadPath   string
 	DownloadData   string
+
+	DownloadDirDst     string
+	DownloadDirSrc     string
+	DownloadDirExclude []string
 }
 
 func (c *MockCommunicator) Start(rc *RemoteCmd) error {
@@ -97,3 +101,11 @@ func (c *MockCommunicator) Download(path string, w io.Writer) error {
 
 	return nil
 }
+
+func (c *MockCommunicator) DownloadDir(src string, dst string, excl []string) error {
+	c.DownloadDirDst = dst
+	c.DownloadDirSrc = src
+	c.DownloadDirExclude = excl
+
+	return nil
+}
@@ -60,3 +60,24 @@ func TestRemoteCmd_Wait(t *testing.T) {
 		t.Fatal("never got exit notification")
 	}
 }
+
+func TestRemoteCmd_Kill(t *testing.T) {
+	var cmd RemoteCmd
+
+	killCh := cmd.KillCh()
+	select {
+	case <-killCh:
+		t.Fatal("should not be killed")
+	default:
+	}
+
+	cmd.Kill()
+	cmd.Kill()
+
+	select {
+	case <-killCh:
+		// Success
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("never got kill notification")
+	}
+}
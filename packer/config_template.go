@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/packer/common/uuid"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 )
@@ -25,6 +28,10 @@ func init() {
 type ConfigTemplate struct {
 	UserVars map[string]string
 
+	// The artifacts of the builds that the build depends on, keyed by
+	// the build name.
+	UpstreamArtifacts map[string][]UpstreamArtifact
+
 	root *template.Template
 	i    int
 }
@@ -37,12 +44,15 @@ func NewConfigTemplate() (*ConfigTemplate, error) {
 
 	result.root = template.New("configTemplateRoot")
 	result.root.Funcs(template.FuncMap{
-		"env":       templateDisableEnv,
-		"pwd":       templatePwd,
-		"isotime":   templateISOTime,
-		"timestamp": templateTimestamp,
-		"user":      result.templateUser,
-		"uuid":      templateUuid,
+		"env":            templateDisableEnv,
+		"pwd":            templatePwd,
+		"isotime":        templateISOTime,
+		"timestamp":      templateTimestamp,
+		"upstream_file":  result.templateUpstreamFile,
+		"upstream_files": result.templateUpstreamFiles,
+		"upstream_id":    result.templateUpstreamId,
+		"user":           result.templateUser,
+		"uuid":           templateUuid,
 	})
 
 	return result, nil
@@ -96,6 +106,105 @@ func (t *ConfigTemplate) templateUser(n string) (string, error) {
 	return result, nil
 }
 
+// upstreamArtifact returns the first artifact of the upstream build with
+// the given name, which is the artifact that the upstream functions of
+// the templates refer to.
+func (t *ConfigTemplate) upstreamArtifact(n string) (UpstreamArtifact, error) {
+	artifacts, ok := t.UpstreamArtifacts[n]
+	if !ok {
+		return UpstreamArtifact{}, fmt.Errorf("unknown upstream build: %s", n)
+	}
+
+	if len(artifacts) == 0 {
+		return UpstreamArtifact{}, fmt.Errorf("upstream build '%s' has no artifacts", n)
+	}
+
+	return artifacts[0], nil
+}
+
+// templateUpstreamFile is the function exposed as "upstream_file" and
+// returns the first file of the upstream artifact whose base name matches
+// the given glob pattern, such as "*.ovf".
+func (t *ConfigTemplate) templateUpstreamFile(n, pattern string) (string, error) {
+	artifact, err := t.upstreamArtifact(n)
+	if err != nil {
+		return "", err
+	}
+
+	if artifact.PlaceholderDir != "" {
+		return placeholderFile(artifact.PlaceholderDir, pattern)
+	}
+
+	for _, file := range artifact.Files {
+		matched, err := filepath.Match(pattern, filepath.Base(file))
+		if err != nil {
+			return "", err
+		}
+
+		if matched {
+			return file, nil
+		}
+	}
+
+	return "", fmt.Errorf(
+		"no file of upstream build '%s' matches '%s'", n, pattern)
+}
+
+// templateUpstreamFiles is the function exposed as "upstream_files" and
+// returns the files of the upstream artifact.
+func (t *ConfigTemplate) templateUpstreamFiles(n string) ([]string, error) {
+	artifact, err := t.upstreamArtifact(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if artifact.PlaceholderDir != "" {
+		file, err := placeholderFile(artifact.PlaceholderDir, "placeholder")
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{file}, nil
+	}
+
+	return artifact.Files, nil
+}
+
+// placeholderFile creates an empty file in the directory of a placeholder
+// artifact whose name matches the given glob pattern, if possible, and
+// returns its path.
+func placeholderFile(dir string, pattern string) (string, error) {
+	name := strings.NewReplacer("*", "placeholder", "?", "p").Replace(
+		filepath.Base(pattern))
+	if matched, err := filepath.Match(pattern, name); err != nil || !matched {
+		name = "placeholder"
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+// templateUpstreamId is the function exposed as "upstream_id" and returns
+// the ID of the upstream artifact.
+func (t *ConfigTemplate) templateUpstreamId(n string) (string, error) {
+	artifact, err := t.upstreamArtifact(n)
+	if err != nil {
+		return "", err
+	}
+
+	return artifact.Id, nil
+}
+
 func templateDisableEnv(n string) (string, error) {
 	return "", fmt.Errorf(
 		"Environmental variables can only be used as default values for user variables.")
@@ -1,8 +1,10 @@
 package packer
 
 import (
+	"io/ioutil"
 	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -114,6 +116,92 @@ func TestConfigTemplateProcess_user(t *testing.T) {
 	}
 }
 
+func TestConfigTemplateProcess_upstream(t *testing.T) {
+	tpl, err := NewConfigTemplate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tpl.UpstreamArtifacts = map[string][]UpstreamArtifact{
+		"base": []UpstreamArtifact{
+			{Id: "foo", Files: []string{"out/base.vmdk", "out/base.ovf"}},
+		},
+		"empty": []UpstreamArtifact{},
+	}
+
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{`{{upstream_id "base"}}`, "foo"},
+		{`{{upstream_file "base" "*.ovf"}}`, "out/base.ovf"},
+		{`{{index (upstream_files "base") 0}}`, "out/base.vmdk"},
+	}
+
+	for _, tc := range cases {
+		result, err := tpl.Process(tc.Input, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if result != tc.Output {
+			t.Fatalf("bad: %s %s", tc.Input, result)
+		}
+	}
+
+	bad := []string{
+		`{{upstream_id "unknown"}}`,
+		`{{upstream_id "empty"}}`,
+		`{{upstream_file "base" "*.iso"}}`,
+	}
+
+	for _, input := range bad {
+		if _, err := tpl.Process(input, nil); err == nil {
+			t.Fatalf("should error: %s", input)
+		}
+	}
+}
+
+func TestConfigTemplateProcess_upstreamPlaceholder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tpl, err := NewConfigTemplate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tpl.UpstreamArtifacts = PlaceholderUpstreamArtifacts([]string{"base"}, dir)
+
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{`{{upstream_id "base"}}`, "placeholder-base"},
+		{`{{upstream_file "base" "*.ovf"}}`, filepath.Join(dir, "base", "placeholder.ovf")},
+		{`{{upstream_file "base" "disk[0-9].vmdk"}}`, filepath.Join(dir, "base", "placeholder")},
+		{`{{index (upstream_files "base") 0}}`, filepath.Join(dir, "base", "placeholder")},
+	}
+
+	for _, tc := range cases {
+		result, err := tpl.Process(tc.Input, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if result != tc.Output {
+			t.Fatalf("bad: %s %s", tc.Input, result)
+		}
+
+		if _, err := os.Stat(result); tc.Input != `{{upstream_id "base"}}` && err != nil {
+			t.Fatalf("placeholder file should exist: %s", err)
+		}
+	}
+}
+
 func TestConfigTemplateProcess_uuid(t *testing.T) {
 	tpl, err := NewConfigTemplate()
 	if err != nil {
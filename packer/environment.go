@@ -202,7 +202,7 @@ func (e *coreEnvironment) Provisioner(name string) (p Provisioner, err error) {
 // Executes a command as if it was typed on the command-line interface.
 // The return value is the exit code of the command.
 func (e *coreEnvironment) Cli(args []string) (result int, err error) {
-	log.Printf("Environment.Cli: %#v\n", args)
+	log.Printf("Environment.Cli: %#v\n", filterVarArgs(args))
 
 	// If we have no arguments, just short-circuit here and print the help
 	if len(args) == 0 {
@@ -228,7 +228,7 @@ func (e *coreEnvironment) Cli(args []string) (result int, err error) {
 		}
 	}
 
-	log.Printf("command + args: %#v", args)
+	log.Printf("command + args: %#v", filterVarArgs(args))
 
 	version := args[0] == "version"
 	if !version {
@@ -270,6 +270,35 @@ func (e *coreEnvironment) Cli(args []string) (result int, err error) {
 	return command.Run(e, args[1:]), nil
 }
 
+// filterVarArgs returns a copy of the command-line arguments with the
+// values of "-var" flags filtered out, for logging. The template that
+// says which variables are sensitive hasn't been read at this point, so
+// all of them are filtered.
+func filterVarArgs(args []string) []string {
+	result := make([]string, len(args))
+	copy(result, args)
+
+	for i, arg := range result {
+		var prefix, kv string
+		switch {
+		case (arg == "-var" || arg == "--var") && i+1 < len(result):
+			i++
+			kv = result[i]
+		case strings.HasPrefix(arg, "-var="), strings.HasPrefix(arg, "--var="):
+			idx := strings.Index(arg, "=") + 1
+			prefix, kv = arg[:idx], arg[idx:]
+		default:
+			continue
+		}
+
+		if idx := strings.Index(kv, "="); idx > -1 {
+			result[i] = prefix + kv[:idx+1] + SensitiveFilterText
+		}
+	}
+
+	return result
+}
+
 // Prints the CLI help to the UI.
 func (e *coreEnvironment) printHelp() {
 	// Created a sorted slice of the map keys and record the longest
@@ -429,3 +429,22 @@ func TestEnvironment_SettingUi(t *testing.T) {
 		t.Fatalf("UI should be equal: %#v", env.Ui())
 	}
 }
+
+func TestFilterVarArgs(t *testing.T) {
+	args := []string{
+		"build", "-var", "foo=bar", "-var=baz=qux", "-var-file=vars.json", "-var",
+	}
+
+	expected := []string{
+		"build", "-var", "foo=<Filtered>", "-var=baz=<Filtered>", "-var-file=vars.json", "-var",
+	}
+
+	result := filterVarArgs(args)
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	if args[2] != "foo=bar" {
+		t.Fatal("should not modify args")
+	}
+}
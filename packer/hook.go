@@ -4,9 +4,33 @@ import (
 	"sync"
 )
 
-// This is the hook that should be fired for provisioners to run.
+// This is the hook that should be fired for provisioners to run. The data
+// is a *ConnectionInfo if the builder connected to the machine over SSH.
 const HookProvision = "packer_provision"
 
+// ConnectionInfo describes how to reach the machine being provisioned
+// over SSH.
+type ConnectionInfo struct {
+	Host string
+	Port int
+	User string
+
+	// PrivateKey is the temporary private key created by the builder, in
+	// PEM format, if the builder created one.
+	PrivateKey string
+
+	// The bastion host that the machine is reached through, if any.
+	BastionHost string
+	BastionPort int
+	BastionUser string
+
+	// The proxy that the machine, or the bastion host if there is one,
+	// is reached through, if any. ProxyType is "socks5" or "http" and
+	// ProxyAddress is the host and port of the proxy.
+	ProxyType    string
+	ProxyAddress string
+}
+
 // A Hook is used to hook into an arbitrarily named location in a build,
 // allowing custom behavior to run at certain points along a build.
 //
@@ -0,0 +1,32 @@
+package packer
+
+import (
+	"strconv"
+	"time"
+)
+
+// The machine-readable types of the events of a build. The "started"
+// events have the name of what started as their only data. The
+// "finished" events have the name, the duration in seconds and the
+// outcome, which is one of the Outcome constants.
+const (
+	MachineStepStarted           = "step-started"
+	MachineStepFinished          = "step-finished"
+	MachineProvisionerStarted    = "provisioner-started"
+	MachineProvisionerFinished   = "provisioner-finished"
+	MachinePostProcessorStarted  = "post-processor-started"
+	MachinePostProcessorFinished = "post-processor-finished"
+)
+
+// The outcomes of the "finished" events.
+const (
+	OutcomeSuccess   = "success"
+	OutcomeError     = "error"
+	OutcomeCancelled = "cancelled"
+)
+
+// MachineDuration formats a duration for machine-readable output as a
+// number of seconds with millisecond precision, such as "12.345".
+func MachineDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -16,6 +16,8 @@ func (b *cmdBuilder) Prepare(config ...interface{}) ([]string, error) {
 		b.checkExit(r, nil)
 	}()
 
+	b.client.setLogFile(config)
+
 	return b.builder.Prepare(config...)
 }
 
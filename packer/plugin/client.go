@@ -12,6 +12,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +36,11 @@ type Client struct {
 	doneLogging chan struct{}
 	l           sync.Mutex
 	address     net.Addr
+
+	// The log file of the build that the plugin serves, if any. This has
+	// its own lock since the stderr is logged while the client starts.
+	logFile  *os.File
+	logFileL sync.Mutex
 }
 
 // ClientConfig is the configuration used to initialize a new
@@ -347,6 +353,31 @@ func (c *Client) Start() (addr net.Addr, err error) {
 	return
 }
 
+// setLogFile makes the stderr of the plugin also go to the log file of
+// the build found in the given configurations, if there is one. The file
+// is shared with the other plugins of the build, so it is appended to.
+func (c *Client) setLogFile(raws []interface{}) {
+	path := packer.ConfigLogFile(raws...)
+	if path == "" {
+		return
+	}
+
+	c.logFileL.Lock()
+	defer c.logFileL.Unlock()
+
+	if c.logFile != nil {
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Printf("Error opening log file of plugin %s: %s", c.config.Cmd.Path, err)
+		return
+	}
+
+	c.logFile = f
+}
+
 func (c *Client) logStderr(r io.Reader) {
 	bufR := bufio.NewReader(r)
 	for {
@@ -356,6 +387,12 @@ func (c *Client) logStderr(r io.Reader) {
 
 			line = strings.TrimRightFunc(line, unicode.IsSpace)
 			log.Printf("%s: %s", c.config.Cmd.Path, line)
+
+			c.logFileL.Lock()
+			if c.logFile != nil {
+				fmt.Fprintf(c.logFile, "%s: %s\n", filepath.Base(c.config.Cmd.Path), line)
+			}
+			c.logFileL.Unlock()
 		}
 
 		if err == io.EOF {
@@ -363,6 +400,13 @@ func (c *Client) logStderr(r io.Reader) {
 		}
 	}
 
+	c.logFileL.Lock()
+	if c.logFile != nil {
+		c.logFile.Close()
+		c.logFile = nil
+	}
+	c.logFileL.Unlock()
+
 	// Flag that we've completed logging for others
 	close(c.doneLogging)
 }
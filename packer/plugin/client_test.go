@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"bytes"
+	"github.com/mitchellh/packer/packer"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -97,6 +98,45 @@ func TestClient_Stderr(t *testing.T) {
 	}
 }
 
+func TestClient_logFile(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.WriteString("build output\n")
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	process := helperProcess("stderr")
+	c := NewClient(&ClientConfig{Cmd: process})
+	c.setLogFile([]interface{}{
+		map[string]interface{}{packer.LogFileConfigKey: tf.Name()},
+	})
+
+	if _, err := c.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for !c.Exited() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Kill()
+
+	data, err := ioutil.ReadFile(tf.Name())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The log file is appended to
+	if !strings.HasPrefix(string(data), "build output\n") {
+		t.Fatalf("bad log data: '%s'", data)
+	}
+
+	if !strings.Contains(string(data), "HELLO\n") || !strings.Contains(string(data), "WORLD\n") {
+		t.Fatalf("bad log data: '%s'", data)
+	}
+}
+
 func TestClient_Stdin(t *testing.T) {
 	// Overwrite stdin for this test with a temporary file
 	tf, err := ioutil.TempFile("", "packer")
@@ -98,6 +98,14 @@ func TestHelperProcess(*testing.T) {
 		}
 		server.RegisterProvisioner(new(packer.MockProvisioner))
 		server.Serve()
+	case "provisioner-timeout":
+		server, err := Server()
+		if err != nil {
+			log.Printf("[ERR] %s", err)
+			os.Exit(1)
+		}
+		server.RegisterProvisioner(new(helperTimeoutProvisioner))
+		server.Serve()
 	case "start-timeout":
 		time.Sleep(1 * time.Minute)
 		os.Exit(1)
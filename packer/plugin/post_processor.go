@@ -16,6 +16,8 @@ func (c *cmdPostProcessor) Configure(config ...interface{}) error {
 		c.checkExit(r, nil)
 	}()
 
+	c.client.setLogFile(config)
+
 	return c.p.Configure(config...)
 }
 
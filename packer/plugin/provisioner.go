@@ -16,6 +16,8 @@ func (c *cmdProvisioner) Prepare(configs ...interface{}) error {
 		c.checkExit(r, nil)
 	}()
 
+	c.client.setLogFile(configs)
+
 	return c.p.Prepare(configs...)
 }
 
@@ -1,10 +1,54 @@
 package plugin
 
 import (
+	"bytes"
+	"github.com/mitchellh/packer/packer"
+	"os"
 	"os/exec"
 	"testing"
+	"time"
 )
 
+// helperTimeoutProvisioner runs a remote command until it exits, and is
+// cancelled the way the built-in provisioners are, by exiting the plugin.
+type helperTimeoutProvisioner struct{}
+
+func (helperTimeoutProvisioner) Prepare(...interface{}) error {
+	return nil
+}
+
+func (helperTimeoutProvisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+	cmd := &packer.RemoteCmd{Command: "sleep 3600"}
+	if err := comm.Start(cmd); err != nil {
+		return err
+	}
+
+	cmd.Wait()
+	return nil
+}
+
+func (helperTimeoutProvisioner) Cancel() {
+	os.Exit(0)
+}
+
+// killableCommunicator is a communicator whose remote commands only exit,
+// a bit later, once they are killed.
+type killableCommunicator struct {
+	packer.MockCommunicator
+	cmds chan *packer.RemoteCmd
+}
+
+func (c *killableCommunicator) Start(cmd *packer.RemoteCmd) error {
+	go func() {
+		<-cmd.KillCh()
+		time.Sleep(100 * time.Millisecond)
+		cmd.SetExited(143)
+	}()
+
+	c.cmds <- cmd
+	return nil
+}
+
 func TestProvisioner_NoExist(t *testing.T) {
 	c := NewClient(&ClientConfig{Cmd: exec.Command("i-should-not-exist")})
 	defer c.Kill()
@@ -24,3 +68,48 @@ func TestProvisioner_Good(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 }
+
+func TestProvisioner_Timeout(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("provisioner-timeout")})
+	defer c.Kill()
+
+	p, err := c.Provisioner()
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	prov := &packer.TimeoutProvisioner{
+		Timeout:     100 * time.Millisecond,
+		Provisioner: p,
+	}
+
+	ui := &packer.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)}
+	comm := &killableCommunicator{cmds: make(chan *packer.RemoteCmd, 1)}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- prov.Provision(ui, comm)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should have error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("provisioner should time out")
+	}
+
+	// The remote command was killed and exited before the plugin was
+	// cancelled, which exits it.
+	cmd := <-comm.cmds
+	if !cmd.Exited || cmd.ExitStatus != 143 {
+		t.Fatalf("command should have exited: %#v", cmd)
+	}
+
+	if !c.Exited() {
+		time.Sleep(time.Second)
+		if !c.Exited() {
+			t.Fatal("plugin should have exited")
+		}
+	}
+}
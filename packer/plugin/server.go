@@ -38,6 +38,10 @@ const APIVersion = "2"
 // Server waits for a connection to this plugin and returns a Packer
 // RPC server that you can use to register components and serve them.
 func Server() (*packrpc.Server, error) {
+	// Scrub sensitive values from the logs. These are registered as soon
+	// as they arrive with the configuration of the plugin.
+	log.SetOutput(&packer.SensitiveWriter{Writer: os.Stderr})
+
 	log.Printf("Plugin build against Packer '%s'", packer.GitCommit)
 
 	if os.Getenv(MagicCookieKey) != MagicCookieValue {
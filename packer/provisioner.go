@@ -2,6 +2,7 @@ package packer
 
 import (
 	"fmt"
+	"log"
 	"sync"
 	"time"
 )
@@ -32,8 +33,19 @@ type ProvisionHook struct {
 	// be prepared (by calling Prepare) at some earlier stage.
 	Provisioners []Provisioner
 
+	// The types of the provisioners, in the same order, which name them
+	// in the machine-readable output.
+	ProvisionerTypes []string
+
+	// BreakpointUi, if set, is the Ui that breakpoints wait for the user
+	// with. The Ui that the hook runs with comes from the builder, over
+	// RPC for a builder plugin, which can't stop waiting for an answer
+	// when the breakpoint is cancelled.
+	BreakpointUi Ui
+
 	lock               sync.Mutex
 	runningProvisioner Provisioner
+	cancelled          bool
 }
 
 // Runs the provisioners in order.
@@ -45,12 +57,40 @@ func (h *ProvisionHook) Run(name string, ui Ui, comm Communicator, data interfac
 		h.runningProvisioner = nil
 	}()
 
-	for _, p := range h.Provisioners {
+	info, _ := data.(*ConnectionInfo)
+	for i, p := range h.Provisioners {
+		if bp := breakpoint(p); bp != nil {
+			bp.connectionInfo = info
+			bp.ui = h.BreakpointUi
+		}
+
 		h.lock.Lock()
 		h.runningProvisioner = p
 		h.lock.Unlock()
 
-		if err := p.Provision(ui, comm); err != nil {
+		pType := ""
+		if i < len(h.ProvisionerTypes) {
+			pType = h.ProvisionerTypes[i]
+		}
+
+		ui.Machine(MachineProvisionerStarted, pType)
+		start := time.Now()
+		err := p.Provision(ui, comm)
+
+		outcome := OutcomeSuccess
+		if err != nil {
+			outcome = OutcomeError
+		}
+
+		h.lock.Lock()
+		if h.cancelled {
+			outcome = OutcomeCancelled
+		}
+		h.lock.Unlock()
+
+		ui.Machine(MachineProvisionerFinished,
+			pType, MachineDuration(time.Since(start)), outcome)
+		if err != nil {
 			return err
 		}
 	}
@@ -63,11 +103,27 @@ func (h *ProvisionHook) Cancel() {
 	h.lock.Lock()
 	defer h.lock.Unlock()
 
+	h.cancelled = true
 	if h.runningProvisioner != nil {
 		h.runningProvisioner.Cancel()
 	}
 }
 
+// breakpoint returns the breakpoint that the provisioner is, looking
+// through the provisioners that wrap it, or nil if it isn't one.
+func breakpoint(p Provisioner) *BreakpointProvisioner {
+	switch v := p.(type) {
+	case *PausedProvisioner:
+		return breakpoint(v.Provisioner)
+	case *TimeoutProvisioner:
+		return breakpoint(v.Provisioner)
+	case *BreakpointProvisioner:
+		return v
+	default:
+		return nil
+	}
+}
+
 // PausedProvisioner is a Provisioner implementation that pauses before
 // the provisioner is actually run.
 type PausedProvisioner struct {
@@ -144,3 +200,109 @@ func (p *PausedProvisioner) Cancel() {
 func (p *PausedProvisioner) provision(result chan<- error, ui Ui, comm Communicator) {
 	result <- p.Provisioner.Provision(ui, comm)
 }
+
+// timeoutKillWait is how long a TimeoutProvisioner that timed out waits
+// for the remote commands it killed to exit before it cancels the
+// provisioner.
+var timeoutKillWait = 30 * time.Second
+
+// TimeoutProvisioner is a Provisioner implementation that cancels the
+// provisioner, and kills the remote commands it started, if it runs for
+// longer than the timeout.
+type TimeoutProvisioner struct {
+	Timeout     time.Duration
+	Provisioner Provisioner
+}
+
+func (p *TimeoutProvisioner) Prepare(raws ...interface{}) error {
+	return p.Provisioner.Prepare(raws...)
+}
+
+func (p *TimeoutProvisioner) Provision(ui Ui, comm Communicator) error {
+	timeoutComm := &timeoutCommunicator{Communicator: comm}
+
+	provDoneCh := make(chan error, 1)
+	go func() {
+		provDoneCh <- p.Provisioner.Provision(ui, timeoutComm)
+	}()
+
+	timer := time.NewTimer(p.Timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-provDoneCh:
+		return err
+	case <-timer.C:
+	}
+
+	// The remote commands are killed before the provisioner is cancelled,
+	// since the built-in provisioners cancel by exiting their plugin.
+	ui.Error(fmt.Sprintf(
+		"Provisioner timed out after %s. Cancelling it...", p.Timeout))
+	if !timeoutComm.kill(timeoutKillWait) {
+		log.Printf("Remote commands didn't exit within %s of being killed", timeoutKillWait)
+	}
+	p.Provisioner.Cancel()
+	<-provDoneCh
+
+	return fmt.Errorf("Provisioner timed out after %s", p.Timeout)
+}
+
+func (p *TimeoutProvisioner) Cancel() {
+	p.Provisioner.Cancel()
+}
+
+// timeoutCommunicator is a Communicator that keeps track of the remote
+// commands it starts so that they can be killed once the provisioner
+// times out.
+type timeoutCommunicator struct {
+	Communicator
+
+	cmds   []*RemoteCmd
+	killed bool
+	lock   sync.Mutex
+}
+
+func (c *timeoutCommunicator) Start(cmd *RemoteCmd) error {
+	if err := c.Communicator.Start(cmd); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cmds = append(c.cmds, cmd)
+	if c.killed {
+		cmd.Kill()
+	}
+
+	return nil
+}
+
+// kill kills the remote commands that were started, as well as any
+// command started from now on, and waits up to the given duration for
+// them to exit. It returns whether they exited in time.
+func (c *timeoutCommunicator) kill(wait time.Duration) bool {
+	c.lock.Lock()
+	c.killed = true
+	cmds := c.cmds
+	for _, cmd := range cmds {
+		cmd.Kill()
+	}
+	c.lock.Unlock()
+
+	exited := make(chan struct{})
+	go func() {
+		for _, cmd := range cmds {
+			cmd.Wait()
+		}
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
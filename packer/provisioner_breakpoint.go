@@ -0,0 +1,267 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BreakpointProvisionerType is the type of the provisioner that pauses the
+// build at a breakpoint.
+//
+// Unlike the other provisioners, this one is built into the core rather
+// than being a plugin found through the ComponentFinder. It needs the
+// connection information of the builder, including the private key, which
+// only the core gets from the provision hook, and which the Provisioner
+// interface that plugins implement has no way to receive. It also waits on
+// the Ui of the core, since a question asked through the Ui that a plugin
+// gets over RPC can't be cancelled, which would leave the build waiting on
+// the input of the user after it is cancelled.
+const BreakpointProvisionerType = "breakpoint"
+
+// BreakpointProvisioner is a Provisioner implementation that pauses the
+// build, printing how to reach the machine over SSH, until the user
+// confirms that the build should continue.
+type BreakpointProvisioner struct {
+	config struct {
+		// Disable is a boolean, as a string so that it can be set by
+		// a user variable.
+		Disable string
+		Note    string
+
+		PackerUserVars map[string]string `mapstructure:"packer_user_variables"`
+	}
+
+	disabled       bool
+	connectionInfo *ConnectionInfo
+	ui             Ui
+
+	cancelCh chan struct{}
+	lock     sync.Mutex
+}
+
+func (p *BreakpointProvisioner) Prepare(raws ...interface{}) error {
+	var md mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata:         &md,
+		Result:           &p.config,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range raws {
+		if err := decoder.Decode(raw); err != nil {
+			return err
+		}
+	}
+
+	errs := make([]error, 0)
+	sort.Strings(md.Unused)
+	for _, unused := range md.Unused {
+		if unused != "type" && !strings.HasPrefix(unused, "packer_") {
+			errs = append(errs, fmt.Errorf("Unknown configuration key: %s", unused))
+		}
+	}
+
+	tpl, err := NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	tpl.UserVars = p.config.PackerUserVars
+
+	templates := map[string]*string{
+		"disable": &p.config.Disable,
+		"note":    &p.config.Note,
+	}
+
+	for n, ptr := range templates {
+		var err error
+		*ptr, err = tpl.Process(*ptr, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error processing %s: %s", n, err))
+		}
+	}
+
+	if p.config.Disable != "" {
+		p.disabled, err = strconv.ParseBool(p.config.Disable)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"disable must be a boolean, got '%s'", p.config.Disable))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &MultiError{errs}
+	}
+
+	return nil
+}
+
+func (p *BreakpointProvisioner) Provision(ui Ui, comm Communicator) error {
+	if p.disabled {
+		ui.Say("Skipping disabled breakpoint.")
+		return nil
+	}
+
+	p.lock.Lock()
+	cancelCh := make(chan struct{})
+	p.cancelCh = cancelCh
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if p.cancelCh == cancelCh {
+			p.cancelCh = nil
+		}
+	}()
+
+	if p.config.Note != "" {
+		ui.Say(fmt.Sprintf("Pausing at breakpoint: %s", p.config.Note))
+	} else {
+		ui.Say("Pausing at breakpoint.")
+	}
+
+	info := p.connectionInfo
+	if info == nil {
+		ui.Message("The builder didn't connect to the machine over SSH.")
+	} else {
+		keyPath, err := writeBreakpointKey(info.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("Error writing the temporary private key: %s", err)
+		}
+		if keyPath != "" {
+			defer os.Remove(keyPath)
+		}
+
+		ui.Message(fmt.Sprintf("SSH host: %s", info.Host))
+		ui.Message(fmt.Sprintf("SSH port: %d", info.Port))
+		if info.User != "" {
+			ui.Message(fmt.Sprintf("SSH user: %s", info.User))
+		}
+
+		command := fmt.Sprintf("ssh -p %d", info.Port)
+		if keyPath != "" {
+			ui.Message(fmt.Sprintf("SSH private key: %s", keyPath))
+			ui.Message("The private key is deleted when the build continues.")
+			command += fmt.Sprintf(" -i %s", keyPath)
+		}
+
+		if info.BastionHost != "" {
+			ui.Message(fmt.Sprintf("SSH bastion host: %s:%d", info.BastionHost, info.BastionPort))
+		}
+		if info.ProxyAddress != "" {
+			ui.Message(fmt.Sprintf("SSH proxy: %s://%s", info.ProxyType, info.ProxyAddress))
+		}
+		command += breakpointJumpOptions(info)
+
+		if info.User != "" {
+			command += fmt.Sprintf(" %s@%s", info.User, info.Host)
+		} else {
+			command += " " + info.Host
+		}
+
+		ui.Message(fmt.Sprintf("Connect with: %s", command))
+	}
+
+	askUi := ui
+	if p.ui != nil {
+		askUi = p.ui
+	}
+
+	_, err := AskCancel(askUi, "Press enter to continue the build:", cancelCh)
+	select {
+	case <-cancelCh:
+		return errors.New("Breakpoint cancelled")
+	default:
+	}
+
+	if err != nil {
+		return fmt.Errorf("Error waiting at breakpoint: %s", err)
+	}
+
+	ui.Say("Continuing the build.")
+	return nil
+}
+
+func (p *BreakpointProvisioner) Cancel() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.cancelCh != nil {
+		close(p.cancelCh)
+		p.cancelCh = nil
+	}
+}
+
+// writeBreakpointKey writes the private key to a temporary file, which
+// only the user can read, returning its path. Nothing is written if there
+// is no private key.
+func writeBreakpointKey(privateKey string) (string, error) {
+	if privateKey == "" {
+		return "", nil
+	}
+
+	f, err := ioutil.TempFile("", "packer-breakpoint-key")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(privateKey); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// breakpointJumpOptions returns the options of the ssh command to reach
+// the machine through the bastion host and proxy of the connection, if
+// any. The proxy is connected to with the nc of OpenBSD, and its username
+// and password aren't included.
+func breakpointJumpOptions(info *ConnectionInfo) string {
+	var proxyCommand string
+	if info.ProxyAddress != "" {
+		proxyType := "5"
+		if info.ProxyType == "http" {
+			proxyType = "connect"
+		}
+
+		proxyCommand = fmt.Sprintf(
+			"nc -X %s -x %s %%h %%p", proxyType, info.ProxyAddress)
+	}
+
+	if info.BastionHost == "" {
+		if proxyCommand == "" {
+			return ""
+		}
+
+		return fmt.Sprintf(" -o 'ProxyCommand=%s'", proxyCommand)
+	}
+
+	var user string
+	if info.BastionUser != "" {
+		user = info.BastionUser + "@"
+	}
+
+	if proxyCommand == "" {
+		return fmt.Sprintf(" -J %s%s", user, net.JoinHostPort(
+			info.BastionHost, strconv.Itoa(info.BastionPort)))
+	}
+
+	// ssh can't combine -J with a ProxyCommand, so the bastion host is
+	// reached with a ProxyCommand of its own that goes through the proxy.
+	return fmt.Sprintf(
+		" -o 'ProxyCommand=ssh -p %d -o \"ProxyCommand=%s\" -W %%h:%%p %s%s'",
+		info.BastionPort, proxyCommand, user, info.BastionHost)
+}
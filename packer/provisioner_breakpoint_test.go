@@ -0,0 +1,249 @@
+package packer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBreakpointConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "breakpoint",
+		UserVariablesConfigKey: map[string]string{
+			"skip": "true",
+		},
+	}
+}
+
+func TestBreakpointProvisioner_Impl(t *testing.T) {
+	var _ Provisioner = new(BreakpointProvisioner)
+}
+
+func TestBreakpointProvisionerPrepare_disable(t *testing.T) {
+	p := new(BreakpointProvisioner)
+	if err := p.Prepare(testBreakpointConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.disabled {
+		t.Fatal("should not be disabled")
+	}
+
+	// Disabled with a user variable
+	config := testBreakpointConfig()
+	config["disable"] = "{{user `skip`}}"
+	p = new(BreakpointProvisioner)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.disabled {
+		t.Fatal("should be disabled")
+	}
+
+	// Disabled with a boolean
+	config["disable"] = true
+	p = new(BreakpointProvisioner)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.disabled {
+		t.Fatal("should be disabled")
+	}
+
+	// Not a boolean
+	config["disable"] = "maybe"
+	p = new(BreakpointProvisioner)
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBreakpointProvisionerPrepare_unknownKey(t *testing.T) {
+	config := testBreakpointConfig()
+	config["foo"] = "bar"
+
+	p := new(BreakpointProvisioner)
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBreakpointProvisionerProvision(t *testing.T) {
+	config := testBreakpointConfig()
+	config["note"] = "before the reboot"
+
+	p := new(BreakpointProvisioner)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	p.connectionInfo = &ConnectionInfo{
+		Host:       "10.0.0.1",
+		Port:       2222,
+		User:       "vagrant",
+		PrivateKey: "KEY",
+	}
+
+	ui := testUi()
+	ui.Reader = bytes.NewBufferString("\n")
+	if err := p.Provision(ui, new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out := ui.Writer.(*bytes.Buffer).String()
+	for _, expected := range []string{
+		"before the reboot", "10.0.0.1", "2222", "vagrant", "ssh -p 2222 -i ",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %q: %s", expected, out)
+		}
+	}
+
+	// The key is deleted once the build continues
+	idx := strings.Index(out, "SSH private key: ")
+	if idx < 0 {
+		t.Fatalf("bad: %s", out)
+	}
+	keyPath := strings.SplitN(out[idx+len("SSH private key: "):], "\n", 2)[0]
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Fatalf("key should be deleted: %s", keyPath)
+	}
+}
+
+func TestBreakpointJumpOptions(t *testing.T) {
+	cases := []struct {
+		Info     ConnectionInfo
+		Expected string
+	}{
+		{
+			ConnectionInfo{},
+			"",
+		},
+		{
+			ConnectionInfo{BastionHost: "bastion", BastionPort: 22, BastionUser: "bob"},
+			" -J bob@bastion:22",
+		},
+		{
+			ConnectionInfo{BastionHost: "::1", BastionPort: 2222},
+			" -J [::1]:2222",
+		},
+		{
+			ConnectionInfo{ProxyType: "socks5", ProxyAddress: "proxy:1080"},
+			" -o 'ProxyCommand=nc -X 5 -x proxy:1080 %h %p'",
+		},
+		{
+			ConnectionInfo{ProxyType: "http", ProxyAddress: "proxy:3128"},
+			" -o 'ProxyCommand=nc -X connect -x proxy:3128 %h %p'",
+		},
+		{
+			ConnectionInfo{
+				BastionHost:  "bastion",
+				BastionPort:  22,
+				BastionUser:  "bob",
+				ProxyType:    "socks5",
+				ProxyAddress: "proxy:1080",
+			},
+			" -o 'ProxyCommand=ssh -p 22 -o \"ProxyCommand=nc -X 5 -x proxy:1080 %h %p\" -W %h:%p bob@bastion'",
+		},
+	}
+
+	for _, tc := range cases {
+		if actual := breakpointJumpOptions(&tc.Info); actual != tc.Expected {
+			t.Fatalf("bad: %#v\n\n%s", tc.Info, actual)
+		}
+	}
+}
+
+func TestBreakpointProvisionerProvision_disabled(t *testing.T) {
+	config := testBreakpointConfig()
+	config["disable"] = true
+
+	p := new(BreakpointProvisioner)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Nothing is read, so this would fail if it asked
+	ui := testUi()
+	ui.Reader = new(errorReader)
+	if err := p.Provision(ui, new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestBreakpointProvisionerCancel(t *testing.T) {
+	p := new(BreakpointProvisioner)
+	if err := p.Prepare(testBreakpointConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ui := testUi()
+	ui.Reader = r
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Provision(ui, new(MockCommunicator))
+	}()
+
+	// Cancel until the breakpoint is waiting, since cancelling before
+	// has no effect
+	timeout := time.After(time.Second)
+	for {
+		p.Cancel()
+
+		select {
+		case err := <-errCh:
+			if err == nil {
+				t.Fatal("should have error")
+			}
+
+			// The question was cancelled too, so the Ui isn't
+			// waiting on the answer anymore
+			sayCh := make(chan struct{})
+			go func() {
+				ui.Say("foo")
+				close(sayCh)
+			}()
+
+			select {
+			case <-sayCh:
+			case <-time.After(time.Second):
+				t.Fatal("Ui should not wait on the answer")
+			}
+
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("should be cancelled")
+		}
+	}
+}
+
+func TestBreakpointProvisionerProvision_ui(t *testing.T) {
+	p := new(BreakpointProvisioner)
+	if err := p.Prepare(testBreakpointConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The breakpoint asks the Ui of the core rather than the one given
+	coreUi := testUi()
+	coreUi.Reader = bytes.NewBufferString("\n")
+	p.ui = coreUi
+
+	ui := testUi()
+	ui.Reader = new(errorReader)
+	if err := p.Provision(ui, new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// errorReader is a reader that fails the test if it is read from.
+type errorReader struct{}
+
+func (*errorReader) Read([]byte) (int, error) {
+	panic("should not be read")
+}
@@ -1,6 +1,10 @@
 package packer
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
 	"sync"
 	"testing"
 	"time"
@@ -37,6 +41,70 @@ func TestProvisionHook(t *testing.T) {
 	}
 }
 
+func TestProvisionHook_connectionInfo(t *testing.T) {
+	breakpoint := new(BreakpointProvisioner)
+	wrapped := &PausedProvisioner{
+		PauseBefore: time.Millisecond,
+		Provisioner: &TimeoutProvisioner{
+			Timeout:     time.Minute,
+			Provisioner: breakpoint,
+		},
+	}
+	if err := breakpoint.Prepare(map[string]interface{}{"disable": true}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	hook := &ProvisionHook{
+		Provisioners: []Provisioner{&MockProvisioner{}, wrapped},
+	}
+
+	info := &ConnectionInfo{Host: "127.0.0.1", Port: 22}
+	if err := hook.Run("foo", testUi(), new(MockCommunicator), info); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if breakpoint.connectionInfo != info {
+		t.Fatalf("bad: %#v", breakpoint.connectionInfo)
+	}
+}
+
+func TestProvisionHook_machineEvents(t *testing.T) {
+	out := new(bytes.Buffer)
+	ui := &MachineReadableUi{Writer: out}
+
+	pA := &MockProvisioner{}
+	pB := &MockProvisioner{
+		ProvFunc: func() error { return errors.New("failed") },
+	}
+
+	hook := &ProvisionHook{
+		Provisioners:     []Provisioner{pA, pB},
+		ProvisionerTypes: []string{"shell", "file"},
+	}
+
+	if err := hook.Run("foo", ui, nil, nil); err == nil {
+		t.Fatal("should have error")
+	}
+
+	re := regexp.MustCompile(`,provisioner-(started|finished),([a-z]+)(,[0-9]+\.[0-9]{3},([a-z]+))?\n`)
+	matches := re.FindAllStringSubmatch(out.String(), -1)
+	if len(matches) != 4 {
+		t.Fatalf("bad: %s", out.String())
+	}
+
+	expected := [][]string{
+		{"started", "shell", ""},
+		{"finished", "shell", OutcomeSuccess},
+		{"started", "file", ""},
+		{"finished", "file", OutcomeError},
+	}
+	for i, match := range matches {
+		if match[1] != expected[i][0] || match[2] != expected[i][1] || match[4] != expected[i][2] {
+			t.Fatalf("bad: %d: %#v", i, match)
+		}
+	}
+}
+
 func TestProvisionHook_cancel(t *testing.T) {
 	var lock sync.Mutex
 	order := make([]string, 0, 2)
@@ -59,7 +127,7 @@ func TestProvisionHook_cancel(t *testing.T) {
 
 	finished := make(chan struct{})
 	go func() {
-		hook.Run("foo", nil, nil, nil)
+		hook.Run("foo", testUi(), nil, nil)
 		close(finished)
 	}()
 
@@ -171,3 +239,116 @@ func TestPausedProvisionerCancel(t *testing.T) {
 		t.Fatal("cancel should be called")
 	}
 }
+
+func TestTimeoutProvisioner_impl(t *testing.T) {
+	var _ Provisioner = new(TimeoutProvisioner)
+}
+
+func TestTimeoutProvisionerProvision(t *testing.T) {
+	mock := new(MockProvisioner)
+	prov := &TimeoutProvisioner{
+		Timeout:     time.Minute,
+		Provisioner: mock,
+	}
+
+	if err := prov.Provision(testUi(), new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !mock.ProvCalled {
+		t.Fatal("prov should be called")
+	}
+	if mock.CancelCalled {
+		t.Fatal("cancel should not be called")
+	}
+}
+
+func TestTimeoutProvisionerProvision_timeout(t *testing.T) {
+	mock := new(MockProvisioner)
+	prov := &TimeoutProvisioner{
+		Timeout:     10 * time.Millisecond,
+		Provisioner: mock,
+	}
+
+	// Keep the command running until stdin is closed, which happens a
+	// bit after it is killed
+	stdin_r, stdin_w := io.Pipe()
+	defer stdin_w.Close()
+
+	cmd := &RemoteCmd{Command: "foo", Stdin: stdin_r}
+	mock.ProvFunc = func() error {
+		if err := mock.ProvCommunicator.Start(cmd); err != nil {
+			return err
+		}
+
+		<-cmd.KillCh()
+		time.Sleep(50 * time.Millisecond)
+		stdin_w.Close()
+		return nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- prov.Provision(testUi(), new(MockCommunicator))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should have error")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("provisioner should time out")
+	}
+
+	if !mock.CancelCalled {
+		t.Fatal("cancel should be called")
+	}
+
+	// The command exits before the provisioner is cancelled
+	if !cmd.Exited {
+		t.Fatal("command should have exited")
+	}
+}
+
+func TestTimeoutProvisionerProvision_killWait(t *testing.T) {
+	defer func(old time.Duration) { timeoutKillWait = old }(timeoutKillWait)
+	timeoutKillWait = 10 * time.Millisecond
+
+	mock := new(MockProvisioner)
+	prov := &TimeoutProvisioner{
+		Timeout:     10 * time.Millisecond,
+		Provisioner: mock,
+	}
+
+	// The command never exits, even once killed
+	stdin_r, stdin_w := io.Pipe()
+	defer stdin_w.Close()
+
+	cmd := &RemoteCmd{Command: "foo", Stdin: stdin_r}
+	mock.ProvFunc = func() error {
+		if err := mock.ProvCommunicator.Start(cmd); err != nil {
+			return err
+		}
+
+		<-cmd.KillCh()
+		return nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- prov.Provision(testUi(), new(MockCommunicator))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should have error")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("provisioner should time out")
+	}
+
+	if !mock.CancelCalled {
+		t.Fatal("cancel should be called")
+	}
+}
@@ -63,6 +63,11 @@ func (b *build) Run(ui packer.Ui, cache packer.Cache) ([]packer.Artifact, error)
 	return artifacts, nil
 }
 
+func (b *build) Plan() (result packer.BuildPlan) {
+	b.client.Call("Build.Plan", new(interface{}), &result)
+	return
+}
+
 func (b *build) SetDebug(val bool) {
 	if err := b.client.Call("Build.SetDebug", val, new(interface{})); err != nil {
 		panic(err)
@@ -75,6 +80,36 @@ func (b *build) SetForce(val bool) {
 	}
 }
 
+func (b *build) SetLogFile(val string) {
+	if err := b.client.Call("Build.SetLogFile", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) SetOnError(val string) {
+	if err := b.client.Call("Build.SetOnError", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) SetResume(val bool) {
+	if err := b.client.Call("Build.SetResume", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) SetRunUUID(val string) {
+	if err := b.client.Call("Build.SetRunUUID", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) SetUpstreamArtifacts(val map[string][]packer.UpstreamArtifact) {
+	if err := b.client.Call("Build.SetUpstreamArtifacts", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
 func (b *build) Cancel() {
 	if err := b.client.Call("Build.Cancel", new(interface{}), new(interface{})); err != nil {
 		panic(err)
@@ -120,6 +155,11 @@ func (b *BuildServer) Run(streamId uint32, reply *[]uint32) error {
 	return nil
 }
 
+func (b *BuildServer) Plan(args *interface{}, reply *packer.BuildPlan) error {
+	*reply = b.build.Plan()
+	return nil
+}
+
 func (b *BuildServer) SetDebug(val *bool, reply *interface{}) error {
 	b.build.SetDebug(*val)
 	return nil
@@ -130,6 +170,31 @@ func (b *BuildServer) SetForce(val *bool, reply *interface{}) error {
 	return nil
 }
 
+func (b *BuildServer) SetLogFile(val *string, reply *interface{}) error {
+	b.build.SetLogFile(*val)
+	return nil
+}
+
+func (b *BuildServer) SetOnError(val *string, reply *interface{}) error {
+	b.build.SetOnError(*val)
+	return nil
+}
+
+func (b *BuildServer) SetResume(val *bool, reply *interface{}) error {
+	b.build.SetResume(*val)
+	return nil
+}
+
+func (b *BuildServer) SetRunUUID(val *string, reply *interface{}) error {
+	b.build.SetRunUUID(*val)
+	return nil
+}
+
+func (b *BuildServer) SetUpstreamArtifacts(val *map[string][]packer.UpstreamArtifact, reply *interface{}) error {
+	b.build.SetUpstreamArtifacts(*val)
+	return nil
+}
+
 func (b *BuildServer) Cancel(args *interface{}, reply *interface{}) error {
 	b.build.Cancel()
 	return nil
@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/mitchellh/packer/packer"
 	"reflect"
@@ -16,8 +17,14 @@ type testBuild struct {
 	runCalled       bool
 	runCache        packer.Cache
 	runUi           packer.Ui
+	planCalled      bool
 	setDebugCalled  bool
 	setForceCalled  bool
+	logFile         string
+	onError         string
+	resume          bool
+	runUUID         string
+	upstream        map[string][]packer.UpstreamArtifact
 	cancelCalled    bool
 
 	errRunResult bool
@@ -45,6 +52,21 @@ func (b *testBuild) Run(ui packer.Ui, cache packer.Cache) ([]packer.Artifact, er
 	}
 }
 
+func (b *testBuild) Plan() packer.BuildPlan {
+	b.planCalled = true
+	return packer.BuildPlan{
+		Name:          "name",
+		BuilderType:   "foo",
+		BuilderConfig: map[string]interface{}{"foo": "bar"},
+		Provisioners: []packer.ProvisionerPlan{
+			{Type: "shell", Config: map[string]interface{}{"inline": []interface{}{"ls"}}},
+		},
+		PostProcessors: [][]packer.PostProcessorPlan{
+			{{Type: "compress", Config: map[string]interface{}{}, KeepInputArtifact: true}},
+		},
+	}
+}
+
 func (b *testBuild) SetDebug(bool) {
 	b.setDebugCalled = true
 }
@@ -53,6 +75,26 @@ func (b *testBuild) SetForce(bool) {
 	b.setForceCalled = true
 }
 
+func (b *testBuild) SetLogFile(val string) {
+	b.logFile = val
+}
+
+func (b *testBuild) SetOnError(val string) {
+	b.onError = val
+}
+
+func (b *testBuild) SetResume(val bool) {
+	b.resume = val
+}
+
+func (b *testBuild) SetRunUUID(val string) {
+	b.runUUID = val
+}
+
+func (b *testBuild) SetUpstreamArtifacts(val map[string][]packer.UpstreamArtifact) {
+	b.upstream = val
+}
+
 func (b *testBuild) Cancel() {
 	b.cancelCalled = true
 }
@@ -104,6 +146,19 @@ func TestBuild(t *testing.T) {
 		t.Fatal("should error")
 	}
 
+	// Test Plan
+	plan := bClient.Plan()
+	if !b.planCalled {
+		t.Fatal("plan should be called")
+	}
+
+	// Maps in interfaces are decoded as pointers, so compare the JSON
+	actual, _ := json.Marshal(plan)
+	expected, _ := json.Marshal(b.Plan())
+	if string(actual) != string(expected) {
+		t.Fatalf("bad: %s", actual)
+	}
+
 	// Test SetDebug
 	bClient.SetDebug(true)
 	if !b.setDebugCalled {
@@ -116,6 +171,41 @@ func TestBuild(t *testing.T) {
 		t.Fatal("should be called")
 	}
 
+	// Test SetLogFile
+	bClient.SetLogFile("foo.log")
+	if b.logFile != "foo.log" {
+		t.Fatalf("bad: %s", b.logFile)
+	}
+
+	// Test SetOnError
+	bClient.SetOnError(packer.OnErrorAbort)
+	if b.onError != packer.OnErrorAbort {
+		t.Fatalf("bad: %s", b.onError)
+	}
+
+	// Test SetResume
+	bClient.SetResume(true)
+	if !b.resume {
+		t.Fatal("resume should be set")
+	}
+
+	// Test SetRunUUID
+	bClient.SetRunUUID("foo")
+	if b.runUUID != "foo" {
+		t.Fatalf("bad: %s", b.runUUID)
+	}
+
+	// Test SetUpstreamArtifacts
+	upstream := map[string][]packer.UpstreamArtifact{
+		"base": []packer.UpstreamArtifact{
+			{BuilderId: "bid", Id: "id", Files: []string{"foo.ovf"}},
+		},
+	}
+	bClient.SetUpstreamArtifacts(upstream)
+	if !reflect.DeepEqual(b.upstream, upstream) {
+		t.Fatalf("bad: %#v", b.upstream)
+	}
+
 	// Test Cancel
 	bClient.Cancel()
 	if !b.cancelCalled {
@@ -71,9 +71,10 @@ func (b *builder) Cancel() {
 }
 
 func (b *BuilderServer) Prepare(args *BuilderPrepareArgs, reply *BuilderPrepareResponse) error {
+	packer.AddSensitiveConfigValues(args.Configs...)
 	warnings, err := b.builder.Prepare(args.Configs...)
 	if err != nil {
-		err = NewBasicError(err)
+		err = NewPrepareError(err)
 	}
 
 	*reply = BuilderPrepareResponse{
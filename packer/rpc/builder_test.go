@@ -1,7 +1,10 @@
 package rpc
 
 import (
+	"bytes"
+	"errors"
 	"github.com/mitchellh/packer/packer"
+	"log"
 	"reflect"
 	"testing"
 )
@@ -35,6 +38,32 @@ func TestBuilderPrepare(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_Sensitive(t *testing.T) {
+	b := new(packer.MockBuilder)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	// The configuration arrives in the plugin as a pointer to a map, and
+	// the sensitive values in it must be scrubbed from the plugin's log.
+	config := map[string]interface{}{
+		packer.SensitiveVariablesConfigKey: []string{"rpc-builder-secret"},
+	}
+	if _, err := bClient.Prepare(config); err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+
+	buf := new(bytes.Buffer)
+	logger := log.New(&packer.SensitiveWriter{Writer: buf}, "", 0)
+	logger.Printf("password: rpc-builder-secret")
+
+	if buf.String() != "password: "+packer.SensitiveFilterText+"\n" {
+		t.Fatalf("bad: %#v", buf.String())
+	}
+}
+
 func TestBuilderPrepare_Warnings(t *testing.T) {
 	b := new(packer.MockBuilder)
 	client, server := testClientServer(t)
@@ -56,6 +85,29 @@ func TestBuilderPrepare_Warnings(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_MultiError(t *testing.T) {
+	b := new(packer.MockBuilder)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	b.PrepareErr = &packer.MultiError{
+		Errors: []error{errors.New("foo"), errors.New("bar")},
+	}
+
+	_, err := bClient.Prepare(nil)
+	multi, ok := err.(*packer.MultiError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	if len(multi.Errors) != 2 || multi.Errors[1].Error() != "bar" {
+		t.Fatalf("bad: %#v", multi.Errors)
+	}
+}
+
 func TestBuilderRun(t *testing.T) {
 	b := new(packer.MockBuilder)
 	client, server := testClientServer(t)
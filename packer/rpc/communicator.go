@@ -32,6 +32,7 @@ type CommunicatorStartArgs struct {
 	StdoutStreamId   uint32
 	StderrStreamId   uint32
 	ResponseStreamId uint32
+	KillStreamId     uint32
 }
 
 type CommunicatorDownloadArgs struct {
@@ -39,6 +40,12 @@ type CommunicatorDownloadArgs struct {
 	WriterStreamId uint32
 }
 
+type CommunicatorDownloadDirArgs struct {
+	Dst     string
+	Src     string
+	Exclude []string
+}
+
 type CommunicatorUploadArgs struct {
 	Path           string
 	ReaderStreamId uint32
@@ -76,7 +83,31 @@ func (c *communicator) Start(cmd *packer.RemoteCmd) (err error) {
 	responseStreamId := c.mux.NextId()
 	args.ResponseStreamId = responseStreamId
 
+	// The kill stream is written to if the command is killed, and is
+	// closed when the command exits.
+	killStreamId := c.mux.NextId()
+	args.KillStreamId = killStreamId
+	exitCh := make(chan struct{})
+
+	go func() {
+		conn, err := c.mux.Accept(killStreamId)
+		if err != nil {
+			log.Printf("[ERR] Error accepting kill stream %d: %s",
+				killStreamId, err)
+			return
+		}
+		defer conn.Close()
+
+		select {
+		case <-cmd.KillCh():
+			conn.Write([]byte{1})
+		case <-exitCh:
+		}
+	}()
+
 	go func() {
+		defer close(exitCh)
+
 		conn, err := c.mux.Accept(responseStreamId)
 		if err != nil {
 			log.Printf("[ERR] Error accepting response stream %d: %s",
@@ -147,6 +178,22 @@ func (c *communicator) Download(path string, w io.Writer) (err error) {
 	return
 }
 
+func (c *communicator) DownloadDir(src string, dst string, exclude []string) error {
+	args := &CommunicatorDownloadDirArgs{
+		Dst:     dst,
+		Src:     src,
+		Exclude: exclude,
+	}
+
+	var reply error
+	err := c.client.Call("Communicator.DownloadDir", args, &reply)
+	if err == nil {
+		err = reply
+	}
+
+	return err
+}
+
 func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface{}) error {
 	// Build the RemoteCmd on this side so that it all pipes over
 	// to the remote side.
@@ -206,6 +253,14 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 	}
 	responseWriter := gob.NewEncoder(responseC)
 
+	// Connect to the kill address so that we kill the command when asked.
+	killC, err := c.mux.Dial(args.KillStreamId)
+	if err != nil {
+		close(doneCh)
+		return NewBasicError(err)
+	}
+	toClose = append(toClose, killC)
+
 	// Start the actual command
 	err = c.c.Start(&cmd)
 	if err != nil {
@@ -213,6 +268,14 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 		return NewBasicError(err)
 	}
 
+	go func() {
+		var buf [1]byte
+		if n, _ := killC.Read(buf[:]); n > 0 {
+			log.Printf("[INFO] RPC endpoint: Killing communicator command")
+			cmd.Kill()
+		}
+	}()
+
 	// Start a goroutine to spin and wait for the process to actual
 	// exit. When it does, report it back to caller...
 	go func() {
@@ -252,6 +315,10 @@ func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *int
 	return
 }
 
+func (c *CommunicatorServer) DownloadDir(args *CommunicatorDownloadDirArgs, reply *error) error {
+	return c.c.DownloadDir(args.Src, args.Dst, args.Exclude)
+}
+
 func serveSingleCopy(name string, mux *MuxConn, id uint32, dst io.Writer, src io.Reader) {
 	conn, err := mux.Accept(id)
 	if err != nil {
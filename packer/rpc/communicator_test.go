@@ -6,6 +6,7 @@ import (
 	"io"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCommunicatorRPC(t *testing.T) {
@@ -154,6 +155,55 @@ func TestCommunicatorRPC(t *testing.T) {
 	if downloadData != "download\n" {
 		t.Fatalf("bad: %s", downloadData)
 	}
+
+	// Test that we can download directories
+	err = remote.DownloadDir(dirSrc, dirDst, dirExcl)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.DownloadDirDst != dirDst {
+		t.Fatalf("bad: %s", c.DownloadDirDst)
+	}
+
+	if c.DownloadDirSrc != dirSrc {
+		t.Fatalf("bad: %s", c.DownloadDirSrc)
+	}
+
+	if !reflect.DeepEqual(c.DownloadDirExclude, dirExcl) {
+		t.Fatalf("bad: %#v", c.DownloadDirExclude)
+	}
+}
+
+func TestCommunicatorRPC_kill(t *testing.T) {
+	c := new(packer.MockCommunicator)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	// Keep the command running until stdin is closed
+	stdin_r, stdin_w := io.Pipe()
+	defer stdin_w.Close()
+
+	var cmd packer.RemoteCmd
+	cmd.Command = "foo"
+	cmd.Stdin = stdin_r
+
+	if err := remote.Start(&cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cmd.Kill()
+
+	select {
+	case <-c.StartCmd.KillCh():
+		// Success
+	case <-time.After(5 * time.Second):
+		t.Fatal("command should be killed")
+	}
 }
 
 func TestCommunicator_ImplementsCommunicator(t *testing.T) {
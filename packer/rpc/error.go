@@ -1,5 +1,9 @@
 package rpc
 
+import (
+	"github.com/mitchellh/packer/packer"
+)
+
 // This is a type that wraps error types so that they can be messaged
 // across RPC channels. Since "error" is an interface, we can't always
 // gob-encode the underlying structure. This is a valid error interface
@@ -15,3 +19,20 @@ func NewBasicError(err error) *BasicError {
 func (e *BasicError) Error() string {
 	return e.Message
 }
+
+// NewPrepareError wraps an error from preparing or configuring a component
+// so that it can be messaged across RPC channels. Unlike NewBasicError,
+// a *packer.MultiError keeps its structure so that each of its errors can
+// still be reported on its own.
+func NewPrepareError(err error) error {
+	if multi, ok := err.(*packer.MultiError); ok {
+		errs := make([]error, len(multi.Errors))
+		for i, e := range multi.Errors {
+			errs[i] = NewPrepareError(e)
+		}
+
+		return &packer.MultiError{Errors: errs}
+	}
+
+	return NewBasicError(err)
+}
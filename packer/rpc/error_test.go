@@ -2,6 +2,8 @@ package rpc
 
 import (
 	"errors"
+	"github.com/mitchellh/packer/packer"
+	"reflect"
 	"testing"
 )
 
@@ -17,3 +19,23 @@ func TestBasicError_MatchesMessage(t *testing.T) {
 		t.Fatalf("bad: %#v", wrapped.Error())
 	}
 }
+
+func TestNewPrepareError(t *testing.T) {
+	err := NewPrepareError(errors.New("foo"))
+	if _, ok := err.(*BasicError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	err = NewPrepareError(&packer.MultiError{
+		Errors: []error{errors.New("foo"), errors.New("bar")},
+	})
+	multi, ok := err.(*packer.MultiError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	expected := []error{&BasicError{"foo"}, &BasicError{"bar"}}
+	if !reflect.DeepEqual(multi.Errors, expected) {
+		t.Fatalf("bad: %#v", multi.Errors)
+	}
+}
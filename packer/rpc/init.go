@@ -1,10 +1,16 @@
 package rpc
 
-import "encoding/gob"
+import (
+	"encoding/gob"
+	"github.com/mitchellh/packer/packer"
+)
 
 func init() {
 	gob.Register(new(map[string]interface{}))
 	gob.Register(new(map[string]string))
+	gob.Register(make([]string, 0))
 	gob.Register(make([]interface{}, 0))
+	gob.Register(new(packer.ConnectionInfo))
 	gob.Register(new(BasicError))
+	gob.Register(new(packer.MultiError))
 }
@@ -68,9 +68,10 @@ func (p *postProcessor) PostProcess(ui packer.Ui, a packer.Artifact) (packer.Art
 }
 
 func (p *PostProcessorServer) Configure(args *PostProcessorConfigureArgs, reply *error) error {
+	packer.AddSensitiveConfigValues(args.Configs...)
 	*reply = p.p.Configure(args.Configs...)
 	if *reply != nil {
-		*reply = NewBasicError(*reply)
+		*reply = NewPrepareError(*reply)
 	}
 
 	return nil
@@ -51,9 +51,10 @@ func (p *provisioner) Cancel() {
 }
 
 func (p *ProvisionerServer) Prepare(args *ProvisionerPrepareArgs, reply *error) error {
+	packer.AddSensitiveConfigValues(args.Configs...)
 	*reply = p.p.Prepare(args.Configs...)
 	if *reply != nil {
-		*reply = NewBasicError(*reply)
+		*reply = NewPrepareError(*reply)
 	}
 
 	return nil
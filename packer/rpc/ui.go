@@ -7,7 +7,8 @@ import (
 )
 
 // An implementation of packer.Ui where the Ui is actually executed
-// over an RPC connection.
+// over an RPC connection. All text is scrubbed of sensitive values before
+// it is sent, so they never leave the process that knows about them.
 type Ui struct {
 	client   *rpc.Client
 	endpoint string
@@ -26,20 +27,27 @@ type UiMachineArgs struct {
 }
 
 func (u *Ui) Ask(query string) (result string, err error) {
+	query = packer.ScrubSensitive(query)
 	err = u.client.Call("Ui.Ask", query, &result)
 	return
 }
 
 func (u *Ui) Error(message string) {
+	message = packer.ScrubSensitive(message)
 	if err := u.client.Call("Ui.Error", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui RPC call: %s", err)
 	}
 }
 
 func (u *Ui) Machine(t string, args ...string) {
+	scrubbed := make([]string, len(args))
+	for i, arg := range args {
+		scrubbed[i] = packer.ScrubSensitive(arg)
+	}
+
 	rpcArgs := &UiMachineArgs{
 		Category: t,
-		Args:     args,
+		Args:     scrubbed,
 	}
 
 	if err := u.client.Call("Ui.Machine", rpcArgs, new(interface{})); err != nil {
@@ -48,12 +56,14 @@ func (u *Ui) Machine(t string, args ...string) {
 }
 
 func (u *Ui) Message(message string) {
+	message = packer.ScrubSensitive(message)
 	if err := u.client.Call("Ui.Message", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui RPC call: %s", err)
 	}
 }
 
 func (u *Ui) Say(message string) {
+	message = packer.ScrubSensitive(message)
 	if err := u.client.Call("Ui.Say", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui RPC call: %s", err)
 	}
@@ -0,0 +1,105 @@
+package packer
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The text that sensitive values are replaced with.
+const SensitiveFilterText = "<Filtered>"
+
+// The sensitive values are tracked per process. Every Packer process
+// scrubs the text it emits itself: the Ui implementations, the RPC Ui
+// client that sends text to other processes, and the log output. The
+// values reach plugin processes through SensitiveVariablesConfigKey.
+var sensitive struct {
+	l        sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// AddSensitiveValues registers values that must never be output by this
+// process. Empty values are ignored.
+func AddSensitiveValues(values ...string) {
+	sensitive.l.Lock()
+	defer sensitive.l.Unlock()
+
+	if sensitive.values == nil {
+		sensitive.values = make(map[string]struct{})
+	}
+
+	for _, v := range values {
+		if v != "" {
+			sensitive.values[v] = struct{}{}
+		}
+	}
+
+	// Replace longer values first so that a value containing another
+	// value is filtered completely.
+	sorted := make([]string, 0, len(sensitive.values))
+	for v, _ := range sensitive.values {
+		sorted = append(sorted, v)
+	}
+	sort.Sort(byLengthDesc(sorted))
+
+	pairs := make([]string, 0, len(sorted)*2)
+	for _, v := range sorted {
+		pairs = append(pairs, v, SensitiveFilterText)
+	}
+
+	sensitive.replacer = strings.NewReplacer(pairs...)
+}
+
+// AddSensitiveConfigValues registers the sensitive values found under
+// SensitiveVariablesConfigKey in any of the given raw configurations.
+// This is called with the configurations given to plugins, which arrive
+// over RPC as pointers to maps rather than maps.
+func AddSensitiveConfigValues(raws ...interface{}) {
+	for _, raw := range raws {
+		m, ok := configMap(raw)
+		if !ok {
+			continue
+		}
+
+		if values, ok := m[SensitiveVariablesConfigKey].([]string); ok {
+			AddSensitiveValues(values...)
+		}
+	}
+}
+
+// ScrubSensitive returns the string with all sensitive values replaced
+// by SensitiveFilterText.
+func ScrubSensitive(s string) string {
+	sensitive.l.RLock()
+	defer sensitive.l.RUnlock()
+
+	if sensitive.replacer == nil {
+		return s
+	}
+
+	return sensitive.replacer.Replace(s)
+}
+
+// SensitiveWriter is an io.Writer that scrubs sensitive values from
+// everything written to it before writing it to the wrapped Writer. Each
+// write is scrubbed on its own, so it is meant for writers that receive
+// whole lines, such as the log output.
+type SensitiveWriter struct {
+	Writer io.Writer
+}
+
+func (w *SensitiveWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.Writer, ScrubSensitive(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+type byLengthDesc []string
+
+func (s byLengthDesc) Len() int           { return len(s) }
+func (s byLengthDesc) Less(i, j int) bool { return len(s[i]) > len(s[j]) }
+func (s byLengthDesc) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
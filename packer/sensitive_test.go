@@ -0,0 +1,106 @@
+package packer
+
+import (
+	"bytes"
+	"testing"
+)
+
+// resetSensitiveValues clears the sensitive values so that tests don't
+// affect each other's output.
+func resetSensitiveValues() {
+	sensitive.l.Lock()
+	defer sensitive.l.Unlock()
+	sensitive.values = nil
+	sensitive.replacer = nil
+}
+
+func TestScrubSensitive(t *testing.T) {
+	defer resetSensitiveValues()
+
+	if result := ScrubSensitive("hunter2"); result != "hunter2" {
+		t.Fatalf("bad: %s", result)
+	}
+
+	AddSensitiveValues("hunter2", "", "hunter2-long")
+
+	result := ScrubSensitive("password: hunter2, other: hunter2-long")
+	if result != "password: <Filtered>, other: <Filtered>" {
+		t.Fatalf("bad: %s", result)
+	}
+}
+
+func TestAddSensitiveConfigValues(t *testing.T) {
+	defer resetSensitiveValues()
+
+	AddSensitiveConfigValues(
+		"foo",
+		map[string]interface{}{
+			SensitiveVariablesConfigKey: []string{"hunter2"},
+		})
+
+	if result := ScrubSensitive("hunter2"); result != SensitiveFilterText {
+		t.Fatalf("bad: %s", result)
+	}
+}
+
+func TestAddSensitiveConfigValues_pointer(t *testing.T) {
+	defer resetSensitiveValues()
+
+	// Configurations sent over RPC are pointers to maps
+	AddSensitiveConfigValues(&map[string]interface{}{
+		SensitiveVariablesConfigKey: []string{"hunter2"},
+	})
+
+	if result := ScrubSensitive("hunter2"); result != SensitiveFilterText {
+		t.Fatalf("bad: %s", result)
+	}
+}
+
+func TestSensitiveWriter(t *testing.T) {
+	defer resetSensitiveValues()
+	AddSensitiveValues("hunter2")
+
+	buf := new(bytes.Buffer)
+	w := &SensitiveWriter{Writer: buf}
+
+	n, err := w.Write([]byte("the password is hunter2\n"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n != 24 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	if buf.String() != "the password is <Filtered>\n" {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestBasicUi_sensitive(t *testing.T) {
+	defer resetSensitiveValues()
+	AddSensitiveValues("hunter2")
+
+	bufferUi := testUi()
+	bufferUi.Say("pass hunter2")
+	if result := readWriter(bufferUi); result != "pass <Filtered>\n" {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	bufferUi.Error("pass hunter2")
+	if result := readErrorWriter(bufferUi); result != "pass <Filtered>\n" {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestMachineReadableUi_sensitive(t *testing.T) {
+	defer resetSensitiveValues()
+	AddSensitiveValues("hunter2")
+
+	buf := new(bytes.Buffer)
+	ui := &MachineReadableUi{Writer: buf}
+	ui.Machine("foo", "pass hunter2")
+
+	if !bytes.HasSuffix(buf.Bytes(), []byte(",,foo,pass <Filtered>\n")) {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
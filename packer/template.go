@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"github.com/mitchellh/mapstructure"
 	jsonutil "github.com/mitchellh/packer/common/json"
+	yamlutil "github.com/mitchellh/packer/common/yaml"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"text/template"
 	"time"
 )
@@ -19,11 +24,32 @@ import (
 // are until we read the "type" field.
 type rawTemplate struct {
 	Description    string
+	Include        []string
 	Variables      map[string]interface{}
 	Builders       []map[string]interface{}
 	Hooks          map[string][]string
 	Provisioners   []map[string]interface{}
 	PostProcessors []interface{} `mapstructure:"post-processors"`
+
+	// These record which file each builder, provisioner and post-processor
+	// came from, in the same order as the slices above.
+	builderSources       []rawSource
+	provisionerSources   []rawSource
+	postProcessorSources []rawSource
+}
+
+// rawSource records where a single builder, provisioner or post-processor
+// definition was read from so that errors can point back to it.
+type rawSource struct {
+	Path  string // The file it was included from, or "" for the root template
+	Index int    // The position of the definition within that file
+
+	// The file the definition is in, as errors should name it, the path
+	// of the definition within that file, and the positions of all the
+	// values within the file. See jsonutil.Positions.
+	file      string
+	pointer   string
+	positions map[string]jsonutil.Position
 }
 
 // The Template struct represents a parsed template, parsed into the most
@@ -45,7 +71,27 @@ type RawBuilderConfig struct {
 	Name string
 	Type string
 
+	// The name of the builder this builder is based on, if any. The base
+	// can be an abstract builder, which is only a base for other builders.
+	// The RawConfig is the effective configuration, with this builder's
+	// own configuration merged over that of its base.
+	Base string
+
+	// For the builds expanded from a builder with a "matrix", the name
+	// of that builder and the values of the matrix axes for this build.
+	// The axis values are available as user variables.
+	MatrixName   string
+	MatrixValues map[string]string
+
+	// The names of the builds that this build depends on. The build only
+	// runs once these builds have completed, and its configuration can
+	// refer to their artifacts. The name of a builder with a matrix is
+	// resolved into every build of that matrix.
+	DependsOn []string `mapstructure:"depends_on"`
+
 	RawConfig interface{}
+
+	source rawSource
 }
 
 // RawPostProcessorConfig represents a raw, unprocessed post-processor
@@ -57,6 +103,8 @@ type RawPostProcessorConfig struct {
 	Type              string
 	KeepInputArtifact bool `mapstructure:"keep_input_artifact"`
 	RawConfig         map[string]interface{}
+
+	source rawSource
 }
 
 // RawProvisionerConfig represents a raw, unprocessed provisioner configuration.
@@ -68,18 +116,51 @@ type RawProvisionerConfig struct {
 	Type           string
 	Override       map[string]interface{}
 	RawPauseBefore string `mapstructure:"pause_before"`
+	RawTimeout     string `mapstructure:"timeout"`
 
 	RawConfig interface{}
 
 	pauseBefore time.Duration
+	timeout     time.Duration
+	source      rawSource
+
+	// The timeouts that overrides set for their builds, which replace
+	// the timeout of the provisioner.
+	overrideTimeouts map[string]time.Duration
 }
 
+// The types that a user variable can have.
+const (
+	VariableTypeString = "string"
+	VariableTypeNumber = "number"
+	VariableTypeBool   = "bool"
+	VariableTypeList   = "list"
+	VariableTypeMap    = "map"
+)
+
 // RawVariable represents a variable configuration within a template.
+//
+// Default and Value are always the string form of the variable, which is
+// what the "user" template function returns. TypedDefault and TypedValue
+// hold the same values in the Go type matching the variable's Type:
+// string, float64, bool, []interface{} or map[string]interface{}.
 type RawVariable struct {
-	Default  string // The default value for this variable
-	Required bool   // If the variable is required or not
-	Value    string // The set value for this variable
-	HasValue bool   // True if the value was set
+	Type      string // The type of the variable, one of VariableType*
+	Default   string // The default value for this variable
+	Required  bool   // If the variable is required or not
+	Sensitive bool   // If the value must be scrubbed from all output
+	Value     string // The set value for this variable
+	HasValue  bool   // True if the value was set
+
+	TypedDefault interface{} // The default value as its type
+	TypedValue   interface{} // The set value as its type
+
+	// Validation rules for the value. For list variables, these apply
+	// to each element of the list.
+	Pattern string        // A regular expression the value must match
+	Allowed []interface{} // The values the variable may have
+	Min     *float64      // The minimum of a number variable
+	Max     *float64      // The maximum of a number variable
 }
 
 // ParseTemplate takes a byte slice and parses a Template from it, returning
@@ -89,42 +170,45 @@ type RawVariable struct {
 // way.
 //
 // The second parameter, vars, are the values for a set of user variables.
+//
+// The template can be either JSON or YAML. Data that doesn't start like a
+// JSON object is parsed as YAML.
+//
+// Any files listed in the root level "include" key are resolved relative
+// to the current working directory. Use ParseTemplateFile to resolve them
+// relative to the template file instead.
 func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error) {
-	var rawTplInterface interface{}
-	err = jsonutil.Unmarshal(data, &rawTplInterface)
-	if err != nil {
-		return
-	}
-
-	// Decode the raw template interface into the actual rawTemplate
-	// structure, checking for any extranneous keys along the way.
-	var md mapstructure.Metadata
-	var rawTpl rawTemplate
-	decoderConfig := &mapstructure.DecoderConfig{
-		Metadata: &md,
-		Result:   &rawTpl,
-	}
-
-	decoder, err := mapstructure.NewDecoder(decoderConfig)
-	if err != nil {
-		return
-	}
+	return parseTemplate(data, "", vars)
+}
 
-	err = decoder.Decode(rawTplInterface)
+// parseTemplate parses the template in data that was read from path,
+// which is empty if the template didn't come from a file. Templates with
+// a YAML extension are always parsed as YAML.
+func parseTemplate(data []byte, path string, vars map[string]string) (t *Template, err error) {
+	rawTpl, unused, err := decodeRawTemplate(data, path, false)
 	if err != nil {
 		return
 	}
 
 	errors := make([]error, 0)
-
-	if len(md.Unused) > 0 {
-		sort.Strings(md.Unused)
-		for _, unused := range md.Unused {
-			errors = append(
-				errors, fmt.Errorf("Unknown root level key in template: '%s'", unused))
+	errors = append(errors, unused...)
+
+	// Merge in all the included templates, so everything below operates
+	// on the complete template.
+	var stack []string
+	dir := ""
+	if path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
 		}
+
+		stack = []string{absPath}
+		dir = filepath.Dir(path)
 	}
 
+	errors = append(errors, rawTpl.loadIncludes(dir, stack)...)
+
 	t = &Template{}
 	t.Description = rawTpl.Description
 	t.Variables = make(map[string]RawVariable)
@@ -135,22 +219,7 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 
 	// Gather all the variables
 	for k, v := range rawTpl.Variables {
-		var variable RawVariable
-		variable.Required = v == nil
-
-		// Create a new mapstructure decoder in order to decode the default
-		// value since this is the only value in the regular template that
-		// can be weakly typed.
-		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-			Result:           &variable.Default,
-			WeaklyTypedInput: true,
-		})
-		if err != nil {
-			// This should never happen.
-			panic(err)
-		}
-
-		err = decoder.Decode(v)
+		variable, err := parseVariable(v)
 		if err != nil {
 			errors = append(errors,
 				fmt.Errorf("Error decoding default value for user var '%s': %s", k, err))
@@ -159,61 +228,185 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 
 		// Set the value of this variable if we have it
 		if val, ok := vars[k]; ok {
-			variable.HasValue = true
-			variable.Value = val
 			delete(vars, k)
+
+			typed, err := convertVariable(variable.Type, val)
+			if err != nil {
+				// The conversion errors show the value, which mustn't
+				// be output for sensitive variables.
+				if variable.Sensitive {
+					err = fmt.Errorf("the value isn't a valid %s", variable.Type)
+				}
+
+				errors = append(errors,
+					fmt.Errorf("Invalid value for user var '%s': %s", k, err))
+				continue
+			}
+
+			variable.HasValue = true
+			variable.TypedValue = typed
+			variable.Value = variableString(typed)
+
+			errors = append(errors, validateVariable(k, variable, typed)...)
 		}
 
 		t.Variables[k] = variable
 	}
 
-	// Gather all the builders
+	// Gather all the builders. Builders can be based on other builders,
+	// so the effective configurations are only resolved once every
+	// builder is known. Abstract builders only serve as a base for other
+	// builders and aren't builds themselves.
+	allBuilders := make(map[string]RawBuilderConfig)
+	builderNames := make([]string, 0, len(rawTpl.Builders))
+	builderSources := make(map[string]rawSource)
+	abstract := make(map[string]bool)
+	matrices := make(map[string]interface{})
 	for i, v := range rawTpl.Builders {
+		src := rawTpl.builderSources[i]
+
 		var raw RawBuilderConfig
 		if err := mapstructure.Decode(v, &raw); err != nil {
 			if merr, ok := err.(*mapstructure.Error); ok {
 				for _, err := range merr.Errors {
-					errors = append(errors, fmt.Errorf("builder %d: %s", i+1, err))
+					errors = append(errors, src.errorAt("", fmt.Errorf("builder %s: %s", src, err)))
 				}
 			} else {
-				errors = append(errors, fmt.Errorf("builder %d: %s", i+1, err))
+				errors = append(errors, src.errorAt("", fmt.Errorf("builder %s: %s", src, err)))
 			}
 
 			continue
 		}
 
-		if raw.Type == "" {
-			errors = append(errors, fmt.Errorf("builder %d: missing 'type'", i+1))
+		isAbstract := false
+		if rawAbstract, ok := v["abstract"]; ok {
+			isAbstract, ok = rawAbstract.(bool)
+			if !ok {
+				errors = append(errors, src.errorAt(jsonutil.Path("abstract"), fmt.Errorf(
+					"builder %s: 'abstract' must be a boolean", src)))
+				continue
+			}
+		}
+
+		if raw.Type == "" && raw.Base == "" && !isAbstract {
+			errors = append(errors, src.errorAt("", fmt.Errorf("builder %s: missing 'type'", src)))
 			continue
 		}
 
 		// Attempt to get the name of the builder. If the "name" key
-		// missing, use the "type" field, which is guaranteed to exist
-		// at this point.
+		// missing, use the "type" field. Builders with a base or that
+		// are abstract may not have a type, so they need a name.
 		if raw.Name == "" {
 			raw.Name = raw.Type
 		}
 
+		if raw.Name == "" {
+			errors = append(errors, src.errorAt("", fmt.Errorf("builder %s: missing 'name'", src)))
+			continue
+		}
+
 		// Check if we already have a builder with this name and error if so
-		if _, ok := t.Builders[raw.Name]; ok {
-			errors = append(errors, fmt.Errorf("builder with name '%s' already exists", raw.Name))
+		if _, ok := allBuilders[raw.Name]; ok {
+			errors = append(errors, src.errorAt(jsonutil.Path("name"), fmt.Errorf(
+				"builder %s: builder with name '%s' already exists", src, raw.Name)))
 			continue
 		}
 
 		// Now that we have the name, remove it from the config - as the builder
 		// itself doesn't know about, and it will cause a validation error.
 		delete(v, "name")
+		delete(v, "base")
+		delete(v, "abstract")
+		delete(v, "depends_on")
+
+		if matrix, ok := v["matrix"]; ok {
+			matrices[raw.Name] = matrix
+			delete(v, "matrix")
+		}
 
 		raw.RawConfig = v
+		raw.source = src
+
+		allBuilders[raw.Name] = raw
+		builderNames = append(builderNames, raw.Name)
+		builderSources[raw.Name] = src
+		abstract[raw.Name] = isAbstract
+	}
+
+	// The list variables that matrix axes can take their values from
+	listVariables := make(map[string]interface{})
+	for k, v := range t.Variables {
+		if v.Type != VariableTypeList {
+			continue
+		}
+
+		if v.HasValue {
+			listVariables[k] = v.TypedValue
+		} else {
+			listVariables[k] = v.TypedDefault
+		}
+	}
+
+	// The builders are resolved in the order of the template, so the
+	// errors are too, and it's always the later of two builders with the
+	// same name that's reported.
+	resolvedBuilders := make(map[string]RawBuilderConfig)
+	for _, name := range builderNames {
+		src := builderSources[name]
+		raw, err := resolveBuilderBase(name, nil, allBuilders, resolvedBuilders)
+		if err != nil {
+			errors = append(errors, src.errorAt(jsonutil.Path("base"), fmt.Errorf("builder %s: %s", src, err)))
+			continue
+		}
+
+		if abstract[name] {
+			continue
+		}
+
+		if raw.Type == "" {
+			errors = append(errors, src.errorAt("", fmt.Errorf("builder %s: missing 'type'", src)))
+			continue
+		}
+
+		matrix, ok := matrices[name]
+		if !ok {
+			if _, ok := t.Builders[name]; ok {
+				errors = append(errors, src.errorAt(jsonutil.Path("name"), fmt.Errorf(
+					"builder %s: builder with name '%s' already exists", src, name)))
+				continue
+			}
+
+			t.Builders[name] = raw
+			continue
+		}
 
-		t.Builders[raw.Name] = raw
+		cells, err := expandBuilderMatrix(raw, matrix, listVariables)
+		if err != nil {
+			errors = append(errors, src.errorAt(jsonutil.Path("matrix"), fmt.Errorf("builder %s: %s", src, err)))
+			continue
+		}
+
+		for _, cell := range cells {
+			_, exists := t.Builders[cell.Name]
+			if _, ok := allBuilders[cell.Name]; ok || exists {
+				errors = append(errors, src.errorAt(jsonutil.Path("matrix"), fmt.Errorf(
+					"builder %s: builder with name '%s' already exists", src, cell.Name)))
+				continue
+			}
+
+			t.Builders[cell.Name] = cell
+		}
 	}
 
+	// Now that every build is known, resolve the builds they depend on
+	errors = append(errors, resolveBuildDependencies(t.Builders)...)
+
 	// Gather all the post-processors. This is a complicated process since there
 	// are actually three different formats that the user can use to define
 	// a post-processor.
 	for i, rawV := range rawTpl.PostProcessors {
-		rawPP, err := parsePostProcessor(i, rawV)
+		src := rawTpl.postProcessorSources[i]
+		rawPP, err := parsePostProcessor(src, rawV)
 		if err != nil {
 			errors = append(errors, err...)
 			continue
@@ -221,24 +414,29 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 
 		configs := make([]RawPostProcessorConfig, 0, len(rawPP))
 		for j, pp := range rawPP {
+			ppSrc := src
+			if _, ok := rawV.([]interface{}); ok {
+				ppSrc = src.element(j)
+			}
+
 			var config RawPostProcessorConfig
 			if err := mapstructure.Decode(pp, &config); err != nil {
 				if merr, ok := err.(*mapstructure.Error); ok {
 					for _, err := range merr.Errors {
-						errors = append(errors,
-							fmt.Errorf("Post-processor #%d.%d: %s", i+1, j+1, err))
+						errors = append(errors, ppSrc.errorAt("",
+							fmt.Errorf("Post-processor #%s: %s", src.Nested(j), err)))
 					}
 				} else {
-					errors = append(errors,
-						fmt.Errorf("Post-processor %d.%d: %s", i+1, j+1, err))
+					errors = append(errors, ppSrc.errorAt("",
+						fmt.Errorf("Post-processor %s: %s", src.Nested(j), err)))
 				}
 
 				continue
 			}
 
 			if config.Type == "" {
-				errors = append(errors,
-					fmt.Errorf("Post-processor %d.%d: missing 'type'", i+1, j+1))
+				errors = append(errors, ppSrc.errorAt("",
+					fmt.Errorf("Post-processor %s: missing 'type'", src.Nested(j))))
 				continue
 			}
 
@@ -249,14 +447,15 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 			// Verify that the only settings are good
 			if errs := config.TemplateOnlyExcept.Validate(t.Builders); len(errs) > 0 {
 				for _, err := range errs {
-					errors = append(errors,
-						fmt.Errorf("Post-processor %d.%d: %s", i+1, j+1, err))
+					errors = append(errors, ppSrc.errorAt(config.TemplateOnlyExcept.path(),
+						fmt.Errorf("Post-processor %s: %s", src.Nested(j), err)))
 				}
 
 				continue
 			}
 
 			config.RawConfig = pp
+			config.source = ppSrc
 
 			// Add it to the list of configs
 			configs = append(configs, config)
@@ -267,21 +466,22 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 
 	// Gather all the provisioners
 	for i, v := range rawTpl.Provisioners {
+		src := rawTpl.provisionerSources[i]
 		raw := &t.Provisioners[i]
 		if err := mapstructure.Decode(v, raw); err != nil {
 			if merr, ok := err.(*mapstructure.Error); ok {
 				for _, err := range merr.Errors {
-					errors = append(errors, fmt.Errorf("provisioner %d: %s", i+1, err))
+					errors = append(errors, src.errorAt("", fmt.Errorf("provisioner %s: %s", src, err)))
 				}
 			} else {
-				errors = append(errors, fmt.Errorf("provisioner %d: %s", i+1, err))
+				errors = append(errors, src.errorAt("", fmt.Errorf("provisioner %s: %s", src, err)))
 			}
 
 			continue
 		}
 
 		if raw.Type == "" {
-			errors = append(errors, fmt.Errorf("provisioner %d: missing 'type'", i+1))
+			errors = append(errors, src.errorAt("", fmt.Errorf("provisioner %s: missing 'type'", src)))
 			continue
 		}
 
@@ -292,16 +492,16 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 		// Verify that the override keys exist...
 		for name, _ := range raw.Override {
 			if _, ok := t.Builders[name]; !ok {
-				errors = append(
-					errors, fmt.Errorf("provisioner %d: build '%s' not found for override", i+1, name))
+				errors = append(errors, src.errorAt(jsonutil.Path("override", name), fmt.Errorf(
+					"provisioner %s: build '%s' not found for override", src, name)))
 			}
 		}
 
 		// Verify that the only settings are good
 		if errs := raw.TemplateOnlyExcept.Validate(t.Builders); len(errs) > 0 {
 			for _, err := range errs {
-				errors = append(errors,
-					fmt.Errorf("provisioner %d: %s", i+1, err))
+				errors = append(errors, src.errorAt(raw.TemplateOnlyExcept.path(),
+					fmt.Errorf("provisioner %s: %s", src, err)))
 			}
 		}
 
@@ -309,20 +509,71 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 		if raw.RawPauseBefore != "" {
 			duration, err := time.ParseDuration(raw.RawPauseBefore)
 			if err != nil {
-				errors = append(
-					errors, fmt.Errorf(
-						"provisioner %d: pause_before invalid: %s",
-						i+1, err))
+				errors = append(errors, src.errorAt(jsonutil.Path("pause_before"), fmt.Errorf(
+					"provisioner %s: pause_before invalid: %s", src, err)))
 			}
 
 			raw.pauseBefore = duration
 		}
 
-		// Remove the pause_before setting if it is there so that we don't
-		// get template validation errors later.
+		// Setup the timeout
+		if raw.RawTimeout != "" {
+			duration, err := time.ParseDuration(raw.RawTimeout)
+			if err == nil && duration <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+			if err != nil {
+				errors = append(errors, src.errorAt(jsonutil.Path("timeout"), fmt.Errorf(
+					"provisioner %s: timeout invalid: %s", src, err)))
+			}
+
+			raw.timeout = duration
+		}
+
+		// An override can set the timeout for its build, which is removed
+		// from the override like the timeout of the provisioner is.
+		for name, override := range raw.Override {
+			m, ok := override.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			rawTimeout, ok := m["timeout"]
+			if !ok {
+				continue
+			}
+			delete(m, "timeout")
+
+			var duration time.Duration
+			var err error
+			str, ok := rawTimeout.(string)
+			if !ok {
+				err = fmt.Errorf("must be a string")
+			} else {
+				duration, err = time.ParseDuration(str)
+				if err == nil && duration <= 0 {
+					err = fmt.Errorf("must be positive")
+				}
+			}
+			if err != nil {
+				errors = append(errors, src.errorAt(jsonutil.Path("override", name, "timeout"), fmt.Errorf(
+					"provisioner %s: timeout of override '%s' invalid: %s", src, name, err)))
+				continue
+			}
+
+			if raw.overrideTimeouts == nil {
+				raw.overrideTimeouts = make(map[string]time.Duration)
+			}
+			raw.overrideTimeouts[name] = duration
+		}
+
+		// Remove the pause_before and timeout settings if they are there
+		// so that we don't get template validation errors later.
 		delete(v, "pause_before")
+		delete(v, "timeout")
 
 		raw.RawConfig = v
+		raw.source = src
 	}
 
 	if len(t.Builders) == 0 {
@@ -345,10 +596,9 @@ func ParseTemplate(data []byte, vars map[string]string) (t *Template, err error)
 }
 
 // ParseTemplateFile takes the given template file and parses it into
-// a single template.
+// a single template. Included files are resolved relative to the directory
+// of the template file.
 func ParseTemplateFile(path string, vars map[string]string) (*Template, error) {
-	var data []byte
-
 	if path == "-" {
 		// Read from stdin...
 		buf := new(bytes.Buffer)
@@ -357,19 +607,291 @@ func ParseTemplateFile(path string, vars map[string]string) (*Template, error) {
 			return nil, err
 		}
 
-		data = buf.Bytes()
-	} else {
-		var err error
-		data, err = ioutil.ReadFile(path)
+		// Includes are relative to the working directory
+		return ParseTemplate(buf.Bytes(), vars)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTemplate(data, path, vars)
+}
+
+// decodeRawTemplate decodes a single template file, in JSON or YAML, into
+// a rawTemplate. The file is the path of the template file, or empty if
+// it didn't come from a file, and is used for the positions of errors.
+// For included files, it is also recorded as the source of every
+// definition within the file. Errors for unknown root level keys are
+// returned separately so the caller can report them along with others.
+func decodeRawTemplate(data []byte, file string, include bool) (*rawTemplate, []error, error) {
+	unmarshal := jsonutil.Unmarshal
+	positions := jsonutil.Positions
+	if yamlutil.IsYAML(file, data) {
+		unmarshal = yamlutil.Unmarshal
+		positions = yamlutil.Positions
+	}
+
+	var rawTplInterface interface{}
+	if err := unmarshal(data, &rawTplInterface); err != nil {
+		return nil, nil, err
+	}
+
+	// Decode the raw template interface into the actual rawTemplate
+	// structure, checking for any extranneous keys along the way.
+	var md mapstructure.Metadata
+	var rawTpl rawTemplate
+	decoderConfig := &mapstructure.DecoderConfig{
+		Metadata: &md,
+		Result:   &rawTpl,
+	}
+
+	decoder, err := mapstructure.NewDecoder(decoderConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := decoder.Decode(rawTplInterface); err != nil {
+		return nil, nil, err
+	}
+
+	// The document was already decoded, so this can't really fail. If it
+	// does anyways, the errors just don't have positions.
+	pos, err := positions(data)
+	if err != nil {
+		log.Printf("Error finding positions in template: %s", err)
+	}
+
+	root := rawSource{file: file, positions: pos}
+	if include {
+		root.Path = file
+	}
+
+	sources := func(key string, n int) []rawSource {
+		result := make([]rawSource, n)
+		for i := range result {
+			result[i] = root
+			result[i].Index = i
+			result[i].pointer = jsonutil.Path(key, i)
+		}
+
+		return result
+	}
+
+	rawTpl.builderSources = sources("builders", len(rawTpl.Builders))
+	rawTpl.provisionerSources = sources("provisioners", len(rawTpl.Provisioners))
+	rawTpl.postProcessorSources = sources("post-processors", len(rawTpl.PostProcessors))
+
+	sort.Strings(md.Unused)
+	unused := make([]error, len(md.Unused))
+	for i, k := range md.Unused {
+		unused[i] = root.errorAt(jsonutil.Path(k), fmt.Errorf(
+			"Unknown root level key in template: '%s'", k))
+	}
+
+	return &rawTpl, unused, nil
+}
+
+// loadIncludes reads every file listed in the "include" key and merges
+// its contents into this template. Definitions from included files come
+// before the ones in this template, in the order they were included, and
+// variables defined here override variables of the same name in included
+// files. Relative paths are resolved against dir. The stack contains the
+// absolute paths of the files currently being included and is used to
+// detect include cycles.
+func (r *rawTemplate) loadIncludes(dir string, stack []string) []error {
+	errors := make([]error, 0)
+	result := new(rawTemplate)
+
+	for _, include := range r.Include {
+		path := include
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		absPath, err := filepath.Abs(path)
 		if err != nil {
-			return nil, err
+			errors = append(errors, fmt.Errorf(
+				"Error resolving include '%s': %s", include, err))
+			continue
+		}
+
+		cycle := false
+		for _, p := range stack {
+			if p == absPath {
+				cycle = true
+				break
+			}
+		}
+
+		if cycle {
+			chain := make([]string, len(stack), len(stack)+1)
+			copy(chain, stack)
+			chain = append(chain, absPath)
+
+			errors = append(errors, fmt.Errorf(
+				"Include cycle detected: %s", strings.Join(chain, " -> ")))
+			continue
 		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			errors = append(errors, fmt.Errorf(
+				"Error reading include '%s': %s", include, err))
+			continue
+		}
+
+		included, unused, err := decodeRawTemplate(data, path, true)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("%s: %s", path, err))
+			continue
+		}
+
+		errors = append(errors, unused...)
+
+		// Copy the stack so that sibling includes don't share it
+		childStack := make([]string, len(stack), len(stack)+1)
+		copy(childStack, stack)
+		childStack = append(childStack, absPath)
+
+		errors = append(errors, included.loadIncludes(filepath.Dir(path), childStack)...)
+		result.merge(included)
+	}
+
+	result.merge(r)
+	result.Include = nil
+	*r = *result
+	return errors
+}
+
+// merge appends the definitions of other to this template.
+func (r *rawTemplate) merge(other *rawTemplate) {
+	if other.Description != "" {
+		r.Description = other.Description
+	}
+
+	if len(other.Variables) > 0 && r.Variables == nil {
+		r.Variables = make(map[string]interface{})
+	}
+	for k, v := range other.Variables {
+		r.Variables[k] = v
+	}
+
+	if len(other.Hooks) > 0 && r.Hooks == nil {
+		r.Hooks = make(map[string][]string)
+	}
+	for k, v := range other.Hooks {
+		r.Hooks[k] = append(r.Hooks[k], v...)
+	}
+
+	r.Builders = append(r.Builders, other.Builders...)
+	r.builderSources = append(r.builderSources, other.builderSources...)
+	r.Provisioners = append(r.Provisioners, other.Provisioners...)
+	r.provisionerSources = append(r.provisionerSources, other.provisionerSources...)
+	r.PostProcessors = append(r.PostProcessors, other.PostProcessors...)
+	r.postProcessorSources = append(r.postProcessorSources, other.postProcessorSources...)
+}
+
+// String returns the position of the definition for use in error
+// messages, such as "2" or "2 in common/provisioners.json". The file it
+// was included from is left out if the position of the definition within
+// the file is known, since errors are prefixed with that position.
+func (s rawSource) String() string {
+	return s.Nested(-1)
+}
+
+// Nested is like String, but for the j-th element of a post-processor
+// sequence, such as "2.1". A negative j leaves out the element.
+func (s rawSource) Nested(j int) string {
+	result := fmt.Sprintf("%d", s.Index+1)
+	if j >= 0 {
+		result = fmt.Sprintf("%s.%d", result, j+1)
+	}
+
+	if s.Path != "" && s.positions == nil {
+		result = fmt.Sprintf("%s in %s", result, s.Path)
+	}
+
+	return result
+}
+
+// element returns the source of the j-th element of a post-processor
+// sequence.
+func (s rawSource) element(j int) rawSource {
+	s.pointer += jsonutil.Path(j)
+	return s
+}
+
+// position returns the position of the value at the given path within
+// the definition, such as jsonutil.Path("type"), as "file:line:col". If
+// the value isn't known, such as when it came from a base builder, it
+// is the position of the definition itself.
+func (s rawSource) position(path string) string {
+	pos, ok := s.positions[s.pointer+path]
+	if !ok {
+		pos, ok = s.positions[s.pointer]
 	}
 
-	return ParseTemplate(data, vars)
+	switch {
+	case !ok:
+		return s.file
+	case s.file == "":
+		return pos.String()
+	default:
+		return fmt.Sprintf("%s:%s", s.file, pos)
+	}
 }
 
-func parsePostProcessor(i int, rawV interface{}) (result []map[string]interface{}, errors []error) {
+// errorAt prefixes the error with the position of the value at the given
+// path within the definition.
+func (s rawSource) errorAt(path string, err error) error {
+	pos := s.position(path)
+	if pos == "" {
+		return err
+	}
+
+	return fmt.Errorf("%s: %s", pos, err)
+}
+
+// configKeyRe matches the configuration key at the start of an error
+// from a component, such as "Unknown configuration key: foo" from
+// common.CheckUnusedConfig, "'foo' expected type 'string'" from decoding
+// the configuration, or "foo must be specified".
+var configKeyRe = regexp.MustCompile(
+	`^(?:Unknown configuration key: (\S+)|'([^'\[.]+)[^']*'|([A-Za-z0-9_]+)\b)`)
+
+// prepareError prefixes every error within err, which came from preparing
+// the component with this definition, with the position of the
+// configuration key that the error is about, or with the position of the
+// definition if the error isn't about any key within it.
+func (s rawSource) prepareError(err error) error {
+	if s.positions == nil {
+		return err
+	}
+
+	if merr, ok := err.(*MultiError); ok {
+		errs := make([]error, len(merr.Errors))
+		for i, err := range merr.Errors {
+			errs[i] = s.prepareError(err)
+		}
+
+		return &MultiError{errs}
+	}
+
+	path := ""
+	if match := configKeyRe.FindStringSubmatch(err.Error()); match != nil {
+		for _, key := range match[1:] {
+			if key != "" {
+				path = jsonutil.Path(key)
+			}
+		}
+	}
+
+	return s.errorAt(path, err)
+}
+
+func parsePostProcessor(src rawSource, rawV interface{}) (result []map[string]interface{}, errors []error) {
 	switch v := rawV.(type) {
 	case string:
 		result = []map[string]interface{}{
@@ -387,11 +909,11 @@ func parsePostProcessor(i int, rawV interface{}) (result []map[string]interface{
 			case map[string]interface{}:
 				result[j] = innerV
 			case []interface{}:
-				errors = append(
-					errors,
-					fmt.Errorf("Post-processor %d.%d: sequences not allowed to be nested in sequences", i+1, j+1))
+				errors = append(errors, src.element(j).errorAt("", fmt.Errorf(
+					"Post-processor %s: sequences not allowed to be nested in sequences", src.Nested(j))))
 			default:
-				errors = append(errors, fmt.Errorf("Post-processor %d.%d is in a bad format.", i+1, j+1))
+				errors = append(errors, src.element(j).errorAt("", fmt.Errorf(
+					"Post-processor %s is in a bad format.", src.Nested(j))))
 			}
 		}
 
@@ -400,7 +922,7 @@ func parsePostProcessor(i int, rawV interface{}) (result []map[string]interface{
 		}
 	default:
 		result = nil
-		errors = []error{fmt.Errorf("Post-processor %d is in a bad format.", i+1)}
+		errors = []error{src.errorAt("", fmt.Errorf("Post-processor %s is in a bad format.", src))}
 	}
 
 	return
@@ -417,6 +939,80 @@ func (t *Template) BuildNames() []string {
 	return names
 }
 
+// resolveBuilderBase returns the builder with the given name with its
+// effective configuration, which is its own configuration deep merged
+// over that of its base builder. The stack holds the names of the
+// builders being resolved, to detect cycles.
+func resolveBuilderBase(
+	name string, stack []string,
+	builders, resolved map[string]RawBuilderConfig) (RawBuilderConfig, error) {
+	if raw, ok := resolved[name]; ok {
+		return raw, nil
+	}
+
+	raw := builders[name]
+	if raw.Base == "" {
+		resolved[name] = raw
+		return raw, nil
+	}
+
+	stack = append(stack, name)
+	for _, s := range stack[:len(stack)-1] {
+		if s == name {
+			return raw, fmt.Errorf(
+				"base builder cycle detected: %s", strings.Join(stack, " -> "))
+		}
+	}
+
+	if _, ok := builders[raw.Base]; !ok {
+		return raw, fmt.Errorf("base builder '%s' not found", raw.Base)
+	}
+
+	base, err := resolveBuilderBase(raw.Base, stack, builders, resolved)
+	if err != nil {
+		return raw, err
+	}
+
+	if raw.Type == "" {
+		raw.Type = base.Type
+	}
+
+	config := mergeRawConfig(base.RawConfig, raw.RawConfig).(map[string]interface{})
+	if raw.Type != "" {
+		config["type"] = raw.Type
+	}
+	raw.RawConfig = config
+
+	resolved[name] = raw
+	return raw, nil
+}
+
+// mergeRawConfig deep merges the raw configuration override over base,
+// returning a new value. Maps are merged key by key, and any other value
+// in override, including lists, replaces the value in base.
+func mergeRawConfig(base, override interface{}) interface{} {
+	baseMap, ok := base.(map[string]interface{})
+	if !ok {
+		return override
+	}
+
+	overrideMap, ok := override.(map[string]interface{})
+	if !ok {
+		return override
+	}
+
+	result := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+	for k, v := range baseMap {
+		result[k] = v
+	}
+
+	for k, v := range overrideMap {
+		result[k] = mergeRawConfig(result[k], v)
+	}
+
+	return result
+}
+
 // Build returns a Build for the given name.
 //
 // If the build does not exist as part of this template, an error is
@@ -447,7 +1043,8 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 	}
 
 	if builder == nil {
-		err = fmt.Errorf("Builder type not found: %s", builderConfig.Type)
+		err = builderConfig.source.errorAt(jsonutil.Path("type"),
+			fmt.Errorf("Builder type not found: %s", builderConfig.Type))
 		return
 	}
 
@@ -463,33 +1060,58 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 		"user": templateDisableUser,
 	})
 
-	// Prepare the variables
+	// Prepare the variables. The string form is what the "user" function
+	// returns, and the typed form is substituted into configurations that
+	// reference a non-string variable as a whole value.
 	var varErrors []error
 	variables := make(map[string]string)
+	typedVariables := make(map[string]interface{})
+	sensitive := make([]string, 0)
 	for k, v := range t.Variables {
 		if v.Required && !v.HasValue {
 			varErrors = append(varErrors,
 				fmt.Errorf("Required user variable '%s' not set", k))
 		}
 
-		var val string
+		var val interface{}
 		if v.HasValue {
-			val = v.Value
+			val = v.TypedValue
 		} else {
-			val, err = varTpl.Process(v.Default, nil)
+			val, err = processVariableDefault(varTpl, v.TypedDefault)
 			if err != nil {
 				varErrors = append(varErrors,
 					fmt.Errorf("Error processing user variable '%s': %s'", k, err))
+			} else if !v.Required {
+				// Set values are validated when the template is parsed,
+				// but defaults can only be validated once processed.
+				varErrors = append(varErrors, validateVariable(k, v, val)...)
 			}
 		}
 
-		variables[k] = val
+		variables[k] = variableString(val)
+		if v.Type != VariableTypeString {
+			typedVariables[k] = val
+		}
+
+		if v.Sensitive {
+			sensitive = append(sensitive, sensitiveStrings(val)...)
+		}
 	}
 
 	if len(varErrors) > 0 {
 		return nil, &MultiError{varErrors}
 	}
 
+	// The values of the matrix axes of this build override the variables
+	for k, v := range builderConfig.MatrixValues {
+		variables[k] = v
+		delete(typedVariables, k)
+	}
+
+	// Make sure the sensitive values never show up in the output of this
+	// process. The build passes them on to the plugins.
+	AddSensitiveValues(sensitive...)
+
 	// Process the name
 	tpl, err := NewConfigTemplate()
 	if err != nil {
@@ -502,6 +1124,12 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 		return nil, err
 	}
 
+	// The names that "only" and "except" can refer to this build with
+	skipNames := []string{name}
+	if builderConfig.MatrixName != "" {
+		skipNames = append(skipNames, builderConfig.MatrixName)
+	}
+
 	// Gather the Hooks
 	hooks := make(map[string][]Hook)
 	for tplEvent, tplHooks := range t.Hooks {
@@ -527,10 +1155,12 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 
 	// Prepare the post-processors
 	postProcessors := make([][]coreBuildPostProcessor, 0, len(t.PostProcessors))
+	ppSources := make([][]rawSource, 0, len(t.PostProcessors))
 	for _, rawPPs := range t.PostProcessors {
 		current := make([]coreBuildPostProcessor, 0, len(rawPPs))
+		currentSources := make([]rawSource, 0, len(rawPPs))
 		for _, rawPP := range rawPPs {
-			if rawPP.TemplateOnlyExcept.Skip(name) {
+			if rawPP.TemplateOnlyExcept.Skip(skipNames...) {
 				continue
 			}
 
@@ -540,15 +1170,18 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 			}
 
 			if pp == nil {
-				return nil, fmt.Errorf("PostProcessor type not found: %s", rawPP.Type)
+				return nil, rawPP.source.errorAt(jsonutil.Path("type"),
+					fmt.Errorf("PostProcessor type not found: %s", rawPP.Type))
 			}
 
+			config := interpolateTypedVariables(rawPP.RawConfig, typedVariables)
 			current = append(current, coreBuildPostProcessor{
 				processor:         pp,
 				processorType:     rawPP.Type,
-				config:            rawPP.RawConfig,
+				config:            config.(map[string]interface{}),
 				keepInputArtifact: rawPP.KeepInputArtifact,
 			})
+			currentSources = append(currentSources, rawPP.source)
 		}
 
 		// If we have no post-processors in this chain, just continue.
@@ -558,32 +1191,55 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 		}
 
 		postProcessors = append(postProcessors, current)
+		ppSources = append(ppSources, currentSources)
 	}
 
 	// Prepare the provisioners
 	provisioners := make([]coreBuildProvisioner, 0, len(t.Provisioners))
+	provSources := make([]rawSource, 0, len(t.Provisioners))
 	for _, rawProvisioner := range t.Provisioners {
-		if rawProvisioner.TemplateOnlyExcept.Skip(name) {
+		if rawProvisioner.TemplateOnlyExcept.Skip(skipNames...) {
 			continue
 		}
 
+		// Breakpoints are built into the core rather than found through
+		// the components, see BreakpointProvisionerType for why.
 		var provisioner Provisioner
-		provisioner, err = components.Provisioner(rawProvisioner.Type)
-		if err != nil {
-			return
+		if rawProvisioner.Type == BreakpointProvisionerType {
+			provisioner = new(BreakpointProvisioner)
+		} else {
+			provisioner, err = components.Provisioner(rawProvisioner.Type)
+			if err != nil {
+				return
+			}
 		}
 
 		if provisioner == nil {
-			err = fmt.Errorf("Provisioner type not found: %s", rawProvisioner.Type)
+			err = rawProvisioner.source.errorAt(jsonutil.Path("type"),
+				fmt.Errorf("Provisioner type not found: %s", rawProvisioner.Type))
 			return
 		}
 
 		configs := make([]interface{}, 1, 2)
-		configs[0] = rawProvisioner.RawConfig
+		configs[0] = interpolateTypedVariables(rawProvisioner.RawConfig, typedVariables)
 
 		if rawProvisioner.Override != nil {
 			if override, ok := rawProvisioner.Override[name]; ok {
-				configs = append(configs, override)
+				configs = append(configs,
+					interpolateTypedVariables(override, typedVariables))
+			}
+		}
+
+		// The timeout is wrapped first so that it doesn't include the pause
+		timeout := rawProvisioner.timeout
+		if d, ok := rawProvisioner.overrideTimeouts[name]; ok {
+			timeout = d
+		}
+
+		if timeout > 0 {
+			provisioner = &TimeoutProvisioner{
+				Timeout:     timeout,
+				Provisioner: provisioner,
 			}
 		}
 
@@ -594,19 +1250,26 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 			}
 		}
 
-		coreProv := coreBuildProvisioner{provisioner, configs}
+		coreProv := coreBuildProvisioner{provisioner, rawProvisioner.Type, configs}
 		provisioners = append(provisioners, coreProv)
+		provSources = append(provSources, rawProvisioner.source)
 	}
 
 	b = &coreBuild{
 		name:           name,
 		builder:        builder,
-		builderConfig:  builderConfig.RawConfig,
+		builderConfig:  interpolateTypedVariables(builderConfig.RawConfig, typedVariables),
 		builderType:    builderConfig.Type,
 		hooks:          hooks,
 		postProcessors: postProcessors,
 		provisioners:   provisioners,
 		variables:      variables,
+		sensitive:      sensitive,
+		sources: coreBuildSources{
+			builder:        builderConfig.source,
+			provisioners:   provSources,
+			postProcessors: ppSources,
+		},
 	}
 
 	return
@@ -625,12 +1288,14 @@ func (t *TemplateOnlyExcept) Prune(raw map[string]interface{}) {
 	delete(raw, "only")
 }
 
-// Skip tests if we should skip putting this item onto a build.
-func (t *TemplateOnlyExcept) Skip(name string) bool {
+// Skip tests if we should skip putting this item onto a build. The names
+// are all the names the build is known by: its own name and, for a build
+// expanded from a matrix, the name of the matrix builder.
+func (t *TemplateOnlyExcept) Skip(names ...string) bool {
 	if len(t.Only) > 0 {
 		onlyFound := false
 		for _, n := range t.Only {
-			if n == name {
+			if containsString(names, n) {
 				onlyFound = true
 				break
 			}
@@ -644,7 +1309,7 @@ func (t *TemplateOnlyExcept) Skip(name string) bool {
 
 	// If the name is in the except list, then skip that
 	for _, n := range t.Except {
-		if n == name {
+		if containsString(names, n) {
 			return true
 		}
 	}
@@ -652,6 +1317,16 @@ func (t *TemplateOnlyExcept) Skip(name string) bool {
 	return false
 }
 
+// path returns the path of the "only" or "except" key within the
+// definition, whichever is set, for the positions of errors.
+func (t *TemplateOnlyExcept) path() string {
+	if len(t.Only) > 0 {
+		return jsonutil.Path("only")
+	}
+
+	return jsonutil.Path("except")
+}
+
 // Validates the only/except parameters.
 func (t *TemplateOnlyExcept) Validate(b map[string]RawBuilderConfig) (e []error) {
 	if len(t.Only) > 0 && len(t.Except) > 0 {
@@ -661,7 +1336,7 @@ func (t *TemplateOnlyExcept) Validate(b map[string]RawBuilderConfig) (e []error)
 
 	if len(t.Only) > 0 {
 		for _, n := range t.Only {
-			if _, ok := b[n]; !ok {
+			if !hasBuildName(b, n) {
 				e = append(e,
 					fmt.Errorf("'only' specified builder '%s' not found", n))
 			}
@@ -669,7 +1344,7 @@ func (t *TemplateOnlyExcept) Validate(b map[string]RawBuilderConfig) (e []error)
 	}
 
 	for _, n := range t.Except {
-		if _, ok := b[n]; !ok {
+		if !hasBuildName(b, n) {
 			e = append(e,
 				fmt.Errorf("'except' specified builder '%s' not found", n))
 		}
@@ -677,3 +1352,25 @@ func (t *TemplateOnlyExcept) Validate(b map[string]RawBuilderConfig) (e []error)
 
 	return
 }
+
+// hasBuildName tests if the name refers to any of the builders, either
+// by its name or by the name of the matrix it was expanded from.
+func hasBuildName(b map[string]RawBuilderConfig, name string) bool {
+	for k, v := range b {
+		if k == name || v.MatrixName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
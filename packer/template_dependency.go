@@ -0,0 +1,128 @@
+package packer
+
+import (
+	"fmt"
+	jsonutil "github.com/mitchellh/packer/common/json"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PlaceholderUpstreamArtifacts returns an artifact for each of the given
+// builds that stands in for the artifacts they produce, so that the builds
+// that depend on them can be prepared, validating their configuration,
+// before the builds have run. The upstream functions return placeholder
+// values for them, and "upstream_file" creates an empty file in dir, since
+// builders check that the files they are given exist.
+func PlaceholderUpstreamArtifacts(names []string, dir string) map[string][]UpstreamArtifact {
+	result := make(map[string][]UpstreamArtifact)
+	for _, name := range names {
+		result[name] = []UpstreamArtifact{
+			UpstreamArtifact{
+				BuilderId:      "packer.placeholder",
+				Id:             "placeholder-" + name,
+				PlaceholderDir: filepath.Join(dir, name),
+			},
+		}
+	}
+
+	return result
+}
+
+// resolveBuildDependencies resolves the "depends_on" of every build into
+// the names of the builds it depends on, where the name of a builder with
+// a matrix refers to every build of that matrix, and verifies that the
+// builds don't depend on each other in a cycle.
+func resolveBuildDependencies(builds map[string]RawBuilderConfig) []error {
+	names := make([]string, 0, len(builds))
+	for name, _ := range builds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		raw := builds[name]
+		if len(raw.DependsOn) == 0 {
+			continue
+		}
+
+		resolved := make([]string, 0, len(raw.DependsOn))
+		for _, dep := range raw.DependsOn {
+			if dep == name {
+				errs = append(errs, raw.source.errorAt(jsonutil.Path("depends_on"),
+					fmt.Errorf("builder %s: build '%s' can't depend on itself", raw.source, name)))
+				continue
+			}
+
+			found := false
+			for _, n := range names {
+				if n != dep && builds[n].MatrixName != dep {
+					continue
+				}
+
+				found = true
+				if n != name && !containsString(resolved, n) {
+					resolved = append(resolved, n)
+				}
+			}
+
+			if !found {
+				errs = append(errs, raw.source.errorAt(jsonutil.Path("depends_on"),
+					fmt.Errorf("builder %s: build '%s' not found for depends_on", raw.source, dep)))
+			}
+		}
+
+		raw.DependsOn = resolved
+		builds[name] = raw
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// Look for cycles by walking the dependencies of every build
+	done := make(map[string]bool)
+	for _, name := range names {
+		if err := checkBuildDependencies(name, nil, builds, done); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// checkBuildDependencies walks the dependencies of the build with the
+// given name, returning an error if it finds a cycle. The stack holds the
+// names of the builds being walked, and done the names of the builds that
+// were already walked.
+func checkBuildDependencies(
+	name string, stack []string,
+	builds map[string]RawBuilderConfig, done map[string]bool) error {
+	if done[name] {
+		return nil
+	}
+
+	stack = append(stack, name)
+	for i, s := range stack[:len(stack)-1] {
+		if s == name {
+			// Mark the whole cycle as done so it is only reported once
+			for _, n := range stack {
+				done[n] = true
+			}
+
+			raw := builds[name]
+			return raw.source.errorAt(jsonutil.Path("depends_on"), fmt.Errorf(
+				"build dependency cycle detected: %s", strings.Join(stack[i:], " -> ")))
+		}
+	}
+
+	for _, dep := range builds[name].DependsOn {
+		if err := checkBuildDependencies(dep, stack, builds, done); err != nil {
+			return err
+		}
+	}
+
+	done[name] = true
+	return nil
+}
@@ -0,0 +1,92 @@
+package packer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// expandBuilderMatrix expands a builder with a "matrix" into one builder
+// for every combination of the values of the matrix axes. The matrix is
+// an object mapping axis names to either a list of values or a reference
+// to one of the given list variables, such as "{{user `versions`}}".
+//
+// The name of each expanded builder is the name of the builder followed
+// by the values of the axes, in the order of the axis names, separated
+// by dashes.
+func expandBuilderMatrix(
+	raw RawBuilderConfig, matrix interface{},
+	vars map[string]interface{}) ([]RawBuilderConfig, error) {
+	axes, ok := matrix.(map[string]interface{})
+	if !ok || len(axes) == 0 {
+		return nil, fmt.Errorf("'matrix' must be an object of axes")
+	}
+
+	names := make([]string, 0, len(axes))
+	for name, _ := range axes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([][]string, len(names))
+	for i, name := range names {
+		axis := axes[name]
+		if s, ok := axis.(string); ok {
+			if typed, ok := typedVariableRef(s, vars); ok {
+				axis = typed
+			}
+		}
+
+		list, ok := axis.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf(
+				"matrix axis '%s' must be a list or a list variable", name)
+		}
+
+		if len(list) == 0 {
+			return nil, fmt.Errorf("matrix axis '%s' has no values", name)
+		}
+
+		values[i] = make([]string, len(list))
+		for j, v := range list {
+			switch v.(type) {
+			case string, float64, bool:
+			default:
+				return nil, fmt.Errorf(
+					"matrix axis '%s' values must be strings, numbers or booleans", name)
+			}
+
+			values[i][j] = variableString(v)
+		}
+	}
+
+	// Build every combination, varying the last axis the fastest
+	combinations := [][]string{[]string{}}
+	for _, axisValues := range values {
+		next := make([][]string, 0, len(combinations)*len(axisValues))
+		for _, c := range combinations {
+			for _, v := range axisValues {
+				combination := make([]string, len(c), len(c)+1)
+				copy(combination, c)
+				next = append(next, append(combination, v))
+			}
+		}
+
+		combinations = next
+	}
+
+	result := make([]RawBuilderConfig, len(combinations))
+	for i, c := range combinations {
+		cell := raw
+		cell.Name = fmt.Sprintf("%s-%s", raw.Name, strings.Join(c, "-"))
+		cell.MatrixName = raw.Name
+		cell.MatrixValues = make(map[string]string, len(names))
+		for j, name := range names {
+			cell.MatrixValues[name] = c[j]
+		}
+
+		result[i] = cell
+	}
+
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package packer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandBuilderMatrix(t *testing.T) {
+	raw := RawBuilderConfig{
+		Name:      "ubuntu",
+		Type:      "foo",
+		RawConfig: map[string]interface{}{"type": "foo"},
+	}
+
+	matrix := map[string]interface{}{
+		"os_version": "{{user `versions`}}",
+		"arch":       []interface{}{"amd64", "i386"},
+	}
+
+	vars := map[string]interface{}{
+		"versions": []interface{}{"12.04", float64(14)},
+	}
+
+	result, err := expandBuilderMatrix(raw, matrix, vars)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	names := make([]string, len(result))
+	for i, r := range result {
+		names[i] = r.Name
+
+		if r.Type != "foo" || r.MatrixName != "ubuntu" {
+			t.Fatalf("bad: %#v", r)
+		}
+	}
+
+	expected := []string{
+		"ubuntu-amd64-12.04",
+		"ubuntu-amd64-14",
+		"ubuntu-i386-12.04",
+		"ubuntu-i386-14",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	values := map[string]string{"arch": "i386", "os_version": "14"}
+	if !reflect.DeepEqual(result[3].MatrixValues, values) {
+		t.Fatalf("bad: %#v", result[3].MatrixValues)
+	}
+}
+
+func TestExpandBuilderMatrix_errors(t *testing.T) {
+	raw := RawBuilderConfig{Name: "foo", Type: "foo"}
+
+	cases := []interface{}{
+		"foo",
+		map[string]interface{}{},
+		map[string]interface{}{"arch": "amd64"},
+		map[string]interface{}{"arch": "{{user `nope`}}"},
+		map[string]interface{}{"arch": []interface{}{}},
+		map[string]interface{}{"arch": []interface{}{[]interface{}{"a"}}},
+	}
+
+	for _, tc := range cases {
+		if _, err := expandBuilderMatrix(raw, tc, nil); err == nil {
+			t.Fatalf("should error: %#v", tc)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package packer
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 )
@@ -93,6 +97,143 @@ func TestParseTemplateFile_stdin(t *testing.T) {
 	}
 }
 
+func TestParseTemplateFile_include(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	common := `
+	{
+		"include": ["nested/provisioners.json"],
+		"variables": {"foo": "common", "bar": "common"},
+		"builders": [{"type": "common-builder"}],
+		"post-processors": ["common-pp"]
+	}
+	`
+
+	provisioners := `
+	{
+		"provisioners": [{"type": "included-prov"}]
+	}
+	`
+
+	data := `
+	{
+		"include": ["common.json"],
+		"variables": {"foo": "root"},
+		"builders": [{"type": "root-builder"}],
+		"provisioners": [{"type": "root-prov"}]
+	}
+	`
+
+	os.Mkdir(filepath.Join(td, "nested"), 0755)
+	ioutil.WriteFile(filepath.Join(td, "common.json"), []byte(common), 0644)
+	ioutil.WriteFile(filepath.Join(td, "nested", "provisioners.json"), []byte(provisioners), 0644)
+	ioutil.WriteFile(filepath.Join(td, "template.json"), []byte(data), 0644)
+
+	result, err := ParseTemplateFile(filepath.Join(td, "template.json"), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(result.Builders) != 2 {
+		t.Fatalf("bad: %#v", result.Builders)
+	}
+
+	if _, ok := result.Builders["common-builder"]; !ok {
+		t.Fatalf("bad: %#v", result.Builders)
+	}
+
+	if len(result.Provisioners) != 2 {
+		t.Fatalf("bad: %#v", result.Provisioners)
+	}
+
+	if result.Provisioners[0].Type != "included-prov" {
+		t.Fatalf("bad: %#v", result.Provisioners)
+	}
+
+	if result.Provisioners[1].Type != "root-prov" {
+		t.Fatalf("bad: %#v", result.Provisioners)
+	}
+
+	if len(result.PostProcessors) != 1 {
+		t.Fatalf("bad: %#v", result.PostProcessors)
+	}
+
+	if result.Variables["foo"].Default != "root" {
+		t.Fatalf("bad: %#v", result.Variables)
+	}
+
+	if result.Variables["bar"].Default != "common" {
+		t.Fatalf("bad: %#v", result.Variables)
+	}
+}
+
+func TestParseTemplateFile_includeCycle(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	a := `{"include": ["b.json"], "builders": [{"type": "a"}]}`
+	b := `{"include": ["a.json"]}`
+
+	ioutil.WriteFile(filepath.Join(td, "a.json"), []byte(a), 0644)
+	ioutil.WriteFile(filepath.Join(td, "b.json"), []byte(b), 0644)
+
+	_, err = ParseTemplateFile(filepath.Join(td, "a.json"), nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestParseTemplateFile_includeErrorSource(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	included := `{"provisioners": [{"type": "foo"}, {"bad": "prov"}]}`
+	data := `{"include": ["included.json"], "builders": [{"type": "foo"}]}`
+
+	includedPath := filepath.Join(td, "included.json")
+	ioutil.WriteFile(includedPath, []byte(included), 0644)
+	ioutil.WriteFile(filepath.Join(td, "template.json"), []byte(data), 0644)
+
+	_, err = ParseTemplateFile(filepath.Join(td, "template.json"), nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	expected := fmt.Sprintf("%s:1:36: provisioner 2: missing 'type'", includedPath)
+	if !strings.Contains(err.Error(), expected) {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestParseTemplateFile_includeMissing(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.Write([]byte(`{"include": ["i-dont-exist.json"], "builders": [{"type": "foo"}]}`))
+	tf.Close()
+
+	_, err = ParseTemplateFile(tf.Name(), nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestParseTemplate_Basic(t *testing.T) {
 	data := `
 	{
@@ -478,6 +619,80 @@ func TestParseTemplate_ProvisionerPauseBefore(t *testing.T) {
 	}
 }
 
+func TestParseTemplate_ProvisionerTimeout(t *testing.T) {
+	data := `
+	{
+		"builders": [{"type": "foo"}],
+
+		"provisioners": [
+			{
+				"type": "shell",
+				"timeout": "5m"
+			}
+		]
+	}
+	`
+
+	result, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(result.Provisioners) != 1 {
+		t.Fatalf("bad: %#v", result.Provisioners)
+	}
+	if result.Provisioners[0].timeout != 5*time.Minute {
+		t.Fatalf("bad: %s", result.Provisioners[0].timeout)
+	}
+}
+
+func TestParseTemplate_ProvisionerTimeoutInvalid(t *testing.T) {
+	data := `
+	{
+		"builders": [{"type": "foo"}],
+
+		"provisioners": [
+			{
+				"type": "shell",
+				"timeout": "-5m"
+			}
+		]
+	}
+	`
+
+	_, err := ParseTemplate([]byte(data), nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "timeout invalid") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestParseTemplate_ProvisionerTimeoutOverrideInvalid(t *testing.T) {
+	data := `
+	{
+		"builders": [{"type": "foo"}],
+
+		"provisioners": [
+			{
+				"type": "shell",
+				"override": {
+					"foo": {"timeout": "bar"}
+				}
+			}
+		]
+	}
+	`
+
+	_, err := ParseTemplate([]byte(data), nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "timeout of override 'foo' invalid") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
 func TestParseTemplate_Variables(t *testing.T) {
 	data := `
 	{
@@ -1527,6 +1742,150 @@ func TestTemplateBuild_ProvisionerPauseBefore(t *testing.T) {
 	}
 }
 
+func TestTemplateBuild_ProvisionerTimeout(t *testing.T) {
+	data := `
+	{
+		"builders": [
+			{
+				"name": "test1",
+				"type": "test-builder"
+			}
+		],
+
+		"provisioners": [
+			{
+				"type": "test-prov",
+				"pause_before": "5s",
+				"timeout": "10m"
+			}
+		]
+	}
+	`
+
+	template, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	builder := new(MockBuilder)
+	builderMap := map[string]Builder{
+		"test-builder": builder,
+	}
+
+	provisioner := &MockProvisioner{}
+	provisionerMap := map[string]Provisioner{
+		"test-prov": provisioner,
+	}
+
+	builderFactory := func(n string) (Builder, error) { return builderMap[n], nil }
+	provFactory := func(n string) (Provisioner, error) { return provisionerMap[n], nil }
+	components := &ComponentFinder{
+		Builder:     builderFactory,
+		Provisioner: provFactory,
+	}
+
+	build, err := template.Build("test1", components)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	coreBuild := build.(*coreBuild)
+	if len(coreBuild.provisioners) != 1 {
+		t.Fatalf("bad: %#v", coreBuild.provisioners)
+	}
+
+	// The pause wraps the timeout so the timeout doesn't include it
+	pp, ok := coreBuild.provisioners[0].provisioner.(*PausedProvisioner)
+	if !ok {
+		t.Fatalf("should be paused provisioner")
+	}
+	tp, ok := pp.Provisioner.(*TimeoutProvisioner)
+	if !ok {
+		t.Fatalf("should be timeout provisioner")
+	}
+	if tp.Timeout != 10*time.Minute {
+		t.Fatalf("bad: %#v", tp.Timeout)
+	}
+	if tp.Provisioner != provisioner {
+		t.Fatalf("bad: %#v", tp.Provisioner)
+	}
+
+	config := coreBuild.provisioners[0].config[0].(map[string]interface{})
+	if _, ok := config["timeout"]; ok {
+		t.Fatal("timeout should be removed")
+	}
+}
+
+func TestTemplateBuild_ProvisionerTimeoutOverride(t *testing.T) {
+	data := `
+	{
+		"builders": [
+			{
+				"name": "test1",
+				"type": "test-builder"
+			},
+			{
+				"name": "test2",
+				"type": "test-builder"
+			}
+		],
+
+		"provisioners": [
+			{
+				"type": "test-prov",
+				"timeout": "10m",
+				"override": {
+					"test1": {
+						"foo": "bar",
+						"timeout": "20m"
+					}
+				}
+			}
+		]
+	}
+	`
+
+	template, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	components := &ComponentFinder{
+		Builder: func(n string) (Builder, error) { return new(MockBuilder), nil },
+		Provisioner: func(n string) (Provisioner, error) {
+			return new(MockProvisioner), nil
+		},
+	}
+
+	cases := map[string]time.Duration{
+		"test1": 20 * time.Minute,
+		"test2": 10 * time.Minute,
+	}
+
+	for name, expected := range cases {
+		build, err := template.Build(name, components)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		prov := build.(*coreBuild).provisioners[0]
+		tp, ok := prov.provisioner.(*TimeoutProvisioner)
+		if !ok {
+			t.Fatalf("should be timeout provisioner")
+		}
+		if tp.Timeout != expected {
+			t.Fatalf("bad: %s %s", name, tp.Timeout)
+		}
+
+		// The timeout is removed from the override
+		for _, config := range prov.config[1:] {
+			if _, ok := config.(map[string]interface{})["timeout"]; ok {
+				t.Fatalf("timeout should be removed: %s", name)
+			}
+		}
+	}
+}
+
 func TestTemplateBuild_variables(t *testing.T) {
 	data := `
 	{
@@ -1590,3 +1949,746 @@ func TestTemplateBuild_variablesRequiredNotSet(t *testing.T) {
 		t.Fatal("should error")
 	}
 }
+
+func TestTemplateBuild_variablesTyped(t *testing.T) {
+	data := `
+	{
+		"variables": {
+			"regions": ["us-east-1", "us-west-2"],
+			"tags": {"type": "map", "default": {"role": "web"}},
+			"size": 10
+		},
+
+		"builders": [
+			{
+				"name": "test1",
+				"type": "test-builder",
+				"regions": "{{user ` + "`regions`" + `}}",
+				"extra_regions": ["eu-west-1", "{{user ` + "`regions`" + `}}"],
+				"tags": "{{user ` + "`tags`" + `}}",
+				"size": "{{user ` + "`size`" + `}}",
+				"name_tag": "{{user ` + "`regions`" + `}}-name"
+			}
+		]
+	}
+	`
+
+	template, err := ParseTemplate([]byte(data), map[string]string{
+		"regions": "ap-south-1,sa-east-1",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	build, err := template.Build("test1", testComponentFinder())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	coreBuild, ok := build.(*coreBuild)
+	if !ok {
+		t.Fatalf("couldn't convert!")
+	}
+
+	expectedVars := map[string]string{
+		"regions": `["ap-south-1","sa-east-1"]`,
+		"tags":    `{"role":"web"}`,
+		"size":    "10",
+	}
+	if !reflect.DeepEqual(coreBuild.variables, expectedVars) {
+		t.Fatalf("bad vars: %#v", coreBuild.variables)
+	}
+
+	expected := map[string]interface{}{
+		"type":          "test-builder",
+		"regions":       []interface{}{"ap-south-1", "sa-east-1"},
+		"extra_regions": []interface{}{"eu-west-1", "ap-south-1", "sa-east-1"},
+		"tags":          map[string]interface{}{"role": "web"},
+		"size":          float64(10),
+		"name_tag":      "{{user `regions`}}-name",
+	}
+	if !reflect.DeepEqual(coreBuild.builderConfig, expected) {
+		t.Fatalf("bad config: %#v", coreBuild.builderConfig)
+	}
+
+	// The template itself should not be modified
+	raw := template.Builders["test1"].RawConfig.(map[string]interface{})
+	if raw["regions"] != "{{user `regions`}}" {
+		t.Fatalf("bad raw: %#v", raw)
+	}
+}
+
+func TestTemplateBuild_variablesSensitive(t *testing.T) {
+	defer resetSensitiveValues()
+
+	data := `
+	{
+		"variables": {
+			"password": {"sensitive": true, "default": "hunter2"},
+			"keys": {"type": "list", "sensitive": true},
+			"port": {"type": "number", "sensitive": true, "default": 1},
+			"user": "bob"
+		},
+
+		"builders": [
+			{
+				"name": "test1",
+				"type": "test-builder"
+			}
+		]
+	}
+	`
+
+	template, err := ParseTemplate([]byte(data), map[string]string{
+		"keys": "key1,key2",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !template.Variables["password"].Sensitive {
+		t.Fatalf("bad: %#v", template.Variables["password"])
+	}
+
+	build, err := template.Build("test1", testComponentFinder())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	coreBuild := build.(*coreBuild)
+	sort.Strings(coreBuild.sensitive)
+	expected := []string{"hunter2", "key1", "key2"}
+	if !reflect.DeepEqual(coreBuild.sensitive, expected) {
+		t.Fatalf("bad: %#v", coreBuild.sensitive)
+	}
+
+	if result := ScrubSensitive("bob hunter2 key2 0.7.1"); result != "bob <Filtered> <Filtered> 0.7.1" {
+		t.Fatalf("bad: %s", result)
+	}
+}
+
+func TestParseTemplate_variablesValidation(t *testing.T) {
+	data := `
+	{
+		"variables": {
+			"instance_type": {
+				"default": "t2.micro",
+				"pattern": "^t2\\."
+			},
+			"region": {
+				"allowed": ["us-east-1", "us-west-2"]
+			},
+			"count": {
+				"type": "number",
+				"min": 1,
+				"max": 4
+			}
+		},
+
+		"builders": [{"type": "something"}]
+	}
+	`
+
+	_, err := ParseTemplate([]byte(data), map[string]string{
+		"instance_type": "t2.small",
+		"region":        "us-east-1",
+		"count":         "2",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = ParseTemplate([]byte(data), map[string]string{
+		"instance_type": "m1.small",
+		"region":        "eu-west-1",
+		"count":         "5",
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if len(err.(*MultiError).Errors) != 3 {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestParseTemplate_variablesValidationSensitive(t *testing.T) {
+	data := `
+	{
+		"variables": {
+			"password": {
+				"sensitive": true,
+				"pattern": "^[a-z]+$"
+			},
+			"pin": {
+				"sensitive": true,
+				"type": "number"
+			}
+		},
+
+		"builders": [{"type": "something"}]
+	}
+	`
+
+	_, err := ParseTemplate([]byte(data), map[string]string{
+		"password": "Hunter2",
+		"pin":      "secret-pin",
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if len(err.(*MultiError).Errors) != 2 {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if strings.Contains(err.Error(), "Hunter2") || strings.Contains(err.Error(), "secret-pin") {
+		t.Fatalf("error shows a sensitive value: %s", err)
+	}
+}
+
+func TestTemplateBuild_variablesValidateDefault(t *testing.T) {
+	data := `
+	{
+		"variables": {
+			"instance_type": {
+				"default": "{{env ` + "`PACKER_TEST_INSTANCE_TYPE`" + `}}",
+				"pattern": "^t2\\."
+			}
+		},
+
+		"builders": [
+			{
+				"name": "test1",
+				"type": "test-builder"
+			}
+		]
+	}
+	`
+
+	template, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	defer os.Setenv("PACKER_TEST_INSTANCE_TYPE", os.Getenv("PACKER_TEST_INSTANCE_TYPE"))
+
+	os.Setenv("PACKER_TEST_INSTANCE_TYPE", "m1.small")
+	_, err = template.Build("test1", testComponentFinder())
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "doesn't match pattern") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	os.Setenv("PACKER_TEST_INSTANCE_TYPE", "t2.micro")
+	_, err = template.Build("test1", testComponentFinder())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestParseTemplate_builderBase(t *testing.T) {
+	data := `
+	{
+		"builders": [
+			{
+				"name": "common",
+				"abstract": true,
+				"iso_url": "foo",
+				"boot": {"wait": "5s", "command": ["a"]},
+				"list": ["a", "b"]
+			},
+			{
+				"name": "east",
+				"type": "amazon-ebs",
+				"base": "common",
+				"region": "us-east-1",
+				"boot": {"command": ["b"]},
+				"list": ["c"]
+			},
+			{
+				"name": "west",
+				"base": "east",
+				"region": "us-west-2"
+			}
+		]
+	}
+	`
+
+	result, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(result.Builders) != 2 {
+		t.Fatalf("bad: %#v", result.Builders)
+	}
+
+	if _, ok := result.Builders["common"]; ok {
+		t.Fatal("abstract builder should not be a build")
+	}
+
+	east := result.Builders["east"]
+	if east.Type != "amazon-ebs" || east.Base != "common" {
+		t.Fatalf("bad: %#v", east)
+	}
+
+	expected := map[string]interface{}{
+		"type":    "amazon-ebs",
+		"iso_url": "foo",
+		"region":  "us-east-1",
+		"boot": map[string]interface{}{
+			"wait":    "5s",
+			"command": []interface{}{"b"},
+		},
+		"list": []interface{}{"c"},
+	}
+	if !reflect.DeepEqual(east.RawConfig, expected) {
+		t.Fatalf("bad: %#v", east.RawConfig)
+	}
+
+	west := result.Builders["west"]
+	if west.Type != "amazon-ebs" {
+		t.Fatalf("bad: %#v", west)
+	}
+
+	expected["region"] = "us-west-2"
+	if !reflect.DeepEqual(west.RawConfig, expected) {
+		t.Fatalf("bad: %#v", west.RawConfig)
+	}
+}
+
+func TestParseTemplate_builderBaseErrors(t *testing.T) {
+	cases := map[string]string{
+		`[{"name": "a", "base": "nope"}]`:                               "base builder 'nope' not found",
+		`[{"name": "a", "base": "b"}, {"name": "b", "base": "a"}]`:      "cycle detected",
+		`[{"name": "a", "abstract": true}, {"name": "b", "base": "a"}]`: "missing 'type'",
+		`[{"base": "a"}]`: "missing 'name'",
+		`[{"name": "a", "type": "foo", "abstract": "yes"}]`: "must be a boolean",
+	}
+
+	for builders, expected := range cases {
+		data := fmt.Sprintf(`{"builders": %s}`, builders)
+		_, err := ParseTemplate([]byte(data), nil)
+		if err == nil {
+			t.Fatalf("should have error: %s", builders)
+		}
+
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s\nbad: %s", builders, err)
+		}
+	}
+}
+
+func TestTemplate_builderMatrix(t *testing.T) {
+	data := `
+	{
+		"variables": {
+			"arch": "amd64"
+		},
+
+		"builders": [
+			{
+				"name": "ubuntu",
+				"type": "test-builder",
+				"matrix": {
+					"arch": ["amd64", "i386"]
+				}
+			},
+			{
+				"name": "other",
+				"type": "test-builder"
+			}
+		],
+
+		"provisioners": [
+			{
+				"type": "test-prov",
+				"only": ["ubuntu"]
+			},
+			{
+				"type": "test-prov",
+				"except": ["ubuntu-i386"]
+			}
+		]
+	}
+	`
+
+	template, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	names := template.BuildNames()
+	sort.Strings(names)
+	expected := []string{"other", "ubuntu-amd64", "ubuntu-i386"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	build, err := template.Build("ubuntu-i386", testComponentFinder())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cb := build.(*coreBuild)
+	if cb.variables["arch"] != "i386" {
+		t.Fatalf("bad: %#v", cb.variables)
+	}
+
+	if _, ok := cb.builderConfig.(map[string]interface{})["matrix"]; ok {
+		t.Fatalf("bad: %#v", cb.builderConfig)
+	}
+
+	if len(cb.provisioners) != 1 {
+		t.Fatalf("bad: %#v", cb.provisioners)
+	}
+
+	build, err = template.Build("other", testComponentFinder())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(build.(*coreBuild).provisioners) != 1 {
+		t.Fatalf("bad: %#v", build.(*coreBuild).provisioners)
+	}
+}
+
+func TestTemplate_builderMatrixDuplicate(t *testing.T) {
+	data := `
+	{
+		"builders": [
+			{
+				"name": "ubuntu",
+				"type": "test-builder",
+				"matrix": {"arch": ["amd64"]}
+			},
+			{
+				"name": "ubuntu-amd64",
+				"type": "test-builder"
+			}
+		]
+	}
+	`
+
+	_, err := ParseTemplate([]byte(data), nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestTemplate_builderMatrixDuplicateOrder(t *testing.T) {
+	data := `
+	{
+		"builders": [
+			{
+				"name": "ubuntu",
+				"type": "test-builder",
+				"matrix": {"arch": ["amd64-1"]}
+			},
+			{
+				"name": "ubuntu-amd64",
+				"type": "test-builder",
+				"matrix": {"version": ["1"]}
+			},
+			{
+				"name": "centos",
+				"base": "missing"
+			}
+		]
+	}
+	`
+
+	// The errors are the same every time, in the order of the template
+	var expected string
+	for i := 0; i < 20; i++ {
+		_, err := ParseTemplate([]byte(data), nil)
+		if err == nil {
+			t.Fatal("should have error")
+		}
+
+		merr, ok := err.(*MultiError)
+		if !ok {
+			t.Fatalf("bad: %#v", err)
+		}
+
+		if len(merr.Errors) != 2 {
+			t.Fatalf("bad: %s", err)
+		}
+
+		if !strings.Contains(merr.Errors[0].Error(), "builder 2:") ||
+			!strings.Contains(merr.Errors[0].Error(), "already exists") {
+			t.Fatalf("bad: %s", merr.Errors[0])
+		}
+
+		if i == 0 {
+			expected = err.Error()
+		} else if err.Error() != expected {
+			t.Fatalf("bad: %s\n\n%s", err, expected)
+		}
+	}
+}
+
+func TestParseTemplateFile_yaml(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	included := filepath.Join(dir, "included.json")
+	err = ioutil.WriteFile(included, []byte(`{"provisioners": [{"type": "shell"}]}`), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := filepath.Join(dir, "template.yml")
+	err = ioutil.WriteFile(path, []byte(`
+include:
+  - included.json
+
+variables:
+  size: 20
+
+# Comments are allowed
+builders:
+  - type: amazon-ebs
+    size: "{{user `+"`size`"+`}}"
+`), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result, err := ParseTemplateFile(path, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.Variables["size"].Type != VariableTypeNumber {
+		t.Fatalf("bad: %#v", result.Variables)
+	}
+
+	if _, ok := result.Builders["amazon-ebs"]; !ok {
+		t.Fatalf("bad: %#v", result.Builders)
+	}
+
+	if len(result.Provisioners) != 1 {
+		t.Fatalf("bad: %#v", result.Provisioners)
+	}
+}
+
+func TestParseTemplateFile_yamlSyntaxError(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte("description: foo\n  builders: [\n"))
+	tf.Close()
+
+	_, err = ParseTemplateFile(tf.Name(), nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if !strings.HasPrefix(err.Error(), "Error in line 2") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestParseTemplate_errorPositions(t *testing.T) {
+	cases := []struct {
+		Data     string
+		Expected string
+	}{
+		{
+			"{\n  \"builders\": [{\"type\": \"foo\"}],\n  \"bad\": true\n}",
+			"3:3: Unknown root level key in template: 'bad'",
+		},
+		{
+			"{\n  \"builders\": [{\"type\": \"foo\"}],\n  \"provisioners\": [\n    {\"bad\": true}\n  ]\n}",
+			"4:5: provisioner 1: missing 'type'",
+		},
+		{
+			"{\n  \"builders\": [{\"type\": \"foo\"}],\n  \"provisioners\": [\n    {\"type\": \"foo\",\n     \"only\": [\"bar\"]}\n  ]\n}",
+			"5:6: provisioner 1: 'only' specified builder 'bar' not found",
+		},
+		{
+			"builders:\n  - type: foo\npost-processors:\n  - [foo, {bad: true}]\n",
+			"4:11: Post-processor 1.2: missing 'type'",
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := ParseTemplate([]byte(tc.Data), nil)
+		if err == nil {
+			t.Fatalf("should have error: %s", tc.Data)
+		}
+
+		if !strings.Contains(err.Error(), tc.Expected) {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+}
+
+func TestTemplateBuild_errorPositions(t *testing.T) {
+	data := `{
+  "builders": [{"type": "test-builder"}],
+  "provisioners": [
+    {"type": "test-prov"},
+    {"type": "missing-prov"}
+  ]
+}`
+
+	template, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	components := testComponentFinder()
+	components.Provisioner = func(n string) (Provisioner, error) {
+		if n == "missing-prov" {
+			return nil, nil
+		}
+
+		return new(MockProvisioner), nil
+	}
+
+	_, err = template.Build("test-builder", components)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	expected := "5:6: Provisioner type not found: missing-prov"
+	if err.Error() != expected {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestTemplateBuild_prepareErrorPositions(t *testing.T) {
+	data := `{
+  "builders": [{
+    "type": "test-builder",
+    "foo": "bar"
+  }]
+}`
+
+	template, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	builder := new(MockBuilder)
+	builder.PrepareErr = &MultiError{[]error{
+		errors.New("Unknown configuration key: foo"),
+		errors.New("something else is wrong"),
+	}}
+
+	components := testComponentFinder()
+	components.Builder = func(n string) (Builder, error) { return builder, nil }
+
+	build, err := template.Build("test-builder", components)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = build.Prepare()
+	merr, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	expected := []string{
+		"4:5: Unknown configuration key: foo",
+		"2:16: something else is wrong",
+	}
+	for i, err := range merr.Errors {
+		if err.Error() != expected[i] {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+}
+
+func TestParseTemplate_builderDependsOn(t *testing.T) {
+	data := `
+	{
+		"builders": [
+			{"name": "base", "type": "foo"},
+			{
+				"name": "custom",
+				"type": "bar",
+				"depends_on": ["base"],
+				"matrix": {"flavor": ["a", "b"]}
+			},
+			{"name": "final", "type": "bar", "depends_on": ["custom", "base"]}
+		]
+	}
+	`
+
+	result, err := ParseTemplate([]byte(data), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string][]string{
+		"base":     nil,
+		"custom-a": []string{"base"},
+		"custom-b": []string{"base"},
+		"final":    []string{"custom-a", "custom-b", "base"},
+	}
+
+	for name, deps := range expected {
+		raw := result.Builders[name]
+		if len(deps) == 0 && len(raw.DependsOn) == 0 {
+			continue
+		}
+
+		if !reflect.DeepEqual(raw.DependsOn, deps) {
+			t.Fatalf("bad: %s %#v", name, raw.DependsOn)
+		}
+
+		if _, ok := raw.RawConfig.(map[string]interface{})["depends_on"]; ok {
+			t.Fatalf("bad: %#v", raw.RawConfig)
+		}
+	}
+}
+
+func TestParseTemplate_builderDependsOnErrors(t *testing.T) {
+	cases := []struct {
+		Builders string
+		Expected string
+	}{
+		{
+			`{"type": "foo", "depends_on": ["bar"]}`,
+			"build 'bar' not found for depends_on",
+		},
+		{
+			`{"type": "foo", "depends_on": ["foo"]}`,
+			"build 'foo' can't depend on itself",
+		},
+		{
+			`{"type": "a", "depends_on": ["b"]}, {"type": "b", "depends_on": ["c"]},
+			 {"type": "c", "depends_on": ["a"]}`,
+			"build dependency cycle detected: a -> b -> c -> a",
+		},
+	}
+
+	for _, tc := range cases {
+		data := fmt.Sprintf(`{"builders": [%s]}`, tc.Builders)
+		_, err := ParseTemplate([]byte(data), nil)
+		if err == nil {
+			t.Fatalf("should have error: %s", tc.Builders)
+		}
+
+		if !strings.Contains(err.Error(), tc.Expected) {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// typedVariableRefRe matches a string that is nothing but a reference to
+// a single user variable, such as "{{user `regions`}}".
+var typedVariableRefRe = regexp.MustCompile(
+	"^\\{\\{\\s*user\\s+(?:`([^`]*)`|\"([^\"]*)\")\\s*\\}\\}$")
+
+// rawVariableConfig is the long form of a variable definition within
+// a template, used when the variable is an object rather than just
+// a default value.
+type rawVariableConfig struct {
+	Type      string
+	Default   interface{}
+	Sensitive bool
+	Pattern   string
+	Allowed   []interface{}
+	Min       *float64
+	Max       *float64
+}
+
+// parseVariable parses a single entry of the "variables" section of a
+// template. The entry is either the default value itself, with the type
+// inferred from it, null for a required string, or an object with the
+// "type", "default" and "sensitive" keys along with the validation rules
+// "pattern", "allowed", "min" and "max".
+func parseVariable(raw interface{}) (RawVariable, error) {
+	var result RawVariable
+	result.Type = VariableTypeString
+
+	var defaultValue interface{}
+	switch v := raw.(type) {
+	case nil:
+		result.Required = true
+		defaultValue = ""
+	case map[string]interface{}:
+		var config rawVariableConfig
+		var md mapstructure.Metadata
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			Metadata: &md,
+			Result:   &config,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		if err := decoder.Decode(v); err != nil {
+			return result, err
+		}
+
+		if len(md.Unused) > 0 {
+			sort.Strings(md.Unused)
+			return result, fmt.Errorf("unknown key '%s'", md.Unused[0])
+		}
+
+		result.Sensitive = config.Sensitive
+		if config.Type != "" {
+			result.Type = config.Type
+		} else if config.Default != nil {
+			result.Type = variableType(config.Default)
+		}
+
+		result.Pattern = config.Pattern
+		result.Allowed = config.Allowed
+		result.Min = config.Min
+		result.Max = config.Max
+		if err := checkVariableRules(result); err != nil {
+			return result, err
+		}
+
+		defaultValue = config.Default
+		if defaultValue == nil {
+			result.Required = true
+			defaultValue = emptyVariable(result.Type)
+		}
+	default:
+		result.Type = variableType(v)
+		defaultValue = v
+	}
+
+	typed, err := convertVariable(result.Type, defaultValue)
+	if err != nil {
+		return result, err
+	}
+
+	result.TypedDefault = typed
+	result.Default = variableString(typed)
+	return result, nil
+}
+
+// checkVariableRules verifies that the validation rules of a variable
+// are valid and make sense for its type.
+func checkVariableRules(v RawVariable) error {
+	if v.Pattern != "" {
+		if v.Type != VariableTypeString && v.Type != VariableTypeList {
+			return fmt.Errorf("pattern can't be used with a %s variable", v.Type)
+		}
+
+		if _, err := regexp.Compile(v.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %s", err)
+		}
+	}
+
+	if v.Allowed != nil && v.Type == VariableTypeMap {
+		return fmt.Errorf("allowed can't be used with a %s variable", v.Type)
+	}
+
+	if v.Min != nil || v.Max != nil {
+		if v.Type != VariableTypeNumber {
+			return fmt.Errorf("min and max can't be used with a %s variable", v.Type)
+		}
+
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			return fmt.Errorf("min is greater than max")
+		}
+	}
+
+	return nil
+}
+
+// validateVariable checks the value of the variable with the given name
+// against its validation rules, returning an error for every rule the
+// value breaks. The errors leave out the values of sensitive variables,
+// since they can be reported before the values are registered to be
+// scrubbed.
+func validateVariable(name string, v RawVariable, val interface{}) []error {
+	values := []interface{}{val}
+	if list, ok := val.([]interface{}); ok {
+		values = list
+	}
+
+	var errs []error
+	for _, elem := range values {
+		s := variableString(elem)
+		shown := "'" + s + "'"
+		if v.Sensitive {
+			shown = "the value"
+		}
+
+		if v.Pattern != "" {
+			// The pattern was already checked when parsing
+			re := regexp.MustCompile(v.Pattern)
+			if !re.MatchString(s) {
+				errs = append(errs, fmt.Errorf(
+					"User variable '%s': %s doesn't match pattern '%s'",
+					name, shown, v.Pattern))
+			}
+		}
+
+		if v.Allowed != nil {
+			allowed := make([]string, len(v.Allowed))
+			found := false
+			for i, a := range v.Allowed {
+				allowed[i] = variableString(a)
+				if allowed[i] == s {
+					found = true
+				}
+			}
+
+			if !found {
+				errs = append(errs, fmt.Errorf(
+					"User variable '%s': %s is not one of the allowed values: %s",
+					name, shown, strings.Join(allowed, ", ")))
+			}
+		}
+
+		if n, ok := elem.(float64); ok {
+			shown = s
+			if v.Sensitive {
+				shown = "the value"
+			}
+
+			if v.Min != nil && n < *v.Min {
+				errs = append(errs, fmt.Errorf(
+					"User variable '%s': %s is less than the minimum of %s",
+					name, shown, variableString(*v.Min)))
+			}
+
+			if v.Max != nil && n > *v.Max {
+				errs = append(errs, fmt.Errorf(
+					"User variable '%s': %s is greater than the maximum of %s",
+					name, shown, variableString(*v.Max)))
+			}
+		}
+	}
+
+	return errs
+}
+
+// variableType infers the type of a variable from its default value.
+func variableType(v interface{}) string {
+	switch v.(type) {
+	case float64, int:
+		return VariableTypeNumber
+	case bool:
+		return VariableTypeBool
+	case []interface{}:
+		return VariableTypeList
+	case map[string]interface{}:
+		return VariableTypeMap
+	default:
+		return VariableTypeString
+	}
+}
+
+// emptyVariable returns the zero value for the given variable type.
+func emptyVariable(t string) interface{} {
+	switch t {
+	case VariableTypeNumber:
+		return float64(0)
+	case VariableTypeBool:
+		return false
+	case VariableTypeList:
+		return []interface{}{}
+	case VariableTypeMap:
+		return map[string]interface{}{}
+	default:
+		return ""
+	}
+}
+
+// convertVariable converts a value to the given variable type. Values
+// can either already be of the right type, such as from a JSON template,
+// or be strings, such as from the command line. A list can be given as
+// a string with a JSON array or with comma separated values, and a map
+// as a string with a JSON object.
+func convertVariable(t string, v interface{}) (interface{}, error) {
+	switch t {
+	case VariableTypeString:
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case float64, int, bool:
+			return variableString(v), nil
+		}
+	case VariableTypeNumber:
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			result, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a number", v)
+			}
+
+			return result, nil
+		}
+	case VariableTypeBool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			result, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a boolean", v)
+			}
+
+			return result, nil
+		}
+	case VariableTypeList:
+		switch v := v.(type) {
+		case []interface{}:
+			return v, nil
+		case string:
+			v = strings.TrimSpace(v)
+			if v == "" {
+				return []interface{}{}, nil
+			}
+
+			if strings.HasPrefix(v, "[") {
+				var result []interface{}
+				if err := json.Unmarshal([]byte(v), &result); err != nil {
+					return nil, fmt.Errorf("error decoding list: %s", err)
+				}
+
+				return result, nil
+			}
+
+			parts := strings.Split(v, ",")
+			result := make([]interface{}, len(parts))
+			for i, part := range parts {
+				result[i] = strings.TrimSpace(part)
+			}
+
+			return result, nil
+		}
+	case VariableTypeMap:
+		switch v := v.(type) {
+		case map[string]interface{}:
+			return v, nil
+		case string:
+			var result map[string]interface{}
+			if err := json.Unmarshal([]byte(v), &result); err != nil {
+				return nil, fmt.Errorf("error decoding map: %s", err)
+			}
+
+			return result, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type '%s'", t)
+	}
+
+	return nil, fmt.Errorf("value of type '%s' can't be used as a %s", variableType(v), t)
+}
+
+// variableString returns the string form of a typed variable value. This
+// is what the "user" template function returns. Lists and maps are
+// encoded as JSON.
+func variableString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		result, err := json.Marshal(v)
+		if err != nil {
+			// This should never happen since the values came from JSON
+			panic(err)
+		}
+
+		return string(result)
+	}
+}
+
+// processVariableDefault runs every string within a default value through
+// the given template, returning a copy of the value.
+func processVariableDefault(tpl *ConfigTemplate, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return tpl.Process(v, nil)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			processed, err := processVariableDefault(tpl, elem)
+			if err != nil {
+				return nil, err
+			}
+
+			result[i] = processed
+		}
+
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			processed, err := processVariableDefault(tpl, elem)
+			if err != nil {
+				return nil, err
+			}
+
+			result[k] = processed
+		}
+
+		return result, nil
+	default:
+		return v, nil
+	}
+}
+
+// interpolateTypedVariables returns a copy of the raw configuration where
+// every string that is only a reference to one of the given variables,
+// such as "{{user `regions`}}", is replaced with the typed value of that
+// variable. A reference to a list variable that is itself an element of
+// a list is expanded in place.
+func interpolateTypedVariables(raw interface{}, vars map[string]interface{}) interface{} {
+	if len(vars) == 0 {
+		return raw
+	}
+
+	switch v := raw.(type) {
+	case string:
+		if typed, ok := typedVariableRef(v, vars); ok {
+			return typed
+		}
+
+		return v
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			if s, ok := elem.(string); ok {
+				if typed, ok := typedVariableRef(s, vars); ok {
+					if list, ok := typed.([]interface{}); ok {
+						result = append(result, list...)
+						continue
+					}
+				}
+			}
+
+			result = append(result, interpolateTypedVariables(elem, vars))
+		}
+
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			result[k] = interpolateTypedVariables(elem, vars)
+		}
+
+		return result
+	default:
+		return raw
+	}
+}
+
+// sensitiveStrings returns the strings within a variable value, which
+// are the ways the value can show up in output. Numbers and booleans are
+// left out, since their text, such as "1" or "true", is so common that
+// filtering it would mangle unrelated output.
+func sensitiveStrings(v interface{}) []string {
+	var result []string
+	switch v := v.(type) {
+	case string:
+		result = append(result, v)
+	case []interface{}:
+		for _, elem := range v {
+			result = append(result, sensitiveStrings(elem)...)
+		}
+	case map[string]interface{}:
+		for _, elem := range v {
+			result = append(result, sensitiveStrings(elem)...)
+		}
+	}
+
+	return result
+}
+
+// typedVariableRef returns the typed value of the variable that s refers
+// to, if s is nothing but a reference to one of the given variables.
+func typedVariableRef(s string, vars map[string]interface{}) (interface{}, bool) {
+	match := typedVariableRefRe.FindStringSubmatch(s)
+	if match == nil {
+		return nil, false
+	}
+
+	name := match[1]
+	if name == "" {
+		name = match[2]
+	}
+
+	typed, ok := vars[name]
+	return typed, ok
+}
@@ -0,0 +1,175 @@
+package packer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVariable(t *testing.T) {
+	cases := []struct {
+		Input    interface{}
+		Type     string
+		Default  string
+		Required bool
+		Err      bool
+	}{
+		{"foo", VariableTypeString, "foo", false, false},
+		{nil, VariableTypeString, "", true, false},
+		{float64(27), VariableTypeNumber, "27", false, false},
+		{true, VariableTypeBool, "true", false, false},
+		{[]interface{}{"a", "b"}, VariableTypeList, `["a","b"]`, false, false},
+		{
+			map[string]interface{}{"type": "map", "default": map[string]interface{}{"a": "b"}},
+			VariableTypeMap, `{"a":"b"}`, false, false,
+		},
+		{map[string]interface{}{"type": "list"}, VariableTypeList, "[]", true, false},
+		{map[string]interface{}{"default": float64(1.5)}, VariableTypeNumber, "1.5", false, false},
+		{map[string]interface{}{"type": "number", "default": "foo"}, "", "", false, true},
+		{map[string]interface{}{"type": "bad"}, "", "", false, true},
+		{map[string]interface{}{"what": "foo"}, "", "", false, true},
+		{map[string]interface{}{"default": "t2.micro", "pattern": "^t2\\."}, VariableTypeString, "t2.micro", false, false},
+		{map[string]interface{}{"default": "a", "pattern": "("}, "", "", false, true},
+		{map[string]interface{}{"default": true, "pattern": "a"}, "", "", false, true},
+		{map[string]interface{}{"type": "map", "allowed": []interface{}{"a"}}, "", "", false, true},
+		{map[string]interface{}{"default": float64(2), "min": float64(1), "max": float64(4)}, VariableTypeNumber, "2", false, false},
+		{map[string]interface{}{"default": "2", "min": float64(1)}, "", "", false, true},
+		{map[string]interface{}{"default": float64(2), "min": float64(4), "max": float64(1)}, "", "", false, true},
+	}
+
+	for _, tc := range cases {
+		v, err := parseVariable(tc.Input)
+		if (err != nil) != tc.Err {
+			t.Fatalf("input: %#v\nerr: %s", tc.Input, err)
+		}
+		if tc.Err {
+			continue
+		}
+
+		if v.Type != tc.Type {
+			t.Fatalf("input: %#v\nbad type: %s", tc.Input, v.Type)
+		}
+		if v.Default != tc.Default {
+			t.Fatalf("input: %#v\nbad default: %s", tc.Input, v.Default)
+		}
+		if v.Required != tc.Required {
+			t.Fatalf("input: %#v\nbad required: %#v", tc.Input, v.Required)
+		}
+	}
+}
+
+func TestConvertVariable(t *testing.T) {
+	cases := []struct {
+		Type   string
+		Input  interface{}
+		Output interface{}
+		Err    bool
+	}{
+		{VariableTypeString, "foo", "foo", false},
+		{VariableTypeString, float64(7), "7", false},
+		{VariableTypeString, []interface{}{}, nil, true},
+		{VariableTypeNumber, "42", float64(42), false},
+		{VariableTypeNumber, "nope", nil, true},
+		{VariableTypeBool, "true", true, false},
+		{VariableTypeBool, "nope", nil, true},
+		{VariableTypeList, "a, b", []interface{}{"a", "b"}, false},
+		{VariableTypeList, `["a", "b,c"]`, []interface{}{"a", "b,c"}, false},
+		{VariableTypeList, "", []interface{}{}, false},
+		{VariableTypeMap, `{"a": "b"}`, map[string]interface{}{"a": "b"}, false},
+		{VariableTypeMap, "a=b", nil, true},
+	}
+
+	for _, tc := range cases {
+		result, err := convertVariable(tc.Type, tc.Input)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s %#v\nerr: %s", tc.Type, tc.Input, err)
+		}
+
+		if !reflect.DeepEqual(result, tc.Output) {
+			t.Fatalf("%s %#v\nbad: %#v", tc.Type, tc.Input, result)
+		}
+	}
+}
+
+func TestInterpolateTypedVariables(t *testing.T) {
+	vars := map[string]interface{}{
+		"list": []interface{}{"a", "b"},
+		"map":  map[string]interface{}{"foo": "bar"},
+	}
+
+	raw := map[string]interface{}{
+		"list":    "{{user `list`}}",
+		"quoted":  `{{ user "map" }}`,
+		"nested":  []interface{}{"{{user `list`}}", "c"},
+		"partial": "{{user `list`}}-foo",
+		"unknown": "{{user `unknown`}}",
+	}
+
+	expected := map[string]interface{}{
+		"list":    []interface{}{"a", "b"},
+		"quoted":  map[string]interface{}{"foo": "bar"},
+		"nested":  []interface{}{"a", "b", "c"},
+		"partial": "{{user `list`}}-foo",
+		"unknown": "{{user `unknown`}}",
+	}
+
+	result := interpolateTypedVariables(raw, vars)
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestValidateVariable(t *testing.T) {
+	min := float64(1)
+	max := float64(4)
+
+	cases := []struct {
+		Variable RawVariable
+		Value    interface{}
+		Errors   int
+	}{
+		{RawVariable{}, "foo", 0},
+		{RawVariable{Pattern: "^t2\\."}, "t2.micro", 0},
+		{RawVariable{Pattern: "^t2\\."}, "m1.small", 1},
+		{RawVariable{Pattern: "^us-"}, []interface{}{"us-east-1", "eu-west-1", "ap-1"}, 2},
+		{RawVariable{Allowed: []interface{}{"a", "b"}}, "b", 0},
+		{RawVariable{Allowed: []interface{}{"a", "b"}}, "c", 1},
+		{RawVariable{Allowed: []interface{}{float64(1), float64(2)}}, float64(2), 0},
+		{RawVariable{Allowed: []interface{}{"a"}, Pattern: "^b"}, "c", 2},
+		{RawVariable{Min: &min, Max: &max}, float64(1), 0},
+		{RawVariable{Min: &min, Max: &max}, float64(0.5), 1},
+		{RawVariable{Min: &min, Max: &max}, float64(5), 1},
+	}
+
+	for _, tc := range cases {
+		errs := validateVariable("foo", tc.Variable, tc.Value)
+		if len(errs) != tc.Errors {
+			t.Fatalf("value: %#v\nbad: %#v", tc.Value, errs)
+		}
+	}
+}
+
+func TestValidateVariable_sensitive(t *testing.T) {
+	min := float64(10)
+
+	cases := []struct {
+		Variable RawVariable
+		Value    interface{}
+	}{
+		{RawVariable{Sensitive: true, Pattern: "^[a-z]+$"}, "Hunter2"},
+		{RawVariable{Sensitive: true, Allowed: []interface{}{"a"}}, "Hunter2"},
+		{RawVariable{Sensitive: true, Min: &min}, float64(2.5)},
+	}
+
+	for _, tc := range cases {
+		errs := validateVariable("foo", tc.Variable, tc.Value)
+		if len(errs) != 1 {
+			t.Fatalf("value: %#v\nbad: %#v", tc.Value, errs)
+		}
+
+		value := variableString(tc.Value)
+		if strings.Contains(errs[0].Error(), value) {
+			t.Fatalf("error shows the value: %s", errs[0])
+		}
+	}
+}
@@ -38,6 +38,41 @@ type Ui interface {
 	Machine(string, ...string)
 }
 
+// A CancelableUi is a Ui whose questions can be cancelled before they are
+// answered. AskCancel is like Ask, except that it returns an error once
+// the given channel is closed.
+type CancelableUi interface {
+	Ui
+	AskCancel(string, <-chan struct{}) (string, error)
+}
+
+// AskCancel asks the Ui a question, returning an error once the given
+// channel is closed. If the Ui isn't a CancelableUi, its Ask keeps
+// waiting for the answer after that.
+func AskCancel(ui Ui, query string, cancelCh <-chan struct{}) (string, error) {
+	if c, ok := ui.(CancelableUi); ok {
+		return c.AskCancel(query, cancelCh)
+	}
+
+	type answer struct {
+		line string
+		err  error
+	}
+
+	result := make(chan answer, 1)
+	go func() {
+		line, err := ui.Ask(query)
+		result <- answer{line, err}
+	}()
+
+	select {
+	case a := <-result:
+		return a.line, a.err
+	case <-cancelCh:
+		return "", errors.New("cancelled")
+	}
+}
+
 // ColoredUi is a UI that is colored using terminal colors.
 type ColoredUi struct {
 	Color      UiColor
@@ -64,6 +99,10 @@ type BasicUi struct {
 	ErrorWriter io.Writer
 	l           sync.Mutex
 	interrupted bool
+
+	// The line being read for a question that was cancelled, which
+	// answers the next question.
+	scanCh chan string
 }
 
 // MachineReadableUi is a UI that only outputs machine-readable output
@@ -76,6 +115,10 @@ func (u *ColoredUi) Ask(query string) (string, error) {
 	return u.Ui.Ask(u.colorize(query, u.Color, true))
 }
 
+func (u *ColoredUi) AskCancel(query string, cancelCh <-chan struct{}) (string, error) {
+	return AskCancel(u.Ui, u.colorize(query, u.Color, true), cancelCh)
+}
+
 func (u *ColoredUi) Say(message string) {
 	u.Ui.Say(u.colorize(message, u.Color, true))
 }
@@ -134,6 +177,10 @@ func (u *TargettedUi) Ask(query string) (string, error) {
 	return u.Ui.Ask(u.prefixLines(true, query))
 }
 
+func (u *TargettedUi) AskCancel(query string, cancelCh <-chan struct{}) (string, error) {
+	return AskCancel(u.Ui, u.prefixLines(true, query), cancelCh)
+}
+
 func (u *TargettedUi) Say(message string) {
 	u.Ui.Say(u.prefixLines(true, message))
 }
@@ -167,6 +214,12 @@ func (u *TargettedUi) prefixLines(arrow bool, message string) string {
 }
 
 func (rw *BasicUi) Ask(query string) (string, error) {
+	return rw.AskCancel(query, nil)
+}
+
+// AskCancel stops waiting for the answer once the channel is closed. The
+// line can't stop being read, so it answers the next question instead.
+func (rw *BasicUi) AskCancel(query string, cancelCh <-chan struct{}) (string, error) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
 
@@ -178,6 +231,7 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	signal.Notify(sigCh, os.Interrupt)
 	defer signal.Stop(sigCh)
 
+	query = ScrubSensitive(query)
 	log.Printf("ui: ask: %s", query)
 	if query != "" {
 		if _, err := fmt.Fprint(rw.Writer, query+" "); err != nil {
@@ -185,19 +239,26 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 		}
 	}
 
-	result := make(chan string, 1)
-	go func() {
-		var line string
-		if _, err := fmt.Fscanln(rw.Reader, &line); err != nil {
-			log.Printf("ui: scan err: %s", err)
-		}
-
-		result <- line
-	}()
+	if rw.scanCh == nil {
+		scanCh := make(chan string, 1)
+		rw.scanCh = scanCh
+		go func() {
+			var line string
+			if _, err := fmt.Fscanln(rw.Reader, &line); err != nil {
+				log.Printf("ui: scan err: %s", err)
+			}
+
+			scanCh <- line
+		}()
+	}
 
 	select {
-	case line := <-result:
+	case line := <-rw.scanCh:
+		rw.scanCh = nil
 		return line, nil
+	case <-cancelCh:
+		fmt.Fprintln(rw.Writer)
+		return "", errors.New("cancelled")
 	case <-sigCh:
 		// Print a newline so that any further output starts properly
 		// on a new line.
@@ -214,6 +275,7 @@ func (rw *BasicUi) Say(message string) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
 
+	message = ScrubSensitive(message)
 	log.Printf("ui: %s", message)
 	_, err := fmt.Fprint(rw.Writer, message+"\n")
 	if err != nil {
@@ -225,6 +287,7 @@ func (rw *BasicUi) Message(message string) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
 
+	message = ScrubSensitive(message)
 	log.Printf("ui: %s", message)
 	_, err := fmt.Fprint(rw.Writer, message+"\n")
 	if err != nil {
@@ -241,6 +304,7 @@ func (rw *BasicUi) Error(message string) {
 		writer = rw.Writer
 	}
 
+	message = ScrubSensitive(message)
 	log.Printf("ui error: %s", message)
 	_, err := fmt.Fprint(writer, message+"\n")
 	if err != nil {
@@ -281,7 +345,8 @@ func (u *MachineReadableUi) Machine(category string, args ...string) {
 
 	// Prepare the args
 	for i, v := range args {
-		args[i] = strings.Replace(v, ",", "%!(PACKER_COMMA)", -1)
+		args[i] = ScrubSensitive(v)
+		args[i] = strings.Replace(args[i], ",", "%!(PACKER_COMMA)", -1)
 		args[i] = strings.Replace(args[i], "\r", "\\r", -1)
 		args[i] = strings.Replace(args[i], "\n", "\\n", -1)
 	}
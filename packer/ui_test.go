@@ -2,6 +2,7 @@ package packer
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"strings"
 	"testing"
@@ -148,6 +149,58 @@ func TestBasicUi_ImplUi(t *testing.T) {
 	}
 }
 
+func TestUi_ImplCancelableUi(t *testing.T) {
+	var _ CancelableUi = new(BasicUi)
+	var _ CancelableUi = new(ColoredUi)
+	var _ CancelableUi = new(TargettedUi)
+}
+
+func TestBasicUi_AskCancel(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ui := testUi()
+	ui.Reader = r
+
+	cancelCh := make(chan struct{})
+	close(cancelCh)
+	if _, err := ui.AskCancel("foo?", cancelCh); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The line that is typed after the question was cancelled answers
+	// the next question
+	go io.WriteString(w, "bar\n")
+	line, err := ui.Ask("baz?")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if line != "bar" {
+		t.Fatalf("bad: %#v", line)
+	}
+}
+
+func TestAskCancel(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	// A Ui that isn't cancelable is still asked
+	ui := &MachineReadableUi{Writer: new(bytes.Buffer)}
+	if _, err := AskCancel(ui, "foo?", nil); err == nil {
+		t.Fatal("should have error")
+	}
+
+	basic := testUi()
+	basic.Reader = r
+	colored := &ColoredUi{Color: UiColorRed, Ui: basic}
+
+	cancelCh := make(chan struct{})
+	close(cancelCh)
+	if _, err := AskCancel(colored, "foo?", cancelCh); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBasicUi_Error(t *testing.T) {
 	bufferUi := testUi()
 
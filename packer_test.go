@@ -33,3 +33,23 @@ func TestExtractMachineReadable(t *testing.T) {
 		t.Fatal("should be mr")
 	}
 }
+
+func TestExtractLogDir(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"build", "template.json"}, ""},
+		{[]string{"build", "-log-dir=logs", "template.json"}, "logs"},
+		{[]string{"build", "--log-dir", "logs", "template.json"}, "logs"},
+		{[]string{"-machine-readable", "build", "-log-dir=logs", "t.json"}, "logs"},
+		{[]string{"validate", "-log-dir=logs", "template.json"}, ""},
+		{[]string{"build", "-var", "log-dir=logs", "template.json"}, ""},
+	}
+
+	for _, tc := range cases {
+		if result := extractLogDir(tc.args); result != tc.expected {
+			t.Fatalf("bad: %#v: %s", tc.args, result)
+		}
+	}
+}
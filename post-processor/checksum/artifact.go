@@ -0,0 +1,50 @@
+package checksum
+
+import (
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"os"
+	"strings"
+)
+
+// Artifact is the artifact of the checksum post-processor, which has the
+// files of the artifact that was checksummed as well as the checksum
+// files. It has the builder ID and ID of the checksummed artifact, so
+// that the post-processors after it see that artifact.
+type Artifact struct {
+	Artifact      packer.Artifact
+	ChecksumFiles []string
+}
+
+func (a *Artifact) BuilderId() string {
+	return a.Artifact.BuilderId()
+}
+
+func (a *Artifact) Files() []string {
+	files := a.Artifact.Files()
+	result := make([]string, 0, len(files)+len(a.ChecksumFiles))
+	result = append(result, files...)
+	result = append(result, a.ChecksumFiles...)
+	return result
+}
+
+func (a *Artifact) Id() string {
+	return a.Artifact.Id()
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Checksums of %s: %s",
+		a.Artifact.Id(), strings.Join(a.ChecksumFiles, ", "))
+}
+
+// Destroy only removes the checksum files. The checksummed artifact is
+// kept by the post-processor, so it is destroyed on its own.
+func (a *Artifact) Destroy() error {
+	for _, path := range a.ChecksumFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package checksum
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestArtifact_Impl(t *testing.T) {
+	var _ packer.Artifact = new(Artifact)
+}
+
+func TestArtifactBuilderId(t *testing.T) {
+	a := &Artifact{
+		Artifact: &packer.MockArtifact{BuilderIdValue: "foo"},
+	}
+
+	if a.BuilderId() != "foo" {
+		t.Fatalf("bad: %s", a.BuilderId())
+	}
+}
+
+func TestArtifactFiles(t *testing.T) {
+	a := &Artifact{
+		Artifact:      &packer.MockArtifact{FilesValue: []string{"foo.box"}},
+		ChecksumFiles: []string{"foo.sha256"},
+	}
+
+	expected := []string{"foo.box", "foo.sha256"}
+	if !reflect.DeepEqual(a.Files(), expected) {
+		t.Fatalf("bad: %#v", a.Files())
+	}
+}
+
+func TestArtifactDestroy(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	boxPath := filepath.Join(td, "foo.box")
+	checksumPath := filepath.Join(td, "foo.sha256")
+	for _, path := range []string{boxPath, checksumPath} {
+		if err := ioutil.WriteFile(path, []byte("foo"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	inner := &packer.MockArtifact{FilesValue: []string{boxPath}}
+	a := &Artifact{
+		Artifact:      inner,
+		ChecksumFiles: []string{checksumPath},
+	}
+
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := os.Stat(checksumPath); !os.IsNotExist(err) {
+		t.Fatal("checksum file should be removed")
+	}
+	if _, err := os.Stat(boxPath); err != nil {
+		t.Fatalf("box should be kept: %s", err)
+	}
+	if inner.DestroyCalled {
+		t.Fatal("should not destroy the checksummed artifact")
+	}
+}
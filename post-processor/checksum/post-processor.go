@@ -0,0 +1,168 @@
+package checksum
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	ChecksumTypes []string `mapstructure:"checksum_types"`
+	OutputPath    string   `mapstructure:"output"`
+
+	tpl *packer.ConfigTemplate
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+// outputPathTemplate is the structure that is available within the
+// OutputPath template.
+type outputPathTemplate struct {
+	BuildName    string
+	ChecksumType string
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	_, err := common.DecodeConfig(&p.config, raws...)
+	if err != nil {
+		return err
+	}
+
+	p.config.tpl, err = packer.NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	p.config.tpl.UserVars = p.config.PackerUserVars
+	p.config.tpl.UpstreamArtifacts = p.config.PackerUpstreamArtifacts
+
+	if len(p.config.ChecksumTypes) == 0 {
+		p.config.ChecksumTypes = []string{"sha256"}
+	}
+
+	if p.config.OutputPath == "" {
+		p.config.OutputPath = "packer_{{.BuildName}}_{{.ChecksumType}}.checksum"
+	}
+
+	// Accumulate any errors
+	errs := new(packer.MultiError)
+
+	for _, t := range p.config.ChecksumTypes {
+		if common.HashForType(t) == nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"Unsupported checksum type: %s. Supported types are md5, sha1, sha256 and sha512.", t))
+		}
+	}
+
+	if err := p.config.tpl.Validate(p.config.OutputPath); err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error parsing output template: %s", err))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	// Find where the checksums of every type go before writing any
+	checksumFiles := make([]string, 0, len(p.config.ChecksumTypes))
+	for _, t := range p.config.ChecksumTypes {
+		path, err := p.config.tpl.Process(p.config.OutputPath, &outputPathTemplate{
+			BuildName:    p.config.PackerBuildName,
+			ChecksumType: t,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, other := range checksumFiles {
+			if other == path {
+				return nil, false, fmt.Errorf(
+					"The checksum files of the types %v are all written to %s. "+
+						"The output must include {{.ChecksumType}}.",
+					p.config.ChecksumTypes, path)
+			}
+		}
+
+		checksumFiles = append(checksumFiles, path)
+	}
+
+	for i, t := range p.config.ChecksumTypes {
+		ui.Say(fmt.Sprintf("Computing %s checksums: %s", t, checksumFiles[i]))
+		if err := writeChecksums(t, checksumFiles[i], artifact.Files()); err != nil {
+			return nil, false, fmt.Errorf("Error writing %s checksums: %s", t, err)
+		}
+	}
+
+	// The checksummed artifact is kept since the artifact returned
+	// includes its files.
+	result := &Artifact{
+		Artifact:      artifact,
+		ChecksumFiles: checksumFiles,
+	}
+
+	return result, true, nil
+}
+
+// writeChecksums writes the checksums of the files to the given path in
+// the format of the sha256sum tools: the checksum in hex, two spaces and
+// the name of the file. The names are relative to the directory of the
+// checksum file, when possible, so that the checksums can be verified
+// from there with "sha256sum -c".
+func writeChecksums(checksumType, path string, files []string) error {
+	var buf bytes.Buffer
+	for _, file := range files {
+		h := common.HashForType(checksumType)
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		name := file
+		if rel, err := relPath(filepath.Dir(path), file); err == nil {
+			name = rel
+		}
+
+		fmt.Fprintf(&buf, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), filepath.ToSlash(name))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// relPath returns the path of the file relative to the directory, using
+// absolute paths for both so that it works for any mix of paths.
+func relPath(dir, file string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	file, err = filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(dir, file)
+}
@@ -0,0 +1,142 @@
+package checksum
+
+import (
+	"bytes"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"packer_build_name": "foo",
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure_defaults(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(p.config.ChecksumTypes, []string{"sha256"}) {
+		t.Fatalf("bad: %#v", p.config.ChecksumTypes)
+	}
+}
+
+func TestPostProcessorConfigure_checksumTypes(t *testing.T) {
+	var p PostProcessor
+
+	c := testConfig()
+	c["checksum_types"] = []string{"md5", "sha512"}
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c["checksum_types"] = []string{"sha256", "crc32"}
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorConfigure_outputInvalid(t *testing.T) {
+	var p PostProcessor
+
+	c := testConfig()
+	c["output"] = "{{.ChecksumType"
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	boxPath := filepath.Join(td, "output", "foo.box")
+	if err := os.MkdirAll(filepath.Dir(boxPath), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(boxPath, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := testConfig()
+	c["checksum_types"] = []string{"md5", "sha256"}
+	c["output"] = filepath.Join(td, "{{.BuildName}}.{{.ChecksumType}}")
+
+	var p PostProcessor
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	input := &packer.MockArtifact{FilesValue: []string{boxPath}}
+	result, keep, err := p.PostProcess(testUi(), input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !keep {
+		t.Fatal("should keep the input artifact")
+	}
+
+	md5Path := filepath.Join(td, "foo.md5")
+	sha256Path := filepath.Join(td, "foo.sha256")
+	expectedFiles := []string{boxPath, md5Path, sha256Path}
+	if !reflect.DeepEqual(result.Files(), expectedFiles) {
+		t.Fatalf("bad: %#v", result.Files())
+	}
+
+	expected := map[string]string{
+		md5Path:    "5d41402abc4b2a76b9719d911017c592  output/foo.box\n",
+		sha256Path: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  output/foo.box\n",
+	}
+	for path, contents := range expected {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if string(data) != contents {
+			t.Fatalf("bad: %s: %s", path, data)
+		}
+	}
+}
+
+func TestPostProcessorPostProcess_sameOutput(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	c := testConfig()
+	c["checksum_types"] = []string{"md5", "sha256"}
+	c["output"] = filepath.Join(td, "CHECKSUMS")
+
+	var p PostProcessor
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	input := &packer.MockArtifact{FilesValue: []string{}}
+	if _, _, err := p.PostProcess(testUi(), input); err == nil {
+		t.Fatal("should have error")
+	}
+}
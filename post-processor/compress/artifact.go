@@ -0,0 +1,33 @@
+package compress
+
+import (
+	"fmt"
+	"os"
+)
+
+const BuilderId = "packer.post-processor.compress"
+
+// Artifact is the archive created by the compress post-processor.
+type Artifact struct {
+	Path string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return []string{a.Path}
+}
+
+func (*Artifact) Id() string {
+	return ""
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Compressed artifact: %s", a.Path)
+}
+
+func (a *Artifact) Destroy() error {
+	return os.Remove(a.Path)
+}
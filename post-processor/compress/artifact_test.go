@@ -0,0 +1,28 @@
+package compress
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"os"
+	"testing"
+)
+
+func TestArtifact_Impl(t *testing.T) {
+	var _ packer.Artifact = new(Artifact)
+}
+
+func TestArtifactDestroy(t *testing.T) {
+	td, artifact := testArtifact(t, map[string]string{
+		"disk.qcow2": "disk",
+	})
+	defer os.RemoveAll(td)
+
+	path := testPostProcess(t, td, "disk.qcow2.gz", artifact)
+	a := &Artifact{Path: path}
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("archive should be removed")
+	}
+}
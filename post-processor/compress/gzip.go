@@ -0,0 +1,152 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// gzipBlockSize is the amount of data compressed at once by each of the
+// goroutines of a parallelGzipWriter.
+const gzipBlockSize = 1 << 20
+
+// parallelGzipWriter is an io.WriteCloser that gzips the data written to
+// it with multiple goroutines. The data is split into blocks that are
+// compressed on their own into separate gzip members, which are written
+// in order. A file of concatenated members is a valid gzip file that
+// gunzip and the compress/gzip package decompress as a whole.
+type parallelGzipWriter struct {
+	w     io.Writer
+	level int
+
+	buf     []byte
+	pending chan chan gzipBlock
+	doneCh  chan struct{}
+	wrote   bool
+	closed  bool
+
+	err  error
+	lock sync.Mutex
+}
+
+// gzipBlock is the result of compressing a block.
+type gzipBlock struct {
+	data []byte
+	err  error
+}
+
+func newParallelGzipWriter(w io.Writer, level, parallel int) (*parallelGzipWriter, error) {
+	// Verify the level now rather than when compressing the first block
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+
+	result := &parallelGzipWriter{
+		w:       w,
+		level:   level,
+		buf:     make([]byte, 0, gzipBlockSize),
+		pending: make(chan chan gzipBlock, parallel),
+		doneCh:  make(chan struct{}),
+	}
+
+	go result.writeBlocks()
+	return result, nil
+}
+
+func (w *parallelGzipWriter) Write(p []byte) (int, error) {
+	if err := w.error(); err != nil {
+		return 0, err
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		size := gzipBlockSize - len(w.buf)
+		if size > len(p) {
+			size = len(p)
+		}
+
+		w.buf = append(w.buf, p[:size]...)
+		p = p[size:]
+
+		if len(w.buf) == gzipBlockSize {
+			w.compressBlock()
+		}
+	}
+
+	return n, nil
+}
+
+// Close compresses the rest of the data and waits for all the blocks to
+// be written. It doesn't close the underlying writer. It must be called
+// even if writing fails, since that stops the goroutine that writes the
+// blocks, and it can be called more than once.
+func (w *parallelGzipWriter) Close() error {
+	if w.closed {
+		return w.error()
+	}
+	w.closed = true
+
+	// Even without any data, the result must have one gzip member
+	if len(w.buf) > 0 || !w.wrote {
+		w.compressBlock()
+	}
+
+	close(w.pending)
+	<-w.doneCh
+
+	return w.error()
+}
+
+// compressBlock compresses the buffered data in a new goroutine. This
+// blocks if the maximum number of blocks are already being compressed.
+func (w *parallelGzipWriter) compressBlock() {
+	block := w.buf
+	w.buf = make([]byte, 0, gzipBlockSize)
+	w.wrote = true
+
+	resultCh := make(chan gzipBlock, 1)
+	w.pending <- resultCh
+
+	go func() {
+		var buf bytes.Buffer
+		gzw, err := gzip.NewWriterLevel(&buf, w.level)
+		if err == nil {
+			_, err = gzw.Write(block)
+		}
+		if err == nil {
+			err = gzw.Close()
+		}
+
+		resultCh <- gzipBlock{buf.Bytes(), err}
+	}()
+}
+
+// writeBlocks writes the compressed blocks in order as they complete.
+func (w *parallelGzipWriter) writeBlocks() {
+	defer close(w.doneCh)
+
+	for resultCh := range w.pending {
+		result := <-resultCh
+		if w.error() != nil {
+			continue
+		}
+
+		err := result.err
+		if err == nil {
+			_, err = w.w.Write(result.data)
+		}
+
+		if err != nil {
+			w.lock.Lock()
+			w.err = err
+			w.lock.Unlock()
+		}
+	}
+}
+
+func (w *parallelGzipWriter) error() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err
+}
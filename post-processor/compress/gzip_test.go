@@ -0,0 +1,126 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// errorWriter is an io.Writer that always fails.
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestParallelGzipWriter(t *testing.T) {
+	// Enough data for a few blocks, with a partial block at the end
+	data := bytes.Repeat([]byte("packer"), gzipBlockSize)
+
+	var buf bytes.Buffer
+	w, err := newParallelGzipWriter(&buf, flate.BestSpeed, 2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Write in chunks that don't line up with the blocks
+	for i := 0; i < len(data); i += 1000 {
+		end := i + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+
+		if _, err := w.Write(data[i:end]); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !bytes.Equal(result, data) {
+		t.Fatalf("bad: %d bytes", len(result))
+	}
+}
+
+func TestParallelGzipWriter_empty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newParallelGzipWriter(&buf, flate.DefaultCompression, 2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(result) != 0 {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestParallelGzipWriter_badLevel(t *testing.T) {
+	if _, err := newParallelGzipWriter(new(bytes.Buffer), 42, 2); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestParallelGzipWriter_writeError(t *testing.T) {
+	w, err := newParallelGzipWriter(errorWriter{}, flate.BestSpeed, 2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Write until the error of the underlying writer comes back
+	data := make([]byte, gzipBlockSize)
+	for i := 0; ; i++ {
+		if _, err := w.Write(data); err != nil {
+			break
+		}
+
+		if i == 100 {
+			t.Fatal("should have error")
+		}
+	}
+
+	if err := w.Close(); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The goroutine that writes the blocks is done
+	select {
+	case <-w.doneCh:
+	case <-time.After(time.Second):
+		t.Fatal("should be done")
+	}
+
+	// Closing again has no effect
+	if err := w.Close(); err == nil {
+		t.Fatal("should have error")
+	}
+}
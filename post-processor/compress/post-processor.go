@@ -0,0 +1,363 @@
+package compress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The formats of archives, which are picked by the extension of the
+// output path.
+const (
+	formatTarGz = "tar.gz"
+	formatZip   = "zip"
+	formatGzip  = "gzip"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	OutputPath       string `mapstructure:"output"`
+	CompressionLevel int    `mapstructure:"compression_level"`
+	Parallel         int    `mapstructure:"parallel"`
+
+	format string
+	tpl    *packer.ConfigTemplate
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+// outputPathTemplate is the structure that is available within the
+// OutputPath template.
+type outputPathTemplate struct {
+	BuildName   string
+	BuilderType string
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	md, err := common.DecodeConfig(&p.config, raws...)
+	if err != nil {
+		return err
+	}
+
+	p.config.tpl, err = packer.NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	p.config.tpl.UserVars = p.config.PackerUserVars
+	p.config.tpl.UpstreamArtifacts = p.config.PackerUpstreamArtifacts
+
+	// Defaults
+	if p.config.OutputPath == "" {
+		p.config.OutputPath = "packer_{{.BuildName}}.tar.gz"
+	}
+
+	found := false
+	for _, k := range md.Keys {
+		if k == "compression_level" {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		p.config.CompressionLevel = flate.DefaultCompression
+	}
+
+	if p.config.Parallel == 0 {
+		p.config.Parallel = runtime.NumCPU()
+	}
+
+	// Accumulate any errors
+	errs := common.CheckUnusedConfig(md)
+
+	p.config.OutputPath, err = p.config.tpl.Process(p.config.OutputPath, &outputPathTemplate{
+		BuildName:   p.config.PackerBuildName,
+		BuilderType: p.config.PackerBuilderType,
+	})
+	if err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error processing output: %s", err))
+	} else {
+		p.config.format = formatForPath(p.config.OutputPath)
+		if p.config.format == "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"The extension of output must be .tar.gz, .tgz, .zip or .gz: %s",
+				p.config.OutputPath))
+		}
+	}
+
+	if p.config.CompressionLevel < flate.DefaultCompression ||
+		p.config.CompressionLevel > flate.BestCompression {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"compression_level must be between %d and %d",
+			flate.DefaultCompression, flate.BestCompression))
+	}
+
+	if p.config.Parallel < 0 {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("parallel can't be negative"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	files, err := artifactFiles(artifact)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if p.config.format == formatGzip && len(files) != 1 {
+		return nil, false, fmt.Errorf(
+			"Only an artifact with a single file can be compressed to %s, "+
+				"this one has %d. Use a .tar.gz or .zip output instead.",
+			p.config.OutputPath, len(files))
+	}
+
+	ui.Say(fmt.Sprintf("Compressing %s: %s", p.config.format, p.config.OutputPath))
+	if err := os.MkdirAll(filepath.Dir(p.config.OutputPath), 0755); err != nil {
+		return nil, false, err
+	}
+
+	f, err := os.Create(p.config.OutputPath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch p.config.format {
+	case formatTarGz:
+		err = p.writeTarGz(ui, f, files)
+	case formatZip:
+		err = p.writeZip(ui, f, files)
+	case formatGzip:
+		err = p.writeGzip(ui, f, files[0])
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(p.config.OutputPath)
+		return nil, false, fmt.Errorf("Error compressing: %s", err)
+	}
+
+	return &Artifact{Path: p.config.OutputPath}, false, nil
+}
+
+// newGzipWriter returns a gzip writer for the configured compression
+// level that compresses in parallel if enabled.
+func (p *PostProcessor) newGzipWriter(w io.Writer) (io.WriteCloser, error) {
+	if p.config.Parallel > 1 {
+		log.Printf("Compressing in parallel with %d goroutines", p.config.Parallel)
+		return newParallelGzipWriter(w, p.config.CompressionLevel, p.config.Parallel)
+	}
+
+	return gzip.NewWriterLevel(w, p.config.CompressionLevel)
+}
+
+func (p *PostProcessor) writeTarGz(ui packer.Ui, w io.Writer, files []archiveFile) error {
+	gzw, err := p.newGzipWriter(w)
+	if err != nil {
+		return err
+	}
+	defer gzw.Close()
+
+	tw := tar.NewWriter(gzw)
+	for _, file := range files {
+		ui.Message(fmt.Sprintf("Compressing: %s", file.Name))
+		header, err := tar.FileInfoHeader(file.Info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = file.Name
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if err := copyFile(tw, file.Path); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gzw.Close()
+}
+
+// zipCompressorRegisterer is implemented by the zip writer of Go 1.6 and
+// later, which can compress with a compressor other than the default one.
+type zipCompressorRegisterer interface {
+	RegisterCompressor(uint16, zip.Compressor)
+}
+
+func (p *PostProcessor) writeZip(ui packer.Ui, w io.Writer, files []archiveFile) error {
+	zw := zip.NewWriter(w)
+
+	// Deflate with the configured level if the zip package allows it,
+	// and with the default level otherwise.
+	level := p.config.CompressionLevel
+	if r, ok := interface{}(zw).(zipCompressorRegisterer); ok {
+		r.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		})
+	} else {
+		log.Printf("Deflating with the default level, the zip package doesn't support others")
+	}
+
+	for _, file := range files {
+		ui.Message(fmt.Sprintf("Compressing: %s", file.Name))
+		header, err := zip.FileInfoHeader(file.Info)
+		if err != nil {
+			return err
+		}
+		header.Name = file.Name
+
+		header.Method = zip.Deflate
+		if p.config.CompressionLevel == flate.NoCompression {
+			header.Method = zip.Store
+		}
+
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if err := copyFile(fw, file.Path); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func (p *PostProcessor) writeGzip(ui packer.Ui, w io.Writer, file archiveFile) error {
+	ui.Message(fmt.Sprintf("Compressing: %s", file.Name))
+	gzw, err := p.newGzipWriter(w)
+	if err != nil {
+		return err
+	}
+	defer gzw.Close()
+
+	if err := copyFile(gzw, file.Path); err != nil {
+		return err
+	}
+
+	return gzw.Close()
+}
+
+// archiveFile is a file of an artifact along with its name within the
+// archive.
+type archiveFile struct {
+	Path string
+	Name string
+	Info os.FileInfo
+}
+
+// artifactFiles returns the files of the artifact, named by their path
+// relative to the directory that contains all of them. Directories are
+// skipped. The paths are made absolute first, since the directory that
+// contains both a relative and an absolute path can't be told otherwise.
+func artifactFiles(artifact packer.Artifact) ([]archiveFile, error) {
+	var result []archiveFile
+	for _, path := range artifact.Files() {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsDir() {
+			log.Printf("Skipping directory: %s", path)
+			continue
+		}
+
+		result = append(result, archiveFile{Path: path, Info: info})
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("The artifact has no files to compress.")
+	}
+
+	dir := filepath.Dir(result[0].Path)
+	for _, file := range result[1:] {
+		dir = commonDir(dir, filepath.Dir(file.Path))
+	}
+
+	for i, file := range result {
+		name, err := filepath.Rel(dir, file.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i].Name = filepath.ToSlash(name)
+	}
+
+	return result, nil
+}
+
+// commonDir returns the deepest directory that contains both directories,
+// which must be absolute.
+func commonDir(a, b string) string {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	for a != b {
+		if len(a) > len(b) {
+			a = filepath.Dir(a)
+		} else {
+			b = filepath.Dir(b)
+		}
+	}
+
+	return a
+}
+
+// formatForPath returns the format of an archive with the given path,
+// or an empty string if the extension is unknown.
+func formatForPath(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return formatTarGz
+	case strings.HasSuffix(lower, ".zip"):
+		return formatZip
+	case strings.HasSuffix(lower, ".gz"):
+		return formatGzip
+	default:
+		return ""
+	}
+}
+
+func copyFile(dst io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(dst, f)
+	return err
+}
@@ -0,0 +1,374 @@
+package compress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"packer_build_name":   "foo",
+		"packer_builder_type": "qemu",
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+// testArtifact creates an artifact with files in a temporary directory,
+// which is returned along with the artifact.
+func testArtifact(t *testing.T, files map[string]string) (string, packer.Artifact) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for name, contents := range files {
+		path := filepath.Join(td, "output", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return td, &packer.MockArtifact{FilesValue: paths}
+}
+
+func testPostProcess(t *testing.T, td, output string, artifact packer.Artifact) string {
+	c := testConfig()
+	c["output"] = filepath.Join(td, output)
+
+	var p PostProcessor
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result, keep, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keep {
+		t.Fatal("should not keep the input artifact")
+	}
+	if result.BuilderId() != BuilderId {
+		t.Fatalf("bad: %s", result.BuilderId())
+	}
+
+	return result.Files()[0]
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure_defaults(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.OutputPath != "packer_foo.tar.gz" {
+		t.Fatalf("bad: %s", p.config.OutputPath)
+	}
+	if p.config.format != formatTarGz {
+		t.Fatalf("bad: %s", p.config.format)
+	}
+	if p.config.CompressionLevel != flate.DefaultCompression {
+		t.Fatalf("bad: %d", p.config.CompressionLevel)
+	}
+	if p.config.Parallel < 1 {
+		t.Fatalf("bad: %d", p.config.Parallel)
+	}
+}
+
+func TestPostProcessorConfigure_compressionLevel(t *testing.T) {
+	var p PostProcessor
+
+	c := testConfig()
+	c["compression_level"] = 0
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.CompressionLevel != 0 {
+		t.Fatalf("bad: %d", p.config.CompressionLevel)
+	}
+
+	c["compression_level"] = 10
+	p = PostProcessor{}
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorConfigure_output(t *testing.T) {
+	cases := map[string]string{
+		"{{.BuildName}}.tgz":                  formatTarGz,
+		"{{.BuilderType}}/{{.BuildName}}.ZIP": formatZip,
+		"disk.qcow2.gz":                       formatGzip,
+		"disk.qcow2":                          "",
+	}
+
+	for output, format := range cases {
+		var p PostProcessor
+
+		c := testConfig()
+		c["output"] = output
+		err := p.Configure(c)
+		if format == "" {
+			if err == nil {
+				t.Fatalf("should have error: %s", output)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("err: %s: %s", output, err)
+		}
+		if p.config.format != format {
+			t.Fatalf("bad: %s: %s", output, p.config.format)
+		}
+	}
+}
+
+func TestPostProcessorPostProcess_tarGz(t *testing.T) {
+	td, artifact := testArtifact(t, map[string]string{
+		"disk.vmdk":     "disk",
+		"vm/packer.vmx": "vmx",
+	})
+	defer os.RemoveAll(td)
+
+	path := testPostProcess(t, td, "out/foo.tar.gz", artifact)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result := make(map[string]string)
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		result[header.Name] = string(data)
+	}
+
+	expected := map[string]string{
+		"disk.vmdk":     "disk",
+		"vm/packer.vmx": "vmx",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestPostProcessorPostProcess_zip(t *testing.T) {
+	td, artifact := testArtifact(t, map[string]string{
+		"disk.vmdk":     "disk",
+		"vm/packer.vmx": "vmx",
+	})
+	defer os.RemoveAll(td)
+
+	path := testPostProcess(t, td, "foo.zip", artifact)
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer zr.Close()
+
+	result := make(map[string]string)
+	for _, file := range zr.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		result[file.Name] = string(data)
+	}
+
+	expected := map[string]string{
+		"disk.vmdk":     "disk",
+		"vm/packer.vmx": "vmx",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestPostProcessorPostProcess_zipCompressionLevel(t *testing.T) {
+	// Text that compresses better with more effort
+	var buf bytes.Buffer
+	r := rand.New(rand.NewSource(42))
+	words := []string{"packer", "builder", "provisioner", "artifact", "template"}
+	for buf.Len() < 1<<20 {
+		buf.WriteString(words[r.Intn(len(words))])
+		buf.WriteString(strconv.Itoa(r.Intn(100)))
+	}
+
+	sizes := make(map[int]uint64)
+	for _, level := range []int{flate.BestSpeed, flate.BestCompression} {
+		td, artifact := testArtifact(t, map[string]string{
+			"disk.vmdk": buf.String(),
+		})
+		defer os.RemoveAll(td)
+
+		c := testConfig()
+		c["compression_level"] = level
+		c["output"] = filepath.Join(td, "foo.zip")
+
+		var p PostProcessor
+		if err := p.Configure(c); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if _, _, err := p.PostProcess(testUi(), artifact); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		zr, err := zip.OpenReader(c["output"].(string))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		sizes[level] = zr.File[0].CompressedSize64
+		zr.Close()
+	}
+
+	if sizes[flate.BestCompression] >= sizes[flate.BestSpeed] {
+		t.Fatalf("bad: %#v", sizes)
+	}
+}
+
+func TestPostProcessorPostProcess_relativePaths(t *testing.T) {
+	td, artifact := testArtifact(t, map[string]string{
+		"disk.vmdk":     "disk",
+		"vm/packer.vmx": "vmx",
+	})
+	defer os.RemoveAll(td)
+
+	// Mix a relative path in with the absolute one
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := artifact.Files()
+	rel, err := filepath.Rel(wd, files[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	artifact = &packer.MockArtifact{FilesValue: []string{rel, files[1]}}
+
+	path := testPostProcess(t, td, "foo.zip", artifact)
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer zr.Close()
+
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+
+	expected := []string{"disk.vmdk", "vm/packer.vmx"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+}
+
+func TestPostProcessorPostProcess_gzip(t *testing.T) {
+	td, artifact := testArtifact(t, map[string]string{
+		"disk.qcow2": "disk",
+	})
+	defer os.RemoveAll(td)
+
+	path := testPostProcess(t, td, "disk.qcow2.gz", artifact)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadAll(gzr)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "disk" {
+		t.Fatalf("bad: %s", data)
+	}
+}
+
+func TestPostProcessorPostProcess_gzipMultipleFiles(t *testing.T) {
+	td, artifact := testArtifact(t, map[string]string{
+		"disk.vmdk":  "disk",
+		"packer.vmx": "vmx",
+	})
+	defer os.RemoveAll(td)
+
+	c := testConfig()
+	c["output"] = filepath.Join(td, "foo.gz")
+
+	var p PostProcessor
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+}
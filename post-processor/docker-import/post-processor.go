@@ -33,6 +33,7 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return err
 	}
 	p.config.tpl.UserVars = p.config.PackerUserVars
+	p.config.tpl.UpstreamArtifacts = p.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := new(packer.MultiError)
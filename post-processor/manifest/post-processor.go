@@ -0,0 +1,161 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/common/uuid"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	OutputPath string `mapstructure:"output"`
+
+	tpl *packer.ConfigTemplate
+}
+
+// Record is what's written to the manifest for every artifact, as a
+// single line of JSON.
+type Record struct {
+	RunUUID     string            `json:"run_uuid"`
+	BuildName   string            `json:"build_name"`
+	BuilderType string            `json:"builder_type"`
+	BuilderId   string            `json:"builder_id"`
+	ArtifactId  string            `json:"artifact_id"`
+	Files       []File            `json:"files"`
+	BuildTime   int64             `json:"build_time"`
+	Variables   map[string]string `json:"variables"`
+}
+
+// File is a file of an artifact along with its size in bytes.
+type File struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	_, err := common.DecodeConfig(&p.config, raws...)
+	if err != nil {
+		return err
+	}
+
+	p.config.tpl, err = packer.NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	p.config.tpl.UserVars = p.config.PackerUserVars
+	p.config.tpl.UpstreamArtifacts = p.config.PackerUpstreamArtifacts
+
+	if p.config.OutputPath == "" {
+		p.config.OutputPath = "packer-manifest.json"
+	}
+
+	// Builds that aren't run by "packer build" don't have a run UUID,
+	// so they get one of their own.
+	if p.config.PackerRunUUID == "" {
+		p.config.PackerRunUUID = uuid.TimeOrderedUUID()
+	}
+
+	// Accumulate any errors
+	errs := new(packer.MultiError)
+
+	p.config.OutputPath, err = p.config.tpl.Process(p.config.OutputPath, nil)
+	if err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error processing output: %s", err))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	record := &Record{
+		RunUUID:     p.config.PackerRunUUID,
+		BuildName:   p.config.PackerBuildName,
+		BuilderType: p.config.PackerBuilderType,
+		BuilderId:   artifact.BuilderId(),
+		ArtifactId:  artifact.Id(),
+		Files:       make([]File, 0, len(artifact.Files())),
+		BuildTime:   time.Now().UTC().Unix(),
+		Variables:   p.variables(),
+	}
+
+	for _, path := range artifact.Files() {
+		file := File{Name: path}
+		if fi, err := os.Stat(path); err == nil {
+			file.Size = fi.Size()
+		} else {
+			log.Printf("Error getting the size of %s: %s", path, err)
+		}
+
+		record.Files = append(record.Files, file)
+	}
+
+	ui.Say(fmt.Sprintf("Adding build '%s' to manifest: %s",
+		record.BuildName, p.config.OutputPath))
+	if err := p.write(record); err != nil {
+		return nil, false, fmt.Errorf("Error writing manifest: %s", err)
+	}
+
+	return artifact, true, nil
+}
+
+// variables returns the user variables with the values of sensitive
+// variables filtered out.
+func (p *PostProcessor) variables() map[string]string {
+	result := make(map[string]string)
+	for k, v := range p.config.PackerUserVars {
+		for _, sensitive := range p.config.PackerSensitiveVars {
+			if v == sensitive {
+				v = packer.SensitiveFilterText
+				break
+			}
+		}
+
+		result[k] = v
+	}
+
+	return result
+}
+
+// write appends the record to the manifest. The record is written with a
+// single write to a file opened for appending, so that builds running in
+// parallel can add to the same manifest.
+func (p *PostProcessor) write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(p.config.OutputPath), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(
+		p.config.OutputPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
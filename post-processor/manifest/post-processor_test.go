@@ -0,0 +1,140 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"packer_build_name":   "foo",
+		"packer_builder_type": "virtualbox-iso",
+		"packer_run_uuid":     "abc",
+		"packer_user_variables": map[string]string{
+			"version":  "1.0",
+			"password": "secret",
+		},
+		"packer_sensitive_variables": []string{"secret"},
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var result []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		result = append(result, record)
+	}
+
+	return result
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure_defaults(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.OutputPath != "packer-manifest.json" {
+		t.Fatalf("bad: %s", p.config.OutputPath)
+	}
+	if p.config.PackerRunUUID == "" {
+		t.Fatal("should have a run UUID")
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	boxPath := filepath.Join(td, "foo.box")
+	if err := ioutil.WriteFile(boxPath, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := testConfig()
+	config["output"] = filepath.Join(td, "out", "manifest.json")
+
+	var p PostProcessor
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: "mitchellh.virtualbox",
+		IdValue:        "foo",
+		FilesValue:     []string{boxPath},
+	}
+
+	// Post-process twice to verify that records are appended
+	for i := 0; i < 2; i++ {
+		result, keep, err := p.PostProcess(testUi(), artifact)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !keep {
+			t.Fatal("should keep the artifact")
+		}
+		if result != artifact {
+			t.Fatalf("bad: %#v", result)
+		}
+	}
+
+	records := readRecords(t, filepath.Join(td, "out", "manifest.json"))
+	if len(records) != 2 {
+		t.Fatalf("bad: %#v", records)
+	}
+
+	record := records[1]
+	if record.BuildTime == 0 {
+		t.Fatal("should have build time")
+	}
+	record.BuildTime = 0
+
+	expected := Record{
+		RunUUID:     "abc",
+		BuildName:   "foo",
+		BuilderType: "virtualbox-iso",
+		BuilderId:   "mitchellh.virtualbox",
+		ArtifactId:  "foo",
+		Files:       []File{{Name: boxPath, Size: 5}},
+		Variables: map[string]string{
+			"version":  "1.0",
+			"password": packer.SensitiveFilterText,
+		},
+	}
+	if !reflect.DeepEqual(record, expected) {
+		t.Fatalf("bad: %#v", record)
+	}
+}
@@ -166,6 +166,7 @@ func (p *PostProcessor) configureSingle(config *Config, raws ...interface{}) err
 		return err
 	}
 	config.tpl.UserVars = config.PackerUserVars
+	config.tpl.UpstreamArtifacts = config.PackerUpstreamArtifacts
 
 	// Defaults
 	if config.OutputPath == "" {
@@ -57,6 +57,7 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	}
 
 	p.config.tpl.UserVars = p.config.PackerUserVars
+	p.config.tpl.UpstreamArtifacts = p.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := common.CheckUnusedConfig(md)
@@ -68,6 +68,7 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		return err
 	}
 	p.config.tpl.UserVars = p.config.PackerUserVars
+	p.config.tpl.UpstreamArtifacts = p.config.PackerUpstreamArtifacts
 
 	if p.config.ExecuteCommand == "" {
 		p.config.ExecuteCommand = "{{if .Sudo}}sudo {{end}}chef-client " +
@@ -6,17 +6,31 @@ import (
 	"github.com/mitchellh/packer/common"
 	"github.com/mitchellh/packer/packer"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
 )
 
 type config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
-	// The local path of the file to upload.
+	// The path of the file to copy, which is local when uploading and
+	// remote when downloading.
 	Source string
 
-	// The remote path where the local file will be uploaded to.
+	// The path where the file is copied to, which is remote when
+	// uploading and local when downloading.
 	Destination string
 
+	// Whether to upload the file to the machine or download it from the
+	// machine. Defaults to uploading.
+	Direction string
+
 	tpl *packer.ConfigTemplate
 }
 
@@ -35,6 +49,7 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		return err
 	}
 	p.config.tpl.UserVars = p.config.PackerUserVars
+	p.config.tpl.UpstreamArtifacts = p.config.PackerUpstreamArtifacts
 
 	// Accumulate any errors
 	errs := common.CheckUnusedConfig(md)
@@ -42,6 +57,7 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	templates := map[string]*string{
 		"source":      &p.config.Source,
 		"destination": &p.config.Destination,
+		"direction":   &p.config.Direction,
 	}
 
 	for n, ptr := range templates {
@@ -53,9 +69,26 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		}
 	}
 
-	if _, err := os.Stat(p.config.Source); err != nil {
-		errs = packer.MultiErrorAppend(errs,
-			fmt.Errorf("Bad source '%s': %s", p.config.Source, err))
+	if p.config.Direction == "" {
+		p.config.Direction = DirectionUpload
+	}
+
+	switch p.config.Direction {
+	case DirectionUpload:
+		if _, err := os.Stat(p.config.Source); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad source '%s': %s", p.config.Source, err))
+		}
+	case DirectionDownload:
+		// The source is on the machine, so it can't be checked until
+		// the file is downloaded.
+		if p.config.Source == "" {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("Source must be specified."))
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Direction must be 'upload' or 'download', got '%s'.", p.config.Direction))
 	}
 
 	if p.config.Destination == "" {
@@ -71,6 +104,10 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 }
 
 func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+	if p.config.Direction == DirectionDownload {
+		return p.provisionDownload(ui, comm)
+	}
+
 	ui.Say(fmt.Sprintf("Uploading %s => %s", p.config.Source, p.config.Destination))
 	info, err := os.Stat(p.config.Source)
 	if err != nil {
@@ -96,6 +133,49 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	return err
 }
 
+func (p *Provisioner) provisionDownload(ui packer.Ui, comm packer.Communicator) error {
+	src := p.config.Source
+	dst := p.config.Destination
+	ui.Say(fmt.Sprintf("Downloading %s => %s", src, dst))
+
+	// If we're downloading a directory, short circuit and do that. The
+	// machine can't be asked whether the source is a directory, so a
+	// directory is one with a trailing slash.
+	if strings.HasSuffix(src, "/") {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+
+		err := comm.DownloadDir(src, dst, nil)
+		if err != nil {
+			ui.Error(fmt.Sprintf("Download failed: %s", err))
+		}
+		return err
+	}
+
+	// We're downloading a file, into a directory if the destination has
+	// a trailing slash.
+	if strings.HasSuffix(dst, "/") || strings.HasSuffix(dst, string(os.PathSeparator)) {
+		dst = filepath.Join(dst, path.Base(src))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = comm.Download(src, f)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Download failed: %s", err))
+	}
+	return err
+}
+
 func (p *Provisioner) Cancel() {
 	// Just hard quit. It isn't a big deal if what we're doing keeps
 	// running on the other side.
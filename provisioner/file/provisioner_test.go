@@ -4,6 +4,7 @@ import (
 	"github.com/mitchellh/packer/packer"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -63,6 +64,22 @@ func TestProvisionerPrepare_ValidSource(t *testing.T) {
 	}
 }
 
+func TestProvisionerPrepare_Direction(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["source"] = "/this/is/on/the/machine"
+	config["direction"] = "download"
+
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("should not check the source when downloading: %s", err)
+	}
+
+	config["direction"] = "sideways"
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestProvisionerPrepare_EmptyDestination(t *testing.T) {
 	var p Provisioner
 
@@ -139,3 +156,73 @@ func TestProvisionerProvision_SendsFile(t *testing.T) {
 		t.Fatalf("should upload with source file's data")
 	}
 }
+
+func TestProvisionerProvision_DownloadsFile(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var p Provisioner
+	config := map[string]interface{}{
+		"source":      "/var/log/app.log",
+		"destination": td + "/logs/",
+		"direction":   "download",
+	}
+
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &packer.MockCommunicator{DownloadData: "hello"}
+	if err := p.Provision(&stubUi{}, comm); err != nil {
+		t.Fatalf("should successfully provision: %s", err)
+	}
+
+	if comm.DownloadPath != "/var/log/app.log" {
+		t.Fatalf("bad: %s", comm.DownloadPath)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(td, "logs", "app.log"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "hello" {
+		t.Fatalf("bad: %q", data)
+	}
+}
+
+func TestProvisionerProvision_DownloadsDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var p Provisioner
+	dst := filepath.Join(td, "reports")
+	config := map[string]interface{}{
+		"source":      "/tmp/reports/",
+		"destination": dst,
+		"direction":   "download",
+	}
+
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &packer.MockCommunicator{}
+	if err := p.Provision(&stubUi{}, comm); err != nil {
+		t.Fatalf("should successfully provision: %s", err)
+	}
+
+	if comm.DownloadDirSrc != "/tmp/reports/" || comm.DownloadDirDst != dst {
+		t.Fatalf("bad: %#v", comm)
+	}
+
+	if _, err := os.Stat(dst); err != nil {
+		t.Fatalf("destination should be created: %s", err)
+	}
+}